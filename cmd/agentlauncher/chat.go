@@ -0,0 +1,72 @@
+package main
+
+import (
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const chatHelp = `Type a message to send it to the agent. While it works, typed lines answer
+its questions or are sent to it as follow-ups. Ctrl-C cancels the current task.
+
+  /reset  forget the conversation
+  /exit   quit
+`
+
+func runChat(args []string) error {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	approve := flags.Bool("yes", false, "approve every tool call without asking")
+	quiet := flags.Bool("quiet", false, "only print the answers")
+	launcherFlags := addLauncherFlags(flags, "warn")
+	flags.Parse(args)
+
+	al, _, err := launcherFlags.newLauncher()
+	if err != nil {
+		return err
+	}
+	defer al.Close()
+	al.EnableAskUserTool()
+
+	lines := readLines(os.Stdin)
+	c := &console{al: al, out: os.Stdout, status: os.Stderr, input: lines, approve: *approve, quiet: *quiet}
+	fmt.Fprint(os.Stderr, chatHelp)
+	history := llminterface.MessageList{}
+	for {
+		fmt.Fprint(os.Stderr, "> ")
+		line, ok := <-lines
+		if !ok {
+			fmt.Fprintln(os.Stderr)
+			return nil
+		}
+		switch line = strings.TrimSpace(line); line {
+		case "":
+			continue
+		case "/exit", "/quit":
+			return nil
+		case "/reset":
+			history = llminterface.MessageList{}
+			fmt.Fprintln(os.Stderr, "conversation cleared")
+			continue
+		case "/help":
+			fmt.Fprint(os.Stderr, chatHelp)
+			continue
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		result := c.runTask(ctx, line, history)
+		stop()
+		if message, failed := launcher.TaskError(result); failed {
+			fmt.Fprintln(os.Stderr, "error:", message)
+			continue
+		}
+		history = append(history,
+			llminterface.UserMessage{Content: line},
+			llminterface.AssistantMessage{Content: strings.TrimSpace(result)},
+		)
+	}
+}
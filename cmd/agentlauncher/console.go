@@ -0,0 +1,190 @@
+package main
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// console runs tasks for the run and chat commands. It streams the primary
+// agent's text to out and progress to status, and reads the answers to tool
+// approvals and ask_user questions from input. Lines typed while no question
+// is pending are sent to the task as follow-ups.
+type console struct {
+	al     *launcher.AgentLauncher
+	out    io.Writer
+	status io.Writer
+	// input is nil when nobody can answer, approvals are then denied.
+	input <-chan string
+	// approve approves every tool call without asking.
+	approve bool
+	quiet   bool
+	mu      sync.Mutex
+}
+
+// prompt is a tool approval or a question waiting for the user.
+type prompt struct {
+	approval *events.ToolApprovalRequestEvent
+	question *events.UserInputRequestEvent
+}
+
+// runTask runs task to completion and prints its answer, unless it failed.
+func (c *console) runTask(ctx context.Context, task string, history llminterface.MessageList) string {
+	taskID := c.al.NewTaskID()
+	streamed := false
+	prompts, done := make(chan prompt), make(chan struct{})
+	defer close(done)
+	// Never block the bus on the user.
+	queue := func(p prompt) {
+		go func() {
+			select {
+			case prompts <- p:
+			case <-done:
+			}
+		}()
+	}
+	subscription := launcher.SubscribeEventsWhere(c.al, events.ForAgentTree(taskID), func(ctx context.Context, event eventbus.Event) {
+		switch e := event.(type) {
+		case events.MessageDeltaStreamingEvent:
+			if e.AgentID == taskID {
+				c.mu.Lock()
+				fmt.Fprint(c.out, e.Delta)
+				streamed = true
+				c.mu.Unlock()
+			}
+			return
+		case events.ToolApprovalRequestEvent:
+			if c.approve {
+				c.al.ApproveToolCall(e.AgentID, e.ToolCallID, true, "")
+			} else {
+				queue(prompt{approval: &e})
+			}
+		case events.UserInputRequestEvent:
+			queue(prompt{question: &e})
+		}
+		if line, ok := describe(event); ok && !c.quiet {
+			c.printStatus("[%s] %s\n", event.(events.AgentEvent).GetAgentID(), line)
+		}
+	})
+	defer subscription.Unsubscribe()
+
+	results := make(chan string, 1)
+	go func() {
+		results <- c.al.RunTaskContext(ctx, taskID, task, history)
+	}()
+
+	input, pending := c.input, []prompt{}
+	for {
+		select {
+		case result := <-results:
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if _, failed := launcher.TaskError(result); !failed {
+				if streamed {
+					fmt.Fprintln(c.out)
+				} else {
+					fmt.Fprintln(c.out, strings.TrimSpace(result))
+				}
+			}
+			return result
+
+		case p := <-prompts:
+			if input == nil {
+				c.dismiss(p)
+				continue
+			}
+			pending = append(pending, p)
+			if len(pending) == 1 {
+				c.ask(p)
+			}
+
+		case line, ok := <-input:
+			switch {
+			case !ok:
+				input = nil
+				for _, p := range pending {
+					c.dismiss(p)
+				}
+				pending = nil
+			case len(pending) > 0:
+				c.answer(pending[0], line)
+				if pending = pending[1:]; len(pending) > 0 {
+					c.ask(pending[0])
+				}
+			case strings.TrimSpace(line) != "":
+				c.al.FollowUp(taskID, line)
+			}
+		}
+	}
+}
+
+func (c *console) ask(p prompt) {
+	if p.approval != nil {
+		arguments, _ := json.Marshal(p.approval.Arguments)
+		c.printStatus("%s wants to call %s %s\nallow? [y/N] ", p.approval.AgentID, p.approval.ToolName, arguments)
+		return
+	}
+	c.printStatus("%s asks: %s\n? ", p.question.AgentID, p.question.Question)
+}
+
+// answer replies to p with a line typed by the user. Anything but yes denies
+// an approval, with the line as the reason unless it is a plain no.
+func (c *console) answer(p prompt, line string) {
+	line = strings.TrimSpace(line)
+	if p.question != nil {
+		c.al.AnswerUser(p.question.AgentID, p.question.RequestID, line)
+		return
+	}
+	switch strings.ToLower(line) {
+	case "y", "yes":
+		c.al.ApproveToolCall(p.approval.AgentID, p.approval.ToolCallID, true, "")
+	case "", "n", "no":
+		c.al.ApproveToolCall(p.approval.AgentID, p.approval.ToolCallID, false, "")
+	default:
+		c.al.ApproveToolCall(p.approval.AgentID, p.approval.ToolCallID, false, line)
+	}
+}
+
+// dismiss replies to p when nobody is there to answer it.
+func (c *console) dismiss(p prompt) {
+	if p.question != nil {
+		c.al.AnswerUser(p.question.AgentID, p.question.RequestID, "The user is not available, continue without an answer.")
+		return
+	}
+	c.al.ApproveToolCall(p.approval.AgentID, p.approval.ToolCallID, false, "nobody is there to approve it, run with -yes to approve tool calls")
+}
+
+func (c *console) printStatus(format string, args ...any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.status, format, args...)
+}
+
+// readLines sends the lines of r until it ends.
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return lines
+}
+
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
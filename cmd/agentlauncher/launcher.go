@@ -0,0 +1,102 @@
+package main
+
+import (
+	"agentlauncher/internal/eventlog"
+	"agentlauncher/internal/providers"
+	"agentlauncher/launcher"
+	"flag"
+	"log/slog"
+	"os"
+)
+
+// launcherFlags are the flags shared by the commands that run agents.
+type launcherFlags struct {
+	flags         *flag.FlagSet
+	config        *string
+	model         *string
+	subAgentModel *string
+	baseURL       *string
+	logFormat     *string
+	logLevel      *string
+	eventLog      *string
+}
+
+func addLauncherFlags(flags *flag.FlagSet, logLevel string) *launcherFlags {
+	return &launcherFlags{
+		flags:         flags,
+		config:        flags.String("config", os.Getenv("AGENTLAUNCHER_CONFIG"), "YAML or JSON file describing the launcher, see launcher.Config (defaults to $AGENTLAUNCHER_CONFIG)"),
+		model:         flags.String("model", "", "model for primary agents (overrides the config, defaults to "+providers.DEFAULT_OPENAI_MODEL+")"),
+		subAgentModel: flags.String("sub-agent-model", "", "model for sub-agents (overrides the config, defaults to -model)"),
+		baseURL:       flags.String("base-url", "", "OpenAI-compatible API base URL of every provider (defaults to $OPENAI_BASE_URL)"),
+		logFormat:     flags.String("log-format", "text", "log format: text or json (overrides the config)"),
+		logLevel:      flags.String("log-level", logLevel, "log level: trace, debug, info, warn or error (overrides the config)"),
+		eventLog:      flags.String("event-log", "", "record every event to this JSONL file, see the replay command"),
+	}
+}
+
+func (lf *launcherFlags) newLauncher() (*launcher.AgentLauncher, *slog.Logger, error) {
+	cfg, err := launcher.ParseConfig(nil, "")
+	if *lf.config != "" {
+		cfg, err = launcher.LoadConfig(*lf.config)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := lf.override(cfg); err != nil {
+		return nil, nil, err
+	}
+	al, err := launcher.NewFromConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if *lf.eventLog != "" {
+		backend, err := eventlog.OpenJSONL(*lf.eventLog)
+		if err != nil {
+			al.Close()
+			return nil, nil, err
+		}
+		al.WithEventLog(backend)
+	}
+	return al, cfg.Logger(), nil
+}
+
+// override applies the flags given on the command line to cfg.
+func (lf *launcherFlags) override(cfg *launcher.Config) error {
+	set := make(map[string]bool)
+	lf.flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if *lf.subAgentModel != "" {
+		if cfg.SubAgent == cfg.MainAgent {
+			// Give sub-agents a model of their own before -model changes the shared one.
+			cfg.SubAgent = "sub-agent"
+			cfg.Models[cfg.SubAgent] = cfg.Models[cfg.MainAgent]
+		}
+		model := cfg.Models[cfg.SubAgent]
+		model.Model = *lf.subAgentModel
+		cfg.Models[cfg.SubAgent] = model
+	}
+	if *lf.model != "" {
+		model := cfg.Models[cfg.MainAgent]
+		model.Model = *lf.model
+		cfg.Models[cfg.MainAgent] = model
+	}
+	if *lf.baseURL != "" {
+		for name, provider := range cfg.Providers {
+			provider.BaseURL = *lf.baseURL
+			cfg.Providers[name] = provider
+		}
+	}
+	if cfg.Logging == nil {
+		cfg.Logging = &launcher.LoggingConfig{Format: *lf.logFormat, Level: *lf.logLevel}
+	}
+	if set["log-format"] {
+		cfg.Logging.Format = *lf.logFormat
+	}
+	if set["log-level"] {
+		cfg.Logging.Level = *lf.logLevel
+	}
+	return cfg.Validate()
+}
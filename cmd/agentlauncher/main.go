@@ -0,0 +1,62 @@
+// Command agentlauncher runs agents from the command line.
+//
+// Usage:
+//
+//	agentlauncher run [flags] [task]        run a task, streaming its output
+//	agentlauncher chat [flags]              chat with an agent, keeping the conversation
+//	agentlauncher tools list [flags]        print the schemas of the available tools
+//	agentlauncher replay [flags] <log>      render an event log recorded with -event-log
+//	agentlauncher serve [flags]             expose the launcher as a REST API
+//
+// The launcher is described by the YAML or JSON file given with -config, see
+// launcher.Config. Without one, agents use the OpenAI API configured by the
+// environment.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: agentlauncher <command> [flags]
+
+Commands:
+  run         run a task, streaming its output
+  chat        chat with an agent, keeping the conversation
+  tools list  print the schemas of the available tools
+  replay      render an event log recorded with -event-log
+  serve       expose the launcher as a REST API
+
+Run "agentlauncher <command> -h" for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "run":
+		err = runRun(args)
+	case "chat":
+		err = runChat(args)
+	case "tools":
+		err = runTools(args)
+	case "replay":
+		err = runReplay(args)
+	case "serve":
+		err = runServe(args)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
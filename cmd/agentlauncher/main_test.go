@@ -0,0 +1,144 @@
+package main
+
+import (
+	"agentlauncher/internal/llminterface"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// subAgentID is the sub-agent of testdata/events.jsonl.
+const subAgentID = "agent0_a18366f8-4a62-434f-95e8-4b1b22d5489f"
+
+// capture returns what run prints to stdout.
+func capture(t *testing.T, run func() error) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- data
+	}()
+	err = run()
+	writer.Close()
+	data := <-output
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// assertGolden compares got with testdata/name.
+func assertGolden(t *testing.T, got, name string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs, run with -update to accept\ngot:\n%s", name, got)
+	}
+}
+
+func TestToolsList(t *testing.T) {
+	output := capture(t, func() error {
+		return runTools([]string{"list", "-config", "testdata/config.yaml"})
+	})
+	assertGolden(t, output, "tools.golden")
+}
+
+func TestToolsListJSON(t *testing.T) {
+	output := capture(t, func() error {
+		return runTools([]string{"list", "-config", "testdata/config.yaml", "-json"})
+	})
+	var schemas []llminterface.ToolSchema
+	if err := json.Unmarshal([]byte(output), &schemas); err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, schema := range schemas {
+		names = append(names, schema.Name)
+	}
+	if strings.Join(names, ",") != "create_sub_agent,fetch,grep" {
+		t.Errorf("expected the configured tools sorted by name, got %v", names)
+	}
+}
+
+func TestToolsRequiresList(t *testing.T) {
+	if err := runTools([]string{"show"}); err == nil {
+		t.Error("expected an error for an unknown subcommand")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	output := capture(t, func() error {
+		return runReplay([]string{"testdata/events.jsonl"})
+	})
+	assertGolden(t, output, "replay.golden")
+}
+
+func TestReplayAgent(t *testing.T) {
+	all := capture(t, func() error {
+		return runReplay([]string{"testdata/events.jsonl"})
+	})
+	output := capture(t, func() error {
+		return runReplay([]string{"-agent", subAgentID, "testdata/events.jsonl"})
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || len(lines) >= len(strings.Split(strings.TrimSpace(all), "\n")) {
+		t.Fatalf("expected a part of the log, got:\n%s", output)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "["+subAgentID+"]") {
+			t.Errorf("expected only the sub-agent's events, got %q", line)
+		}
+	}
+}
+
+func TestReplayAll(t *testing.T) {
+	output := capture(t, func() error {
+		return runReplay([]string{"-all", "testdata/events.jsonl"})
+	})
+	log, err := os.ReadFile("testdata/events.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(output, "\n"), bytes.Count(log, []byte("\n")); got != want {
+		t.Errorf("expected every one of the %d events, got %d lines", want, got)
+	}
+	if !strings.Contains(output, "MessagesAddEvent {") {
+		t.Errorf("expected the payloads of the events, got:\n%s", output)
+	}
+}
+
+func TestReplayMissingLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.jsonl")
+	if err := runReplay([]string{path}); err == nil {
+		t.Error("expected an error for a missing event log")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("replay created the missing event log")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/runtimes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const maxRenderedLength = 200
+
+// describe renders the events worth showing a person as one line, and
+// reports false for the rest.
+func describe(event eventbus.Event) (string, bool) {
+	switch e := event.(type) {
+	case events.TaskCreateEvent:
+		return "task: " + shorten(e.Task), true
+	case events.AgentCreateEvent:
+		if runtimes.GetParentAgentID(e.AgentID) == "" {
+			return "", false
+		}
+		if e.Profile != "" {
+			return fmt.Sprintf("sub-agent created (%s): %s", e.Profile, shorten(e.Task)), true
+		}
+		return "sub-agent created: " + shorten(e.Task), true
+	case events.LLMRequestEvent:
+		if e.RetryCount > 0 {
+			return fmt.Sprintf("thinking (retry %d)", e.RetryCount), true
+		}
+		return "thinking", true
+	case events.LLMRuntimeErrorEvent:
+		return "llm error: " + shorten(e.Error), true
+	case events.LLMRouteFailedEvent:
+		return fmt.Sprintf("llm route %s failed: %s", e.Route, shorten(e.Error)), true
+	case events.ToolExecQueuedEvent:
+		return fmt.Sprintf("%s queued on %s", e.ToolName, e.Limiter), true
+	case events.ToolExecStartEvent:
+		arguments, _ := json.Marshal(e.Arguments)
+		return fmt.Sprintf("calling %s %s", e.ToolName, shorten(string(arguments))), true
+	case events.ToolExecFinishEvent:
+		return fmt.Sprintf("%s returned: %s", e.ToolName, shorten(e.Result)), true
+	case events.ToolExecErrorEvent:
+		return fmt.Sprintf("%s failed: %s", e.ToolName, shorten(e.Error)), true
+	case events.ToolApprovalRequestEvent:
+		return fmt.Sprintf("waiting for approval of %s", e.ToolName), true
+	case events.ToolApprovalResponseEvent:
+		if e.Approved {
+			return "tool call approved", true
+		}
+		return "tool call denied", true
+	case events.UserInputRequestEvent:
+		return "asking the user: " + shorten(e.Question), true
+	case events.UserInputResponseEvent:
+		return "user answered: " + shorten(e.Answer), true
+	case events.UserFollowUpEvent:
+		return "follow-up: " + shorten(e.Content), true
+	case events.AgentRuntimeErrorEvent:
+		return "error: " + shorten(e.Error), true
+	case events.AgentFinishEvent:
+		return "finished: " + shorten(e.Result), true
+	case events.TaskCancelEvent:
+		return "cancelled: " + cmp.Or(e.Reason, "Task cancelled"), true
+	case events.TaskFinishEvent:
+		return "task finished", true
+	}
+	return "", false
+}
+
+// shorten puts text on a single line of at most maxRenderedLength runes.
+func shorten(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxRenderedLength {
+		return string(runes[:maxRenderedLength-1]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"agentlauncher/internal/eventlog"
+	"agentlauncher/internal/events"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func runReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		flags.Output().Write([]byte("Usage: agentlauncher replay [flags] <event log>\n\nRenders an event log recorded with -event-log.\n\n"))
+		flags.PrintDefaults()
+	}
+	all := flags.Bool("all", false, "show every event, with its full payload")
+	agent := flags.String("agent", "", "only show this agent and its sub-agents")
+	realTime := flags.Bool("real-time", false, "wait between events as long as they were apart when recorded")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	// OpenJSONL creates missing files, a typo should not.
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	backend, err := eventlog.OpenJSONL(path)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+	entries, err := eventlog.ReadAll(backend, events.NewRegistry())
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return errors.New("the event log is empty")
+	}
+
+	inTree := events.ForAgentTree(*agent)
+	start, previous := entries[0].Time, entries[0].Time
+	for _, entry := range entries {
+		if *agent != "" && !inTree(entry.Decoded) {
+			continue
+		}
+		line, ok := describe(entry.Decoded)
+		if *all {
+			line, ok = entry.Type+" "+string(entry.Event), true
+		}
+		if !ok {
+			continue
+		}
+		if *realTime {
+			time.Sleep(entry.Time.Sub(previous))
+			previous = entry.Time
+		}
+		agentID := ""
+		if e, ok := entry.Decoded.(events.AgentEvent); ok {
+			agentID = e.GetAgentID()
+		}
+		fmt.Printf("%9.3fs [%s] %s\n", entry.Time.Sub(start).Seconds(), agentID, line)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"agentlauncher/launcher"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func runRun(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.Usage = func() {
+		flags.Output().Write([]byte("Usage: agentlauncher run [flags] [task]\n\nThe task defaults to the arguments, or stdin if there are none.\n\n"))
+		flags.PrintDefaults()
+	}
+	task := flags.String("task", "", "task to run")
+	approve := flags.Bool("yes", false, "approve every tool call without asking")
+	quiet := flags.Bool("quiet", false, "only print the answer")
+	timeout := flags.Duration("timeout", 0, "cancel the task after this long")
+	launcherFlags := addLauncherFlags(flags, "warn")
+	flags.Parse(args)
+
+	text := *task
+	if text == "" {
+		text = strings.Join(flags.Args(), " ")
+	}
+	var input <-chan string
+	if text == "" || text == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	} else if isTerminal(os.Stdin) {
+		input = readLines(os.Stdin)
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("no task given")
+	}
+
+	al, _, err := launcherFlags.newLauncher()
+	if err != nil {
+		return err
+	}
+	defer al.Close()
+	if input != nil {
+		al.EnableAskUserTool()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	c := &console{al: al, out: os.Stdout, status: os.Stderr, input: input, approve: *approve, quiet: *quiet}
+	if message, failed := launcher.TaskError(c.runTask(ctx, text, nil)); failed {
+		return errors.New(message)
+	}
+	return nil
+}
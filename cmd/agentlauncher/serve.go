@@ -0,0 +1,51 @@
+package main
+
+import (
+	"agentlauncher/internal/metrics"
+	"agentlauncher/server"
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	withMetrics := flags.Bool("metrics", false, "serve Prometheus metrics on /metrics")
+	launcherFlags := addLauncherFlags(flags, "info")
+	flags.Parse(args)
+
+	al, logger, err := launcherFlags.newLauncher()
+	if err != nil {
+		return err
+	}
+	srv := server.New(al)
+	if *withMetrics {
+		registry := metrics.NewRegistry()
+		al.WithMetrics(registry)
+		srv.Handle("GET /metrics", registry)
+	}
+
+	httpServer := &http.Server{Addr: *addr, Handler: srv}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving", "addr", *addr)
+	if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	srv.Close()
+	al.Close()
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func runTools(args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return errors.New("usage: agentlauncher tools list [flags]")
+	}
+	flags := flag.NewFlagSet("tools list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the schemas as JSON")
+	launcherFlags := addLauncherFlags(flags, "warn")
+	flags.Parse(args[1:])
+
+	al, _, err := launcherFlags.newLauncher()
+	if err != nil {
+		return err
+	}
+	defer al.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	schemas, err := al.ToolSchemas(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(schemas)
+	}
+	for i, schema := range schemas {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(schema.Name)
+		if schema.Description != "" {
+			fmt.Println("  " + strings.ReplaceAll(strings.TrimSpace(schema.Description), "\n", "\n  "))
+		}
+		for _, param := range schema.Parameters {
+			attributes := param.Type
+			if param.Required {
+				attributes += ", required"
+			}
+			if len(param.Enum) > 0 {
+				attributes += ", one of " + strings.Join(param.Enum, "|")
+			}
+			fmt.Printf("  - %s (%s) %s\n", param.Name, attributes, param.Description)
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
 	"context"
 	"encoding/json"
@@ -130,6 +131,9 @@ func MainAgentLLMHandler(messages llminterface.RequestMessageList, tools llminte
 			if param.Type == "array" && param.Items != nil {
 				parameters[param.Name].(map[string]any)["items"] = param.Items
 			}
+			if len(param.Enum) > 0 {
+				parameters[param.Name].(map[string]any)["enum"] = param.Enum
+			}
 		}
 
 		openaiTools[i] = openai.ChatCompletionToolUnionParam{
@@ -155,6 +159,12 @@ func MainAgentLLMHandler(messages llminterface.RequestMessageList, tools llminte
 	if err != nil {
 		panic(err)
 	}
+	eventbus.Emit(events.LLMUsageEvent{
+		AgentID:      agentid,
+		Model:        chatCompletionResponse.Model,
+		InputTokens:  int(chatCompletionResponse.Usage.PromptTokens),
+		OutputTokens: int(chatCompletionResponse.Usage.CompletionTokens),
+	})
 	response := llminterface.ResponseMessageList{}
 	if chatCompletionResponse.Choices[0].Message.Content != "" {
 		response = append(response, llminterface.AssistantMessage{Content: chatCompletionResponse.Choices[0].Message.Content})
@@ -1,16 +1,38 @@
 package main
 
 import (
-	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
 	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"agentlauncher/tui"
 	"context"
+	"log/slog"
+	"os"
 	// "encoding/json"
 )
 
 func NewAgentLauncher() *launcher.AgentLauncher {
-	agentLauncher := launcher.NewAgentLauncher(MainAgentLLMHandler, MainAgentLLMHandler).WithVerboseLevel(eventbus.BASIC)
+	handler := llminterface.LLMHandler(MainAgentLLMHandler)
+	// Set AGENTLAUNCHER_CASSETTE to record a run once and replay it offline afterwards.
+	if path := os.Getenv("AGENTLAUNCHER_CASSETTE"); path != "" {
+		cassette, err := llmtest.NewCassette(path, llmtest.REPLAY_OR_RECORD)
+		if err != nil {
+			panic(err)
+		}
+		handler = cassette.Wrap(handler)
+	}
+	logger := logging.New(logging.Config{
+		Format: logging.TEXT,
+		Level:  slog.LevelInfo,
+		Levels: map[string]slog.Level{logging.EVENTBUS: slog.LevelDebug},
+	})
+	if os.Getenv("AGENTLAUNCHER_TUI") != "" {
+		// Logs would scribble over the terminal UI.
+		logger = logging.Discard()
+	}
+	agentLauncher := launcher.NewAgentLauncher(handler, handler).WithLogger(logger).WithReport()
 	RegisterTools(agentLauncher)
 	RegisterMessageHandlers(agentLauncher)
 	launcher.SubscribeEvent(agentLauncher, func(ctx context.Context, event events.MessagesAddEvent) {
@@ -36,9 +58,19 @@ func main() {
 	iteration := 3
 	results := make(chan string, iteration)
 	agentLauncher := NewAgentLauncher()
+	// Set AGENTLAUNCHER_TUI to watch the agents live instead of reading logs.
+	var ui *tui.TUI
+	if os.Getenv("AGENTLAUNCHER_TUI") != "" {
+		ui = tui.New(agentLauncher)
+	}
+	taskIDs := make([]string, iteration)
+	done := make([]chan struct{}, iteration)
 	for i := 0; i < iteration; i++ {
+		taskIDs[i] = agentLauncher.NewTaskID()
+		done[i] = make(chan struct{})
 		go func() {
-			results <- agentLauncher.Run(`You are to help me organize a virtual conference. Please:
+			defer close(done[i])
+			results <- agentLauncher.RunTask(taskIDs[i], `You are to help me organize a virtual conference. Please:
 	1. Find three suitable dates in the next month for the event.
 	2. Research and suggest two keynote speakers in AI.
 	3. Prepare a draft agenda with at least five sessions.
@@ -50,8 +82,34 @@ func main() {
 		}()
 	}
 
+	if ui != nil {
+		if err := ui.Run(context.Background()); err != nil {
+			panic(err)
+		}
+		// Quitting the UI gives up on the tasks still running.
+		for i, taskID := range taskIDs {
+			select {
+			case <-done[i]:
+			default:
+				agentLauncher.Cancel(taskID, "")
+			}
+		}
+	}
+
 	for range iteration {
 		<-results
 		// fmt.Println("Final Result:\n", result)
 	}
+
+	// Set AGENTLAUNCHER_REPORT to write an HTML timeline of the run.
+	if path := os.Getenv("AGENTLAUNCHER_REPORT"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		if err := agentLauncher.Report().WriteHTML(file); err != nil {
+			panic(err)
+		}
+	}
 }
@@ -1,19 +1,15 @@
 package eventbus
 
 import (
+	"agentlauncher/internal/logging"
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"reflect"
 	"runtime"
 	"sync"
-)
-
-type VerboseLevel int
-
-const (
-	SILENT VerboseLevel = iota
-	BASIC
-	DETAILED
+	"sync/atomic"
+	"time"
 )
 
 type CompiledHandler interface {
@@ -30,41 +26,126 @@ func (ch *compiledHandler[T]) Call(ctx context.Context, event Event) {
 	}
 }
 
+type matchingHandler[I any] struct {
+	handler func(context.Context, I)
+}
+
+func (mh *matchingHandler[I]) Call(ctx context.Context, event Event) {
+	if matched, ok := event.(I); ok {
+		mh.handler(ctx, matched)
+	}
+}
+
+type Subscription struct {
+	eb        *EventBus
+	id        uint64
+	eventType reflect.Type
+	match     func(Event) bool
+	handler   CompiledHandler
+	name      string
+	closed    atomic.Bool
+	onClose   []func()
+}
+
+func (s *Subscription) Unsubscribe() {
+	if s.closed.Swap(true) {
+		return
+	}
+	s.eb.unsubscribe(s)
+	for _, fn := range s.onClose {
+		fn()
+	}
+}
+
+func (s *Subscription) Active() bool {
+	return !s.closed.Load()
+}
+
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// emitted is an event waiting in the queue with the context it was emitted
+// with. Remote events are not published again.
+type emitted struct {
+	ctx    context.Context
+	event  Event
+	remote bool
+}
+
 type work struct {
-	event   Event
-	handler CompiledHandler
+	ctx          context.Context
+	event        Event
+	subscription *Subscription
+	// partition is set instead to drain the queue of a partition.
+	partition string
 }
 
 type EventBus struct {
-	handlerMap map[reflect.Type][]CompiledHandler
+	handlerMap map[reflect.Type][]*Subscription
+	matchers   []*Subscription
+	handlerMu  sync.RWMutex
+	nextID     atomic.Uint64
+
+	interceptors      []*Interceptor
+	middlewares       []HandlerMiddleware
+	transportContexts []TransportContext
+
+	eventQueue  chan emitted
+	workerPool  chan work
+	backlog     []work
+	maxBacklog  int
+	backlogSize atomic.Int64
+	busyWorkers atomic.Int64
 
-	eventQueue chan Event
-	workerPool chan work
+	runningHandlers atomic.Int64
+
+	partitionKey func(Event) string
+	partitions   map[string]*partition
+	partitionMu  sync.Mutex
+
+	deadLetters DeadLetterSink
+	retry       RetryPolicy
+	transport   Transport
 
 	numWorkers int
 	ctx        context.Context
 	cancel     context.CancelFunc
 	wg         sync.WaitGroup
 
-	verbose VerboseLevel
-	logger  *log.Logger
+	logger atomic.Pointer[slog.Logger]
+
+	// parent is the bus a scope forwards to, scoped its subscriptions.
+	parent *EventBus
+	scoped []*Subscription
 }
 
-func NewEventBus() *EventBus {
-	bufferSize := 100
-	numWorkers := runtime.NumCPU() * 2
+func NewEventBus(opts ...Option) *EventBus {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 
 	eb := &EventBus{
-		handlerMap: make(map[reflect.Type][]CompiledHandler),
-		eventQueue: make(chan Event, bufferSize),
-		workerPool: make(chan work, bufferSize*2),
-		numWorkers: numWorkers,
-		ctx:        ctx,
-		cancel:     cancel,
-		verbose:    SILENT,
-		logger:     log.New(log.Writer(), "EventBus: ", log.LstdFlags),
+		handlerMap:  make(map[reflect.Type][]*Subscription),
+		eventQueue:  make(chan emitted, cfg.queueSize),
+		workerPool:  make(chan work, cfg.workerPoolSize),
+		maxBacklog:  cfg.maxBacklog,
+		partitions:  make(map[string]*partition),
+		deadLetters: cfg.deadLetters,
+		retry:       cfg.retry,
+		transport:   cfg.transport,
+		numWorkers:  cfg.numWorkers,
+		ctx:         ctx,
+		cancel:      cancel,
 	}
+	eb.logger.Store(logging.Discard())
+
+	eb.Use(func(ctx context.Context, event Event) (Event, bool) {
+		logEvent(ctx, eb.logger.Load(), event)
+		return event, true
+	})
 
 	eb.wg.Add(1)
 	go eb.dispatcher()
@@ -74,33 +155,223 @@ func NewEventBus() *EventBus {
 		go eb.worker()
 	}
 
+	if eb.transport != nil {
+		eb.wg.Add(1)
+		go eb.receive()
+	}
+
 	return eb
 }
 
-func (eb *EventBus) WithVerboseLevel(level VerboseLevel) {
-	eb.verbose = level
+// WithLogger logs every dispatched event at debug level, with its payload at
+// logging.LevelTrace.
+func (eb *EventBus) WithLogger(logger *slog.Logger) {
+	eb.logger.Store(logging.Subsystem(logger, logging.EVENTBUS))
 }
 
-func Subscribe[T Event](eb *EventBus, handler func(context.Context, T)) {
+func Subscribe[T Event](eb *EventBus, handler func(context.Context, T)) *Subscription {
 	var zero T
-	eventType := reflect.TypeOf(zero)
+	sub := &Subscription{
+		eb:        eb,
+		id:        eb.nextID.Add(1),
+		eventType: reflect.TypeOf(zero),
+		handler:   &compiledHandler[T]{handler: handler},
+		name:      handlerName(handler),
+	}
+	eb.subscribe(sub)
+	return sub
+}
+
+// SubscribeUntil registers handler until an event of type S satisfying until
+// is dispatched, then removes the subscription.
+func SubscribeUntil[T Event, S Event](eb *EventBus, handler func(context.Context, T), until func(S) bool) *Subscription {
+	sub := Subscribe(eb, handler)
+	stop := Subscribe(eb, func(ctx context.Context, e S) {
+		if until(e) {
+			sub.Unsubscribe()
+		}
+	})
+	sub.onClose = append(sub.onClose, stop.Unsubscribe)
+	return sub
+}
+
+func SubscribeAll(eb *EventBus, handler func(context.Context, Event)) *Subscription {
+	return SubscribeWhere(eb, func(Event) bool { return true }, handler)
+}
+
+func SubscribeWhere(eb *EventBus, predicate func(Event) bool, handler func(context.Context, Event)) *Subscription {
+	sub := &Subscription{
+		eb:      eb,
+		id:      eb.nextID.Add(1),
+		match:   predicate,
+		handler: &compiledHandler[Event]{handler: handler},
+		name:    handlerName(handler),
+	}
+	eb.subscribe(sub)
+	return sub
+}
+
+// SubscribeMatching delivers every event whose type implements the interface I.
+func SubscribeMatching[I any](eb *EventBus, handler func(context.Context, I)) *Subscription {
+	if reflect.TypeFor[I]().Kind() != reflect.Interface {
+		panic("SubscribeMatching requires an interface type, got " + reflect.TypeFor[I]().String())
+	}
+	sub := &Subscription{
+		eb: eb,
+		id: eb.nextID.Add(1),
+		match: func(e Event) bool {
+			_, ok := e.(I)
+			return ok
+		},
+		handler: &matchingHandler[I]{handler: handler},
+		name:    handlerName(handler),
+	}
+	eb.subscribe(sub)
+	return sub
+}
 
-	compiled := &compiledHandler[T]{handler: handler}
-	eb.handlerMap[eventType] = append(eb.handlerMap[eventType], compiled)
+func (eb *EventBus) subscribe(sub *Subscription) {
+	if eb.parent != nil {
+		eb.scopeSubscribe(sub)
+		return
+	}
+	eb.handlerMu.Lock()
+	defer eb.handlerMu.Unlock()
+	if sub.match != nil {
+		eb.matchers = append(eb.matchers, sub)
+		return
+	}
+	eb.handlerMap[sub.eventType] = append(eb.handlerMap[sub.eventType], sub)
 }
 
+func (eb *EventBus) unsubscribe(sub *Subscription) {
+	if eb.parent != nil {
+		eb.scopeUnsubscribe(sub)
+		return
+	}
+	eb.handlerMu.Lock()
+	defer eb.handlerMu.Unlock()
+	if sub.match != nil {
+		eb.matchers = without(eb.matchers, sub)
+		return
+	}
+	eb.handlerMap[sub.eventType] = without(eb.handlerMap[sub.eventType], sub)
+	if len(eb.handlerMap[sub.eventType]) == 0 {
+		delete(eb.handlerMap, sub.eventType)
+	}
+}
+
+// without copies on write, dispatchers may still be iterating the old slice.
+func without(subs []*Subscription, sub *Subscription) []*Subscription {
+	remaining := make([]*Subscription, 0, len(subs))
+	for _, s := range subs {
+		if s != sub {
+			remaining = append(remaining, s)
+		}
+	}
+	return remaining
+}
+
+func (eb *EventBus) handlersFor(event Event) []*Subscription {
+	eb.handlerMu.RLock()
+	subs := eb.handlerMap[reflect.TypeOf(event)]
+	matchers := eb.matchers
+	eb.handlerMu.RUnlock()
+
+	if len(matchers) == 0 {
+		return subs
+	}
+	matched := append([]*Subscription{}, subs...)
+	for _, sub := range matchers {
+		if sub.match(event) {
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+func handlerName(handler any) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return reflect.TypeOf(handler).String()
+}
+
+// Emit blocks while the event queue is full. Events emitted after Shutdown are dropped.
 func (eb *EventBus) Emit(event Event) {
-	eb.eventQueue <- event
-	eb.log(event)
+	eb.EmitContext(context.Background(), event)
 }
 
+// EmitContext waits for room in the queue until ctx is done. Handlers of the
+// event are called with ctx, which they also see cancelled once the bus is
+// shut down.
+func (eb *EventBus) EmitContext(ctx context.Context, event Event) error {
+	if eb.parent != nil {
+		if eb.ctx.Err() != nil {
+			return ErrBusClosed
+		}
+		return eb.parent.EmitContext(ctx, event)
+	}
+	return eb.enqueue(emitted{ctx: ctx, event: event})
+}
+
+func (eb *EventBus) enqueue(e emitted) error {
+	if eb.ctx.Err() != nil {
+		return ErrBusClosed
+	}
+	select {
+	case eb.eventQueue <- e:
+		return nil
+	case <-e.ctx.Done():
+		return e.ctx.Err()
+	case <-eb.ctx.Done():
+		return ErrBusClosed
+	}
+}
+
+// TryEmit fails with ErrQueueFull instead of waiting for room in the queue.
+func (eb *EventBus) TryEmit(event Event) error {
+	if eb.ctx.Err() != nil {
+		return ErrBusClosed
+	}
+	if eb.parent != nil {
+		return eb.parent.TryEmit(event)
+	}
+	select {
+	case eb.eventQueue <- emitted{ctx: context.Background(), event: event}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// The dispatcher never blocks on the worker pool: work that does not fit is
+// kept in a backlog, so the event queue keeps draining even when every worker
+// is itself blocked emitting a new event. Once the backlog is full the
+// dispatcher stops taking events, the queue fills up and emitters wait.
 func (eb *EventBus) dispatcher() {
 	defer eb.wg.Done()
 
 	for {
+		var pool chan work
+		var next work
+		if len(eb.backlog) > 0 {
+			pool = eb.workerPool
+			next = eb.backlog[0]
+		}
+		queue := eb.eventQueue
+		if len(eb.backlog) >= eb.maxBacklog {
+			queue = nil
+		}
+
 		select {
-		case event := <-eb.eventQueue:
-			eb.dispatchEvent(event)
+		case e := <-queue:
+			eb.dispatchEvent(e)
+
+		case pool <- next:
+			eb.backlog[0] = work{}
+			eb.backlog = eb.backlog[1:]
+			eb.backlogSize.Store(int64(len(eb.backlog)))
 
 		case <-eb.ctx.Done():
 			eb.drainEvents()
@@ -109,13 +380,123 @@ func (eb *EventBus) dispatcher() {
 	}
 }
 
-func (eb *EventBus) dispatchEvent(event Event) {
-	eventType := reflect.TypeOf(event)
+// dispatchEvent also publishes local events to the transport, after the
+// interceptors, so they leave the process as dispatched here and in the
+// same order.
+func (eb *EventBus) dispatchEvent(e emitted) {
+	ctx := e.ctx
+	interceptContext := ctx
+	if e.remote {
+		interceptContext = context.WithValue(ctx, remoteKey{}, true)
+	}
+	event, keep := eb.intercept(interceptContext, e.event)
+	if !keep {
+		return
+	}
+	if !e.remote {
+		eb.publish(ctx, event)
+	}
 
-	handlers := eb.handlerMap[eventType]
+	subs := eb.handlersFor(event)
+	if len(subs) == 0 {
+		eb.deadLetter(DeadLetter{Event: event, Reason: NO_SUBSCRIBERS})
+		return
+	}
 
-	for _, handler := range handlers {
-		eb.workerPool <- work{event: event, handler: handler}
+	if key := eb.partitionFor(event); key != "" {
+		works := make([]work, len(subs))
+		for i, sub := range subs {
+			works[i] = work{ctx: ctx, event: event, subscription: sub}
+		}
+		eb.enqueuePartition(key, works)
+		return
+	}
+
+	for _, sub := range subs {
+		eb.submit(work{ctx: ctx, event: event, subscription: sub})
+	}
+}
+
+// submit hands w to an idle worker, or keeps it in the backlog behind the
+// work already waiting. Only the dispatcher calls it.
+func (eb *EventBus) submit(w work) {
+	if len(eb.backlog) == 0 {
+		select {
+		case eb.workerPool <- w:
+			return
+		default:
+		}
+	}
+	eb.backlog = append(eb.backlog, w)
+	eb.backlogSize.Store(int64(len(eb.backlog)))
+}
+
+func (eb *EventBus) call(w work) {
+	if !w.subscription.Active() {
+		return
+	}
+	handler := eb.wrap(w.subscription)
+	eb.runningHandlers.Add(1)
+	defer eb.runningHandlers.Add(-1)
+	ctx, cancel := eb.handlerContext(w.ctx)
+	defer cancel()
+
+	// Without a sink or retry policy a panicking handler crashes as before.
+	if eb.deadLetters == nil && eb.retry.MaxRetries <= 0 {
+		handler(ctx, w.event)
+		return
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := invoke(handler, ctx, w.event)
+		if err == nil {
+			return
+		}
+		if attempt > eb.retry.MaxRetries || !eb.sleep(eb.retry.Delay(attempt)) || !w.subscription.Active() {
+			if eb.deadLetters == nil {
+				eb.dropFailed(w, attempt, err)
+				return
+			}
+			eb.deadLetter(DeadLetter{
+				Event:    w.event,
+				Reason:   HANDLER_FAILED,
+				Handler:  w.subscription.name,
+				Attempts: attempt,
+				Err:      err,
+			})
+			return
+		}
+	}
+}
+
+// dropFailed logs a handler call that failed for good on a bus without a
+// dead letter sink.
+func (eb *EventBus) dropFailed(w work, attempts int, err error) {
+	attrs := []any{
+		"handler", w.subscription.name,
+		"event_type", reflect.TypeOf(w.event).Name(),
+		"agent_id", AgentIDOf(w.event),
+		"attempts", attempts,
+		"error", err,
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		attrs = append(attrs, "stack", string(panicErr.Stack))
+	}
+	eb.logger.Load().Error("handler failed, event dropped", attrs...)
+}
+
+func (eb *EventBus) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return eb.ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-eb.ctx.Done():
+		return false
 	}
 }
 
@@ -125,7 +506,13 @@ func (eb *EventBus) worker() {
 	for {
 		select {
 		case w := <-eb.workerPool:
-			w.handler.Call(eb.ctx, w.event)
+			eb.busyWorkers.Add(1)
+			if w.partition != "" {
+				eb.drainPartition(w.partition)
+			} else {
+				eb.call(w)
+			}
+			eb.busyWorkers.Add(-1)
 
 		case <-eb.ctx.Done():
 			return
@@ -136,8 +523,8 @@ func (eb *EventBus) worker() {
 func (eb *EventBus) drainEvents() {
 	for {
 		select {
-		case event := <-eb.eventQueue:
-			eb.dispatchEvent(event)
+		case e := <-eb.eventQueue:
+			eb.dispatchEvent(e)
 		default:
 			return
 		}
@@ -145,7 +532,14 @@ func (eb *EventBus) drainEvents() {
 }
 
 func (eb *EventBus) Shutdown(ctx context.Context) error {
+	if eb.parent != nil {
+		eb.shutdownScope()
+		return nil
+	}
 	eb.cancel()
+	if eb.transport != nil {
+		eb.transport.Close()
+	}
 
 	done := make(chan struct{})
 	go func() {
@@ -161,26 +555,17 @@ func (eb *EventBus) Shutdown(ctx context.Context) error {
 	}
 }
 
-func (eb *EventBus) log(event Event) {
-	if eb.verbose == SILENT {
-		return
+func AgentIDOf(event Event) string {
+	if agentEvent, ok := event.(interface{ GetAgentID() string }); ok {
+		return agentEvent.GetAgentID()
 	}
-
-	agentID := ""
 	val := reflect.ValueOf(event)
+	if val.Kind() != reflect.Struct {
+		return ""
+	}
 	field := val.FieldByName("AgentID")
 	if field.IsValid() && field.Kind() == reflect.String {
-		agentID = field.String()
-	}
-
-	eventType := reflect.TypeOf(event).Name()
-
-	if eb.verbose == BASIC {
-		eb.logger.Printf("[%s] Event emitted: %s", agentID, eventType)
-		return
+		return field.String()
 	}
-
-	eb.logger.Printf("----- Event emitted: %s -----", eventType)
-	eb.logger.Printf("%+v\n", event)
-	eb.logger.Println("-------------------------")
+	return ""
 }
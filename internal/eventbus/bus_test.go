@@ -0,0 +1,229 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ping struct {
+	eventbus.BaseEvent
+	AgentID string
+	N       int
+}
+
+type pong struct {
+	eventbus.BaseEvent
+	AgentID string
+	N       int
+}
+
+func newBus(t *testing.T, opts ...eventbus.Option) *eventbus.EventBus {
+	t.Helper()
+	eb := eventbus.NewEventBus(opts...)
+	t.Cleanup(func() { eb.Shutdown(context.Background()) })
+	return eb
+}
+
+// receive returns the next value of ch, failing the test after a second.
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+		var zero T
+		return zero
+	}
+}
+
+// expectNone fails the test if ch receives a value within 50ms.
+func expectNone[T any](t *testing.T, ch <-chan T) {
+	t.Helper()
+	select {
+	case v := <-ch:
+		t.Fatalf("unexpected %v", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan int, 10)
+	sub := eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+	other := make(chan int, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { other <- e.N })
+
+	if !sub.Active() {
+		t.Fatal("expected a new subscription to be active")
+	}
+	eb.Emit(ping{N: 1})
+	receive(t, received)
+	receive(t, other)
+
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	if sub.Active() {
+		t.Error("expected the subscription to be inactive")
+	}
+	eb.Emit(ping{N: 2})
+	if n := receive(t, other); n != 2 {
+		t.Errorf("expected the other handler to keep receiving, got %d", n)
+	}
+	expectNone(t, received)
+}
+
+func TestUnsubscribeQueuedEvents(t *testing.T) {
+	eb := newBus(t, eventbus.WithWorkers(1))
+	block := make(chan struct{})
+	eventbus.Subscribe(eb, func(ctx context.Context, e pong) { <-block })
+	received := make(chan int, 10)
+	sub := eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+
+	// The only worker is busy, the ping waits in the queue.
+	eb.Emit(pong{})
+	eb.Emit(ping{N: 1})
+	sub.Unsubscribe()
+	close(block)
+	expectNone(t, received)
+}
+
+func TestSubscribeUntil(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan int, 10)
+	sub := eventbus.SubscribeUntil(eb, func(ctx context.Context, e ping) { received <- e.N }, func(e pong) bool {
+		return e.N == 2
+	})
+
+	eb.Emit(ping{N: 1})
+	receive(t, received)
+	eb.Emit(pong{N: 1})
+	eb.Emit(ping{N: 2})
+	if n := receive(t, received); n != 2 {
+		t.Fatalf("expected a non-matching stop event to be ignored, got %d", n)
+	}
+
+	eb.Emit(pong{N: 2})
+	deadline := time.Now().Add(time.Second)
+	for sub.Active() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the subscription to end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	eb.Emit(ping{N: 3})
+	expectNone(t, received)
+}
+
+func TestUnsubscribeEndsSubscribeUntil(t *testing.T) {
+	eb := newBus(t)
+	stops := make(chan bool, 10)
+	sub := eventbus.SubscribeUntil(eb, func(ctx context.Context, e ping) {}, func(e pong) bool {
+		stops <- true
+		return false
+	})
+
+	sub.Unsubscribe()
+	eb.Emit(pong{})
+	expectNone(t, stops)
+}
+
+func TestFullBusRejectsEmits(t *testing.T) {
+	eb := newBus(t, eventbus.WithWorkers(1), eventbus.WithWorkerPoolSize(1), eventbus.WithMaxBacklog(1), eventbus.WithQueueSize(1))
+	release := make(chan struct{})
+	var handled atomic.Int32
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		<-release
+		handled.Add(1)
+	})
+
+	// Fill the worker, the pool, the backlog and the queue.
+	emitted := 0
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := eb.TryEmit(ping{N: emitted})
+		if errors.Is(err, eventbus.ErrQueueFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		emitted++
+		if time.Now().After(deadline) {
+			close(release)
+			t.Fatalf("TryEmit still succeeds after %d events", emitted)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := eb.EmitContext(ctx, ping{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected EmitContext to wait until its context is done, got %v", err)
+	}
+
+	close(release)
+	for handled.Load() != int32(emitted) {
+		if time.Now().After(deadline.Add(time.Second)) {
+			t.Fatalf("expected the %d accepted events to be handled, got %d", emitted, handled.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type ctxKey struct{}
+
+func TestHandlersGetTheEmitterContext(t *testing.T) {
+	eb := newBus(t)
+	values := make(chan any, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { values <- ctx.Value(ctxKey{}) })
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "traced")
+	if err := eb.EmitContext(ctx, ping{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := receive(t, values); v != "traced" {
+		t.Errorf("expected the emitter's value, got %v", v)
+	}
+}
+
+func TestHandlerContextEndsWithEmitter(t *testing.T) {
+	eb := newBus(t)
+	done := make(chan error, 1)
+	started := make(chan struct{})
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eb.EmitContext(ctx, ping{})
+	<-started
+	cancel()
+	if err := receive(t, done); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the handler's context to be cancelled, got %v", err)
+	}
+}
+
+func TestHandlerContextEndsWithBus(t *testing.T) {
+	eb := eventbus.NewEventBus()
+	done := make(chan error, 1)
+	started := make(chan struct{})
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+
+	eb.Emit(ping{})
+	<-started
+	eb.Shutdown(context.Background())
+	if err := receive(t, done); err == nil {
+		t.Error("expected the handler's context to end with the bus")
+	}
+}
@@ -0,0 +1,30 @@
+package eventbus
+
+import "context"
+
+// valuesOf is ctx with the values of another context.
+type valuesOf struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesOf) Value(key any) any {
+	return c.values.Value(key)
+}
+
+// handlerContext returns the context a handler is called with: the context
+// the event was emitted with, also cancelled when the bus shuts down.
+func (eb *EventBus) handlerContext(emitted context.Context) (context.Context, context.CancelFunc) {
+	if emitted == nil {
+		return eb.ctx, func() {}
+	}
+	if emitted.Done() == nil {
+		return valuesOf{Context: eb.ctx, values: emitted}, func() {}
+	}
+	ctx, cancel := context.WithCancel(emitted)
+	stop := context.AfterFunc(eb.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
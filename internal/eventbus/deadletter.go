@@ -0,0 +1,109 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type DeadLetterReason int
+
+const (
+	NO_SUBSCRIBERS DeadLetterReason = iota
+	HANDLER_FAILED
+	TRANSPORT_FAILED
+)
+
+func (r DeadLetterReason) String() string {
+	switch r {
+	case NO_SUBSCRIBERS:
+		return "no_subscribers"
+	case HANDLER_FAILED:
+		return "handler_failed"
+	case TRANSPORT_FAILED:
+		return "transport_failed"
+	}
+	return fmt.Sprintf("DeadLetterReason(%d)", int(r))
+}
+
+type DeadLetter struct {
+	Event    Event
+	Reason   DeadLetterReason
+	Handler  string
+	Attempts int
+	Err      error
+	Time     time.Time
+}
+
+type DeadLetterSink func(DeadLetter)
+
+type DeadLetterQueue struct {
+	letters []DeadLetter
+	limit   int
+	mu      sync.Mutex
+}
+
+// NewDeadLetterQueue keeps the most recent limit dead letters, or all of them
+// when limit is zero.
+func NewDeadLetterQueue(limit int) *DeadLetterQueue {
+	return &DeadLetterQueue{limit: limit}
+}
+
+func (q *DeadLetterQueue) Put(letter DeadLetter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters = append(q.letters, letter)
+	if q.limit > 0 && len(q.letters) > q.limit {
+		q.letters = append([]DeadLetter{}, q.letters[len(q.letters)-q.limit:]...)
+	}
+}
+
+func (q *DeadLetterQueue) Letters() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]DeadLetter{}, q.letters...)
+}
+
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.letters)
+}
+
+func (eb *EventBus) deadLetter(letter DeadLetter) {
+	if eb.deadLetters == nil {
+		return
+	}
+	letter.Time = time.Now()
+	eb.deadLetters(letter)
+}
+
+// PanicError is the error of a handler call that panicked, with the stack
+// of the panic.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// invoke runs handler and turns a panic into an error, so it can be retried
+// or dead-lettered instead of taking the process down.
+func invoke(handler HandlerFunc, ctx context.Context, event Event) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	handler(ctx, event)
+	return nil
+}
@@ -0,0 +1,144 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUnhandledEventIsDeadLettered(t *testing.T) {
+	queue := eventbus.NewDeadLetterQueue(0)
+	eb := newBus(t, eventbus.WithDeadLetters(queue.Put))
+	handled := make(chan int, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { handled <- e.N })
+
+	eb.Emit(pong{N: 1})
+	eb.Emit(ping{N: 2})
+	receive(t, handled)
+	waitForLetters(t, queue, 1)
+
+	letter := queue.Letters()[0]
+	if letter.Reason != eventbus.NO_SUBSCRIBERS || letter.Event != (pong{N: 1}) {
+		t.Errorf("expected the pong without subscribers, got %+v", letter)
+	}
+	if letter.Time.IsZero() {
+		t.Error("expected the dead letter to be timestamped")
+	}
+}
+
+func TestRetryExhaustion(t *testing.T) {
+	queue := eventbus.NewDeadLetterQueue(0)
+	eb := newBus(t, eventbus.WithDeadLetters(queue.Put), eventbus.WithRetryPolicy(eventbus.RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}))
+	var calls atomic.Int32
+	boom := errors.New("boom")
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		calls.Add(1)
+		panic(boom)
+	})
+
+	eb.Emit(ping{})
+	waitForLetters(t, queue, 1)
+
+	letter := queue.Letters()[0]
+	if letter.Reason != eventbus.HANDLER_FAILED || letter.Attempts != 3 || calls.Load() != 3 {
+		t.Errorf("expected 3 attempts before dead-lettering, got %+v after %d calls", letter, calls.Load())
+	}
+	if !errors.Is(letter.Err, boom) {
+		t.Errorf("expected the panic value to be wrapped, got %v", letter.Err)
+	}
+	var panicErr *eventbus.PanicError
+	if !errors.As(letter.Err, &panicErr) || !strings.Contains(string(panicErr.Stack), "TestRetryExhaustion") {
+		t.Errorf("expected the stack of the panic, got %v", letter.Err)
+	}
+	if !strings.Contains(letter.Handler, "TestRetryExhaustion") {
+		t.Errorf("expected the handler's name, got %q", letter.Handler)
+	}
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	queue := eventbus.NewDeadLetterQueue(0)
+	eb := newBus(t, eventbus.WithDeadLetters(queue.Put), eventbus.WithRetryPolicy(eventbus.RetryPolicy{MaxRetries: 3}))
+	var calls atomic.Int32
+	handled := make(chan int32, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		if n := calls.Add(1); n < 3 {
+			panic("flaky")
+		} else {
+			handled <- n
+		}
+	})
+
+	eb.Emit(ping{})
+	if n := receive(t, handled); n != 3 {
+		t.Errorf("expected the third attempt to succeed, got %d", n)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("expected no dead letters, got %+v", queue.Letters())
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the bus's workers to log to.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRetryWithoutSinkLogsAndDrops(t *testing.T) {
+	eb := newBus(t, eventbus.WithRetryPolicy(eventbus.RetryPolicy{MaxRetries: 1}))
+	var logs syncBuffer
+	eb.WithLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		if e.N == 1 {
+			panic("boom")
+		}
+	})
+	handled := make(chan int, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e pong) { handled <- e.N })
+
+	eb.Emit(ping{N: 1})
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(logs.String(), "event dropped") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the failure to be logged, got %q", logs.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if out := logs.String(); !strings.Contains(out, "attempts=2") || !strings.Contains(out, "TestRetryWithoutSinkLogsAndDrops") {
+		t.Errorf("expected the attempts and the stack, got %q", out)
+	}
+
+	eb.Emit(pong{N: 2})
+	if n := receive(t, handled); n != 2 {
+		t.Errorf("expected the bus to keep working, got %d", n)
+	}
+}
+
+func waitForLetters(t *testing.T, queue *eventbus.DeadLetterQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for queue.Len() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d dead letters, got %d", n, queue.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
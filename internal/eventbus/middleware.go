@@ -0,0 +1,125 @@
+package eventbus
+
+import (
+	"agentlauncher/internal/logging"
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// Interceptor sees every event before it is dispatched. It may return a
+// different event to dispatch instead, or false to drop the event.
+type Interceptor func(ctx context.Context, event Event) (Event, bool)
+
+type HandlerFunc func(ctx context.Context, event Event)
+
+// HandlerMiddleware wraps each handler call. name identifies the handler.
+type HandlerMiddleware func(name string, next HandlerFunc) HandlerFunc
+
+func (eb *EventBus) Use(interceptors ...Interceptor) {
+	eb.handlerMu.Lock()
+	defer eb.handlerMu.Unlock()
+	installed := slices.Clone(eb.interceptors)
+	for i := range interceptors {
+		installed = append(installed, &interceptors[i])
+	}
+	eb.interceptors = installed
+}
+
+// Intercept installs interceptor like Use and returns a function that
+// removes it again.
+func (eb *EventBus) Intercept(interceptor Interceptor) (remove func()) {
+	entry := &interceptor
+	eb.handlerMu.Lock()
+	eb.interceptors = append(slices.Clone(eb.interceptors), entry)
+	eb.handlerMu.Unlock()
+	return func() {
+		eb.handlerMu.Lock()
+		defer eb.handlerMu.Unlock()
+		eb.interceptors = slices.DeleteFunc(slices.Clone(eb.interceptors), func(installed *Interceptor) bool {
+			return installed == entry
+		})
+	}
+}
+
+func (eb *EventBus) WrapHandlers(middlewares ...HandlerMiddleware) {
+	eb.handlerMu.Lock()
+	defer eb.handlerMu.Unlock()
+	eb.middlewares = append(append([]HandlerMiddleware{}, eb.middlewares...), middlewares...)
+}
+
+func (eb *EventBus) intercept(ctx context.Context, event Event) (Event, bool) {
+	eb.handlerMu.RLock()
+	interceptors := eb.interceptors
+	eb.handlerMu.RUnlock()
+
+	ctx = valuesOf{Context: eb.ctx, values: ctx}
+	for _, interceptor := range interceptors {
+		var keep bool
+		if event, keep = (*interceptor)(ctx, event); !keep || event == nil {
+			return nil, false
+		}
+	}
+	return event, true
+}
+
+func (eb *EventBus) wrap(sub *Subscription) HandlerFunc {
+	eb.handlerMu.RLock()
+	middlewares := eb.middlewares
+	eb.handlerMu.RUnlock()
+
+	handler := HandlerFunc(sub.handler.Call)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](sub.name, handler)
+	}
+	return handler
+}
+
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	return func(ctx context.Context, event Event) (Event, bool) {
+		logEvent(ctx, logger, event)
+		return event, true
+	}
+}
+
+func RecoverMiddleware(onPanic func(name string, event Event, recovered any)) HandlerMiddleware {
+	return func(name string, next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) {
+			defer func() {
+				if p := recover(); p != nil {
+					onPanic(name, event, p)
+				}
+			}()
+			next(ctx, event)
+		}
+	}
+}
+
+func TimingMiddleware(observe func(name string, event Event, duration time.Duration)) HandlerMiddleware {
+	return func(name string, next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event Event) {
+			start := time.Now()
+			next(ctx, event)
+			observe(name, event, time.Since(start))
+		}
+	}
+}
+
+func logEvent(ctx context.Context, logger *slog.Logger, event Event) {
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("event_type", reflect.TypeOf(event).Name()),
+		slog.String("agent_id", AgentIDOf(event)),
+	}
+	level := slog.LevelDebug
+	if logger.Enabled(ctx, logging.LevelTrace) {
+		level = logging.LevelTrace
+		attrs = append(attrs, slog.Any("event", event))
+	}
+	logger.LogAttrs(ctx, level, "event dispatched", attrs...)
+}
@@ -0,0 +1,166 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInterceptorsRunInOrder(t *testing.T) {
+	eb := newBus(t)
+	var mu sync.Mutex
+	var order []string
+	step := func(name string, add int) eventbus.Interceptor {
+		return func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			if p, ok := event.(ping); ok {
+				p.N = p.N*10 + add
+				return p, true
+			}
+			return event, true
+		}
+	}
+	eb.Use(step("first", 1), step("second", 2))
+	eb.Intercept(step("third", 3))
+
+	received := make(chan int, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+	eb.Emit(ping{})
+
+	if n := receive(t, received); n != 123 {
+		t.Errorf("expected each interceptor to see the event of the previous one, got %d", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(order, []string{"first", "second", "third"}) {
+		t.Errorf("expected interceptors in installation order, got %v", order)
+	}
+}
+
+func TestInterceptorShortCircuits(t *testing.T) {
+	eb := newBus(t)
+	calls := make(chan string, 10)
+	eb.Use(func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		if p, ok := event.(ping); ok && p.N == 1 {
+			return nil, false
+		}
+		return event, true
+	}, func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		calls <- "later"
+		return event, true
+	})
+
+	received := make(chan int, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+	eb.Emit(ping{N: 1})
+	eb.Emit(ping{N: 2})
+
+	if n := receive(t, received); n != 2 {
+		t.Errorf("expected the dropped event not to reach handlers, got %d", n)
+	}
+	// Events are intercepted one at a time, the dropped one went first.
+	if len(calls) != 1 {
+		t.Errorf("expected later interceptors to be skipped for the dropped event, got %d calls", len(calls))
+	}
+}
+
+func TestInterceptReturnsRemove(t *testing.T) {
+	eb := newBus(t)
+	seen := make(chan int, 10)
+	remove := eb.Intercept(func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		if p, ok := event.(ping); ok {
+			seen <- p.N
+		}
+		return event, true
+	})
+	received := make(chan int, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+
+	eb.Emit(ping{N: 1})
+	receive(t, seen)
+	receive(t, received)
+
+	remove()
+	eb.Emit(ping{N: 2})
+	receive(t, received)
+	expectNone(t, seen)
+}
+
+func TestMiddlewaresWrapHandlers(t *testing.T) {
+	eb := newBus(t)
+	var mu sync.Mutex
+	var order []string
+	record := func(entry string) {
+		mu.Lock()
+		order = append(order, entry)
+		mu.Unlock()
+	}
+	layer := func(label string) eventbus.HandlerMiddleware {
+		return func(name string, next eventbus.HandlerFunc) eventbus.HandlerFunc {
+			return func(ctx context.Context, event eventbus.Event) {
+				record(label + " before")
+				next(ctx, event)
+				record(label + " after")
+			}
+		}
+	}
+	eb.WrapHandlers(layer("outer"), layer("inner"))
+
+	var names []string
+	timed := make(chan time.Duration, 1)
+	eb.WrapHandlers(eventbus.TimingMiddleware(func(name string, event eventbus.Event, duration time.Duration) {
+		names = append(names, name)
+		timed <- duration
+	}))
+	eventbus.Subscribe(eb, handlePing(record))
+
+	eb.Emit(ping{})
+	if d := receive(t, timed); d < 10*time.Millisecond {
+		t.Errorf("expected the handler's duration, got %s", d)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if expected := []string{"outer before", "inner before", "handler", "inner after", "outer after"}; !slices.Equal(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+	if len(names) != 1 || !strings.HasSuffix(names[0], "handlePing.func1") {
+		t.Errorf("expected the handler's name, got %v", names)
+	}
+}
+
+func handlePing(record func(string)) func(context.Context, ping) {
+	return func(ctx context.Context, e ping) {
+		time.Sleep(10 * time.Millisecond)
+		record("handler")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	eb := newBus(t)
+	recovered := make(chan any, 1)
+	eb.WrapHandlers(eventbus.RecoverMiddleware(func(name string, event eventbus.Event, p any) {
+		recovered <- p
+	}))
+	received := make(chan int, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		if e.N == 1 {
+			panic("boom")
+		}
+		received <- e.N
+	})
+
+	eb.Emit(ping{N: 1})
+	if p := receive(t, recovered); p != "boom" {
+		t.Errorf("expected the panic value, got %v", p)
+	}
+	eb.Emit(ping{N: 2})
+	if n := receive(t, received); n != 2 {
+		t.Errorf("expected the bus to keep working, got %d", n)
+	}
+}
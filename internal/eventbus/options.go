@@ -0,0 +1,94 @@
+package eventbus
+
+import (
+	"errors"
+	"runtime"
+)
+
+var (
+	ErrQueueFull = errors.New("event queue is full")
+	ErrBusClosed = errors.New("event bus is shut down")
+)
+
+type config struct {
+	queueSize      int
+	workerPoolSize int
+	maxBacklog     int
+	numWorkers     int
+	deadLetters    DeadLetterSink
+	retry          RetryPolicy
+	transport      Transport
+}
+
+func defaultConfig() config {
+	return config{
+		queueSize:      100,
+		workerPoolSize: 200,
+		maxBacklog:     10000,
+		numWorkers:     runtime.NumCPU() * 2,
+	}
+}
+
+type Option func(*config)
+
+// WithQueueSize sets how many emitted events may wait for the dispatcher
+// before Emit blocks and TryEmit fails.
+func WithQueueSize(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.queueSize = size
+		}
+	}
+}
+
+// WithMaxBacklog sets how many handler calls may wait for a free worker
+// before the dispatcher stops taking events off the queue. Handlers that
+// emit while the bus is saturated wait for room, so keep it well above the
+// number of events in flight.
+func WithMaxBacklog(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.maxBacklog = size
+		}
+	}
+}
+
+func WithWorkerPoolSize(size int) Option {
+	return func(c *config) {
+		if size > 0 {
+			c.workerPoolSize = size
+		}
+	}
+}
+
+func WithWorkers(count int) Option {
+	return func(c *config) {
+		if count > 0 {
+			c.numWorkers = count
+		}
+	}
+}
+
+// WithDeadLetters sends events that no handler subscribed to, and handler
+// calls that still panic after retrying, to sink.
+func WithDeadLetters(sink DeadLetterSink) Option {
+	return func(c *config) {
+		c.deadLetters = sink
+	}
+}
+
+// WithRetryPolicy re-runs a panicking handler up to policy.MaxRetries times
+// before it is dead-lettered, or logged with its stack and dropped on a bus
+// without WithDeadLetters.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = policy
+	}
+}
+
+// WithTransport shares the bus with buses in other processes, see Transport.
+func WithTransport(transport Transport) Option {
+	return func(c *config) {
+		c.transport = transport
+	}
+}
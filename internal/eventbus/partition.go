@@ -0,0 +1,67 @@
+package eventbus
+
+type partition struct {
+	queue   []work
+	running bool
+}
+
+// WithOrderedDelivery delivers events sharing a partition key one at a time,
+// in emission order, while different keys are still handled in parallel by
+// the worker pool. Events with an empty key are not ordered. A nil
+// partitionKey partitions by AgentID. Handlers of ordered events hold back
+// the later events of their partition, long work belongs in a goroutine.
+func (eb *EventBus) WithOrderedDelivery(partitionKey func(Event) string) {
+	if partitionKey == nil {
+		partitionKey = AgentIDOf
+	}
+	eb.partitionMu.Lock()
+	defer eb.partitionMu.Unlock()
+	eb.partitionKey = partitionKey
+}
+
+func (eb *EventBus) partitionFor(event Event) string {
+	eb.partitionMu.Lock()
+	defer eb.partitionMu.Unlock()
+	if eb.partitionKey == nil {
+		return ""
+	}
+	return eb.partitionKey(event)
+}
+
+func (eb *EventBus) enqueuePartition(key string, works []work) {
+	eb.partitionMu.Lock()
+	defer eb.partitionMu.Unlock()
+
+	p, exists := eb.partitions[key]
+	if !exists {
+		p = &partition{}
+		eb.partitions[key] = p
+	}
+	p.queue = append(p.queue, works...)
+	if !p.running {
+		p.running = true
+		eb.submit(work{partition: key})
+	}
+}
+
+// drainPartition runs on a worker until the partition is empty.
+func (eb *EventBus) drainPartition(key string) {
+	eb.partitionMu.Lock()
+	p := eb.partitions[key]
+	eb.partitionMu.Unlock()
+
+	for {
+		eb.partitionMu.Lock()
+		if len(p.queue) == 0 || eb.ctx.Err() != nil {
+			p.running = false
+			delete(eb.partitions, key)
+			eb.partitionMu.Unlock()
+			return
+		}
+		w := p.queue[0]
+		p.queue = p.queue[1:]
+		eb.partitionMu.Unlock()
+
+		eb.call(w)
+	}
+}
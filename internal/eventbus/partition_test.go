@@ -0,0 +1,97 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOrderedDeliveryKeepsOrderPerKey(t *testing.T) {
+	eb := newBus(t, eventbus.WithWorkers(4))
+	eb.WithOrderedDelivery(nil)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var wg sync.WaitGroup
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		defer wg.Done()
+		time.Sleep(time.Duration(e.N%3) * time.Millisecond)
+		mu.Lock()
+		seen[e.AgentID] = append(seen[e.AgentID], e.N)
+		mu.Unlock()
+	})
+
+	keys := []string{"agent0", "agent1", "agent2"}
+	for n := range 60 {
+		wg.Add(1)
+		eb.Emit(ping{AgentID: keys[n%len(keys)], N: n})
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		if len(seen[key]) != 20 {
+			t.Fatalf("%s: expected 20 events, got %v", key, seen[key])
+		}
+		for j, n := range seen[key] {
+			if expected := i + j*len(keys); n != expected {
+				t.Fatalf("%s: expected events in emission order, got %v", key, seen[key])
+			}
+		}
+	}
+}
+
+func TestOrderedDeliveryDoesNotHoldOtherKeys(t *testing.T) {
+	eb := newBus(t)
+	eb.WithOrderedDelivery(nil)
+
+	release := make(chan struct{})
+	received := make(chan string, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		if e.AgentID == "agent0" {
+			<-release
+		}
+		received <- fmt.Sprint(e.AgentID, e.N)
+	})
+
+	eb.Emit(ping{AgentID: "agent0", N: 1})
+	eb.Emit(ping{AgentID: "agent0", N: 2})
+	eb.Emit(ping{AgentID: "agent1", N: 1})
+	if got := receive(t, received); got != "agent11" {
+		t.Fatalf("expected agent1 to be handled while agent0 is busy, got %s", got)
+	}
+	close(release)
+	for _, expected := range []string{"agent01", "agent02"} {
+		if got := receive(t, received); got != expected {
+			t.Fatalf("expected %s, got %s", expected, got)
+		}
+	}
+}
+
+func TestOrderedDeliveryUsesWorkerPool(t *testing.T) {
+	eb := newBus(t, eventbus.WithWorkers(2))
+	eb.WithOrderedDelivery(nil)
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) {
+		defer wg.Done()
+		n := running.Add(1)
+		defer running.Add(-1)
+		for current := peak.Load(); n > current && !peak.CompareAndSwap(current, n); current = peak.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	for n := range 8 {
+		wg.Add(1)
+		eb.Emit(ping{AgentID: fmt.Sprintf("agent%d", n), N: n})
+	}
+	wg.Wait()
+	if peak.Load() != 2 {
+		t.Errorf("expected partitions to run on the 2 workers, got %d at once", peak.Load())
+	}
+}
@@ -0,0 +1,41 @@
+package eventbus
+
+import "context"
+
+// Correlated events carry the ID that ties a reply to its request.
+type Correlated interface {
+	Event
+	CorrelationID() string
+}
+
+// Request emits req and waits for the first Resp event with the same
+// correlation ID. The reply subscription is removed before returning.
+func Request[Req Correlated, Resp Correlated](ctx context.Context, eb *EventBus, req Req) (Resp, error) {
+	var zero Resp
+	correlationID := req.CorrelationID()
+	replies := make(chan Resp, 1)
+
+	sub := Subscribe(eb, func(ctx context.Context, resp Resp) {
+		if resp.CorrelationID() != correlationID {
+			return
+		}
+		select {
+		case replies <- resp:
+		default:
+		}
+	})
+	defer sub.Unsubscribe()
+
+	if err := eb.EmitContext(ctx, req); err != nil {
+		return zero, err
+	}
+
+	select {
+	case resp := <-replies:
+		return resp, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case <-eb.ctx.Done():
+		return zero, ErrBusClosed
+	}
+}
@@ -0,0 +1,123 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type question struct {
+	eventbus.BaseEvent
+	ID string
+	N  int
+}
+
+type answer struct {
+	eventbus.BaseEvent
+	ID string
+	N  int
+}
+
+func (e question) CorrelationID() string { return e.ID }
+func (e answer) CorrelationID() string   { return e.ID }
+
+// answerWith replies to every question with its number doubled, after
+// emitting a reply to some other request.
+func answerWith(eb *eventbus.EventBus, delay time.Duration) {
+	eventbus.Subscribe(eb, func(ctx context.Context, q question) {
+		go func() {
+			eb.Emit(answer{ID: "other", N: -1})
+			time.Sleep(delay)
+			eb.Emit(answer{ID: q.ID, N: q.N * 2})
+		}()
+	})
+}
+
+func TestRequestMatchesCorrelationID(t *testing.T) {
+	eb := newBus(t)
+	answerWith(eb, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := eventbus.Request[question, answer](ctx, eb, question{ID: "q1", N: 21})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != "q1" || resp.N != 42 {
+		t.Errorf("expected the answer to q1, got %+v", resp)
+	}
+}
+
+func TestRequestTimesOut(t *testing.T) {
+	eb := newBus(t)
+	answerWith(eb, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := eventbus.Request[question, answer](ctx, eb, question{ID: "q1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected Request to return at the deadline, took %s", elapsed)
+	}
+}
+
+func TestRequestOnClosedBus(t *testing.T) {
+	eb := eventbus.NewEventBus()
+	eb.Shutdown(context.Background())
+	if _, err := eventbus.Request[question, answer](context.Background(), eb, question{ID: "q1"}); !errors.Is(err, eventbus.ErrBusClosed) {
+		t.Errorf("expected ErrBusClosed, got %v", err)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	eb := newBus(t)
+	answerWith(eb, 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for n := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := eventbus.Request[question, answer](ctx, eb, question{ID: fmt.Sprint("q", n), N: n})
+			if err != nil {
+				errs <- err
+			} else if resp.N != n*2 {
+				errs <- fmt.Errorf("request %d got the answer %d", n, resp.N)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestRequestRemovesReplySubscription(t *testing.T) {
+	letters := make(chan eventbus.DeadLetter, 10)
+	eb := newBus(t, eventbus.WithDeadLetters(func(letter eventbus.DeadLetter) { letters <- letter }))
+	eventbus.Subscribe(eb, func(ctx context.Context, q question) {
+		eb.Emit(answer{ID: q.ID})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := eventbus.Request[question, answer](ctx, eb, question{ID: "q1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody else listens for answers, a late one is dead-lettered.
+	eb.Emit(answer{ID: "q1"})
+	if letter := receive(t, letters); letter.Reason != eventbus.NO_SUBSCRIBERS {
+		t.Errorf("expected the late answer to have no subscribers, got %v", letter.Reason)
+	}
+}
@@ -0,0 +1,35 @@
+package eventbus
+
+import (
+	"math"
+	"time"
+)
+
+// RetryPolicy decides how often a failed call is retried: a panicking
+// handler on the bus, see WithRetryPolicy, or an LLM request in the runtimes.
+type RetryPolicy struct {
+	MaxRetries int
+	// Backoff is the wait before the first retry, doubled for every further
+	// retry up to MaxBackoff. Zero retries immediately.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Delay returns the wait before the given retry, counting from 1.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry && delay > 0; i++ {
+		if delay > math.MaxInt64/2 {
+			delay = math.MaxInt64
+			break
+		}
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,23 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := eventbus.RetryPolicy{Backoff: time.Second, MaxBackoff: time.Minute}
+	for retry, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 6: 32 * time.Second, 7: time.Minute, 100: time.Minute} {
+		if got := policy.Delay(retry); got != expected {
+			t.Errorf("retry %d: expected %s, got %s", retry, expected, got)
+		}
+	}
+
+	policy.MaxBackoff = 0
+	for retry := 60; retry < 70; retry++ {
+		if got := policy.Delay(retry); got <= 0 {
+			t.Errorf("retry %d: delay overflowed to %s", retry, got)
+		}
+	}
+}
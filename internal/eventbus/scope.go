@@ -0,0 +1,48 @@
+package eventbus
+
+import (
+	"context"
+	"slices"
+)
+
+// Scope returns a bus that sends its events to eb and subscribes on eb, for a
+// caller that may be abandoned before it is done with the bus. Once the
+// scope is shut down its events are dropped with ErrBusClosed and its
+// subscriptions are removed. A scope runs no goroutine of its own.
+func (eb *EventBus) Scope() *EventBus {
+	ctx, cancel := context.WithCancel(eb.ctx)
+	return &EventBus{
+		parent: eb,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (eb *EventBus) scopeSubscribe(sub *Subscription) {
+	eb.handlerMu.Lock()
+	if eb.ctx.Err() != nil {
+		eb.handlerMu.Unlock()
+		sub.closed.Store(true)
+		return
+	}
+	eb.scoped = append(eb.scoped, sub)
+	eb.handlerMu.Unlock()
+	eb.parent.subscribe(sub)
+}
+
+func (eb *EventBus) scopeUnsubscribe(sub *Subscription) {
+	eb.handlerMu.Lock()
+	eb.scoped = without(eb.scoped, sub)
+	eb.handlerMu.Unlock()
+	eb.parent.unsubscribe(sub)
+}
+
+func (eb *EventBus) shutdownScope() {
+	eb.handlerMu.Lock()
+	eb.cancel()
+	scoped := slices.Clone(eb.scoped)
+	eb.handlerMu.Unlock()
+	for _, sub := range scoped {
+		sub.Unsubscribe()
+	}
+}
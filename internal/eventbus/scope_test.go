@@ -0,0 +1,73 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestScopeForwardsUntilShutdown(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan int, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+
+	scope := eb.Scope()
+	scope.Emit(ping{N: 1})
+	if n := receive(t, received); n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+
+	scope.Shutdown(context.Background())
+	if err := scope.EmitContext(context.Background(), ping{N: 2}); !errors.Is(err, eventbus.ErrBusClosed) {
+		t.Errorf("expected ErrBusClosed, got %v", err)
+	}
+	if err := scope.TryEmit(ping{N: 3}); !errors.Is(err, eventbus.ErrBusClosed) {
+		t.Errorf("expected ErrBusClosed, got %v", err)
+	}
+	expectNone(t, received)
+
+	eb.Emit(ping{N: 4})
+	if n := receive(t, received); n != 4 {
+		t.Errorf("expected the parent bus to keep working, got %d", n)
+	}
+}
+
+func TestScopeSubscriptionsEndWithShutdown(t *testing.T) {
+	eb := newBus(t)
+	scope := eb.Scope()
+	received := make(chan int, 10)
+	sub := eventbus.Subscribe(scope, func(ctx context.Context, e ping) { received <- e.N })
+
+	eb.Emit(ping{N: 1})
+	if n := receive(t, received); n != 1 {
+		t.Fatalf("expected the scope's handler to see the parent's events, got %d", n)
+	}
+
+	scope.Shutdown(context.Background())
+	if sub.Active() {
+		t.Error("expected the subscription to end with the scope")
+	}
+	eb.Emit(ping{N: 2})
+	expectNone(t, received)
+
+	if late := eventbus.Subscribe(scope, func(ctx context.Context, e ping) {}); late.Active() {
+		t.Error("expected a subscription on a closed scope to be inactive")
+	}
+}
+
+func TestScopeUnsubscribeKeepsParentSubscriptions(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan int, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { received <- e.N })
+
+	// The first subscription of the scope must not be mistaken for the
+	// first one of its parent.
+	scope := eb.Scope()
+	eventbus.Subscribe(scope, func(ctx context.Context, e ping) {}).Unsubscribe()
+
+	eb.Emit(ping{N: 1})
+	if n := receive(t, received); n != 1 {
+		t.Errorf("expected the parent's handler to be kept, got %d", n)
+	}
+}
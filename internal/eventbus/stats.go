@@ -0,0 +1,31 @@
+package eventbus
+
+type Stats struct {
+	QueueDepth    int
+	QueueCapacity int
+	// Backlog counts handler calls and ordered partitions waiting for a free
+	// worker.
+	Backlog     int
+	Workers     int
+	BusyWorkers int
+	// RunningHandlers counts handler calls in progress.
+	RunningHandlers int
+	// Partitions counts agents with ordered deliveries in progress.
+	Partitions int
+}
+
+func (eb *EventBus) Stats() Stats {
+	eb.partitionMu.Lock()
+	partitions := len(eb.partitions)
+	eb.partitionMu.Unlock()
+
+	return Stats{
+		QueueDepth:      len(eb.eventQueue),
+		QueueCapacity:   cap(eb.eventQueue),
+		Backlog:         int(eb.backlogSize.Load()),
+		Workers:         eb.numWorkers,
+		BusyWorkers:     int(eb.busyWorkers.Load()),
+		RunningHandlers: int(eb.runningHandlers.Load()),
+		Partitions:      partitions,
+	}
+}
@@ -0,0 +1,92 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"fmt"
+	"testing"
+)
+
+// numbered is implemented by pong only.
+type numbered interface{ Number() int }
+
+func (e pong) Number() int { return e.N }
+
+func TestSubscribeAll(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan string, 10)
+	eventbus.SubscribeAll(eb, func(ctx context.Context, e eventbus.Event) {
+		received <- fmt.Sprintf("%T", e)
+	})
+
+	eb.Emit(ping{})
+	eb.Emit(pong{})
+	got := map[string]bool{receive(t, received): true, receive(t, received): true}
+	if !got["eventbus_test.ping"] || !got["eventbus_test.pong"] {
+		t.Errorf("expected both events, got %v", got)
+	}
+}
+
+func TestSubscribeWhere(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan eventbus.Event, 10)
+	eventbus.SubscribeWhere(eb, func(e eventbus.Event) bool {
+		p, ok := e.(ping)
+		return ok && p.N%2 == 0
+	}, func(ctx context.Context, e eventbus.Event) {
+		received <- e
+	})
+
+	for n := range 4 {
+		eb.Emit(ping{N: n})
+	}
+	eb.Emit(pong{N: 2})
+	got := map[eventbus.Event]bool{receive(t, received): true, receive(t, received): true}
+	if !got[ping{N: 0}] || !got[ping{N: 2}] {
+		t.Errorf("expected the even pings, got %v", got)
+	}
+	expectNone(t, received)
+}
+
+func TestSubscribeMatching(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan int, 10)
+	eventbus.SubscribeMatching(eb, func(ctx context.Context, e numbered) {
+		received <- e.Number()
+	})
+
+	eb.Emit(ping{N: 1})
+	eb.Emit(pong{N: 2})
+	if n := receive(t, received); n != 2 {
+		t.Errorf("expected only the pong, got %d", n)
+	}
+	expectNone(t, received)
+}
+
+func TestSubscribeMatchingRequiresInterface(t *testing.T) {
+	eb := newBus(t)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected SubscribeMatching to panic on a struct type")
+		}
+	}()
+	eventbus.SubscribeMatching(eb, func(ctx context.Context, e ping) {})
+}
+
+func TestMatchersAndTypedHandlersBothReceive(t *testing.T) {
+	eb := newBus(t)
+	received := make(chan string, 10)
+	eventbus.Subscribe(eb, func(ctx context.Context, e pong) { received <- "typed" })
+	eventbus.SubscribeMatching(eb, func(ctx context.Context, e numbered) { received <- "matching" })
+	eventbus.SubscribeAll(eb, func(ctx context.Context, e eventbus.Event) { received <- "all" })
+
+	eb.Emit(pong{})
+	got := map[string]bool{}
+	for range 3 {
+		got[receive(t, received)] = true
+	}
+	if len(got) != 3 {
+		t.Errorf("expected every handler to be called once, got %v", got)
+	}
+	expectNone(t, received)
+}
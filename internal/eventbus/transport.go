@@ -0,0 +1,91 @@
+package eventbus
+
+import (
+	"context"
+	"slices"
+)
+
+// Transport connects buses running in different processes. Events emitted on
+// a bus are published to its transport, and events received from the
+// transport are dispatched locally without being published again.
+//
+// Events are published with the context derived by the TransportContext
+// hooks of the bus, and a transport may carry values of it, e.g. the trace,
+// to the context it delivers the event with on the other side.
+type Transport interface {
+	Publish(ctx context.Context, event Event) error
+	// Receive calls deliver for every remote event until the transport is closed.
+	Receive(deliver func(ctx context.Context, event Event)) error
+	Close() error
+}
+
+// TransportContext derives the context an event is published with from the
+// context it was emitted with.
+type TransportContext func(ctx context.Context, event Event) context.Context
+
+// UseTransportContext adds hooks deriving the context events are published
+// with, they run in order.
+func (eb *EventBus) UseTransportContext(hooks ...TransportContext) {
+	eb.handlerMu.Lock()
+	defer eb.handlerMu.Unlock()
+	eb.transportContexts = append(slices.Clone(eb.transportContexts), hooks...)
+}
+
+// Claimer is implemented by transports that can hand each remote event of a
+// type to only one of the buses claiming it, instead of to all of them.
+type Claimer interface {
+	Claim(events ...Event) error
+}
+
+// Claim asks the transport to deliver remote events of the given types to
+// only one of the buses claiming them, so processes running the same
+// handlers share the events instead of each handling all of them. Without a
+// transport, or with one that cannot claim, it does nothing.
+func (eb *EventBus) Claim(events ...Event) error {
+	claimer, ok := eb.transport.(Claimer)
+	if !ok {
+		return nil
+	}
+	return claimer.Claim(events...)
+}
+
+type remoteKey struct{}
+
+// Remote reports whether the event an interceptor is given was received from
+// the transport rather than emitted in this process.
+func Remote(ctx context.Context) bool {
+	remote, _ := ctx.Value(remoteKey{}).(bool)
+	return remote
+}
+
+// publish runs on the dispatcher. Events still queued at Shutdown stay
+// local, the transport is closed by then.
+func (eb *EventBus) publish(ctx context.Context, event Event) {
+	if eb.transport == nil || eb.ctx.Err() != nil {
+		return
+	}
+	eb.handlerMu.RLock()
+	hooks := eb.transportContexts
+	eb.handlerMu.RUnlock()
+
+	for _, hook := range hooks {
+		ctx = hook(ctx, event)
+	}
+	if err := eb.transport.Publish(ctx, event); err != nil {
+		eb.deadLetter(DeadLetter{Event: event, Reason: TRANSPORT_FAILED, Err: err})
+	}
+}
+
+func (eb *EventBus) receive() {
+	defer eb.wg.Done()
+
+	err := eb.transport.Receive(func(ctx context.Context, event Event) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		eb.enqueue(emitted{ctx: ctx, event: event, remote: true})
+	})
+	if err != nil && eb.ctx.Err() == nil {
+		eb.logger.Load().Error("transport stopped receiving", "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package eventbus_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"testing"
+)
+
+// loopback is a transport whose remote events are sent by the test.
+type loopback struct {
+	published chan published
+	remote    chan eventbus.Event
+	closed    chan struct{}
+}
+
+type published struct {
+	ctx   context.Context
+	event eventbus.Event
+}
+
+func newLoopback() *loopback {
+	return &loopback{
+		published: make(chan published, 10),
+		remote:    make(chan eventbus.Event, 10),
+		closed:    make(chan struct{}),
+	}
+}
+
+func (l *loopback) Publish(ctx context.Context, event eventbus.Event) error {
+	l.published <- published{ctx: ctx, event: event}
+	return nil
+}
+
+func (l *loopback) Receive(deliver func(context.Context, eventbus.Event)) error {
+	for {
+		select {
+		case event := <-l.remote:
+			deliver(context.WithValue(context.Background(), ctxKey{}, "remote"), event)
+		case <-l.closed:
+			return nil
+		}
+	}
+}
+
+func (l *loopback) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestPublishAfterInterceptors(t *testing.T) {
+	transport := newLoopback()
+	eb := newBus(t, eventbus.WithTransport(transport))
+	eb.Use(func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		e := event.(ping)
+		e.N *= 10
+		return e, e.N != 20
+	})
+	eb.UseTransportContext(func(ctx context.Context, event eventbus.Event) context.Context {
+		return context.WithValue(ctx, ctxKey{}, "published")
+	})
+
+	eb.Emit(ping{N: 1})
+	eb.Emit(ping{N: 2})
+	eb.Emit(ping{N: 3})
+
+	for _, expected := range []int{10, 30} {
+		got := receive(t, transport.published)
+		if got.event.(ping).N != expected {
+			t.Errorf("expected the intercepted ping %d published, got %v", expected, got.event)
+		}
+		if got.ctx.Value(ctxKey{}) != "published" {
+			t.Errorf("expected the transport context, got %v", got.ctx.Value(ctxKey{}))
+		}
+	}
+	expectNone(t, transport.published)
+}
+
+func TestRemoteEventsAreNotPublished(t *testing.T) {
+	transport := newLoopback()
+	eb := newBus(t, eventbus.WithTransport(transport))
+	remote := make(chan bool, 1)
+	eb.Use(func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		remote <- eventbus.Remote(ctx)
+		return event, true
+	})
+	values := make(chan any, 1)
+	eventbus.Subscribe(eb, func(ctx context.Context, e ping) { values <- ctx.Value(ctxKey{}) })
+
+	transport.remote <- ping{N: 1}
+	if !receive(t, remote) {
+		t.Error("expected the interceptor to see a remote event")
+	}
+	if value := receive(t, values); value != "remote" {
+		t.Errorf("expected the handler to get the context it was received with, got %v", value)
+	}
+	expectNone(t, transport.published)
+
+	eb.Emit(ping{N: 2})
+	if receive(t, remote) {
+		t.Error("expected the interceptor to see a local event")
+	}
+	receive(t, transport.published)
+}
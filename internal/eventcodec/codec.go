@@ -0,0 +1,90 @@
+package eventcodec
+
+import (
+	"agentlauncher/internal/eventbus"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type Envelope struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+// Registry maps event types to stable names so they survive a JSON
+// round-trip. Interface-typed fields inside events must decode themselves,
+// as llminterface.MessageList does.
+type Registry struct {
+	events     map[string]reflect.Type
+	eventNames map[reflect.Type]string
+	mu         sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		events:     make(map[string]reflect.Type),
+		eventNames: make(map[reflect.Type]string),
+	}
+}
+
+func (r *Registry) Register(events ...eventbus.Event) *Registry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range events {
+		t := reflect.TypeOf(event)
+		r.events[t.Name()] = t
+		r.eventNames[t] = t.Name()
+	}
+	return r
+}
+
+func (r *Registry) Name(event eventbus.Event) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, exists := r.eventNames[reflect.TypeOf(event)]
+	return name, exists
+}
+
+func (r *Registry) Encode(event eventbus.Event) (Envelope, error) {
+	name, exists := r.Name(event)
+	if !exists {
+		return Envelope{}, fmt.Errorf("event type %T is not registered", event)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return Envelope{Type: name, Event: data}, nil
+}
+
+func (r *Registry) Decode(envelope Envelope) (eventbus.Event, error) {
+	r.mu.RLock()
+	t, exists := r.events[envelope.Type]
+	r.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("event type %s is not registered", envelope.Type)
+	}
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(envelope.Event, ptr.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", envelope.Type, err)
+	}
+	return ptr.Elem().Interface().(eventbus.Event), nil
+}
+
+func (r *Registry) Marshal(event eventbus.Event) ([]byte, error) {
+	envelope, err := r.Encode(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope)
+}
+
+func (r *Registry) Unmarshal(data []byte) (eventbus.Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	return r.Decode(envelope)
+}
@@ -0,0 +1,112 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type JSONLBackend struct {
+	path string
+	file *os.File
+	mu   sync.Mutex
+}
+
+// OpenJSONL opens path for appending, creating it if needed. Existing records
+// are kept, so a log can span several runs.
+func OpenJSONL(path string) (*JSONLBackend, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+	return &JSONLBackend{path: path, file: file}, nil
+}
+
+func (b *JSONLBackend) Append(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return os.ErrClosed
+	}
+	_, err = b.file.Write(append(data, '\n'))
+	return err
+}
+
+func (b *JSONLBackend) Read(fn func(Record) error) error {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("event log line %d: %w", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (b *JSONLBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.file == nil {
+		return nil
+	}
+	err := b.file.Close()
+	b.file = nil
+	if errors.Is(err, os.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+type MemoryBackend struct {
+	records []Record
+	mu      sync.RWMutex
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{}
+}
+
+func (b *MemoryBackend) Append(record Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, record)
+	return nil
+}
+
+func (b *MemoryBackend) Read(fn func(Record) error) error {
+	b.mu.RLock()
+	records := append([]Record{}, b.records...)
+	b.mu.RUnlock()
+	for _, record := range records {
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
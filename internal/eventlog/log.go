@@ -0,0 +1,133 @@
+package eventlog
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventcodec"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Record struct {
+	Seq   int64           `json:"seq"`
+	Time  time.Time       `json:"time"`
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+type Backend interface {
+	Append(record Record) error
+	Read(fn func(Record) error) error
+	Close() error
+}
+
+type Recorder struct {
+	backend  Backend
+	registry *eventcodec.Registry
+	seq      atomic.Int64
+	stopped  atomic.Bool
+	err      error
+	mu       sync.Mutex
+}
+
+// NewRecorder appends every event dispatched on eb to backend, in dispatch
+// order. Events whose type is not in registry are skipped.
+func NewRecorder(eb *eventbus.EventBus, backend Backend, registry *eventcodec.Registry) *Recorder {
+	r := &Recorder{
+		backend:  backend,
+		registry: registry,
+	}
+	eb.Use(r.intercept)
+	return r
+}
+
+func (r *Recorder) intercept(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+	if r.stopped.Load() {
+		return event, true
+	}
+	if _, registered := r.registry.Name(event); !registered {
+		return event, true
+	}
+	envelope, err := r.registry.Encode(event)
+	if err == nil {
+		err = r.backend.Append(Record{
+			Seq:   r.seq.Add(1),
+			Time:  time.Now(),
+			Type:  envelope.Type,
+			Event: envelope.Event,
+		})
+	}
+	if err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+	return event, true
+}
+
+// Err returns the first error hit while recording, if any.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+func (r *Recorder) Close() error {
+	r.stopped.Store(true)
+	return r.backend.Close()
+}
+
+type Entry struct {
+	Record
+	Decoded eventbus.Event
+}
+
+func ReadAll(backend Backend, registry *eventcodec.Registry) ([]Entry, error) {
+	entries := []Entry{}
+	err := backend.Read(func(record Record) error {
+		event, err := registry.Decode(eventcodec.Envelope{Type: record.Type, Event: record.Event})
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{Record: record, Decoded: event})
+		return nil
+	})
+	return entries, err
+}
+
+type ReplayOptions struct {
+	// RealTime waits between events as long as they were apart when recorded.
+	RealTime bool
+	Filter   func(Entry) bool
+}
+
+// Replay emits the recorded events onto eb in their original order.
+func Replay(ctx context.Context, eb *eventbus.EventBus, backend Backend, registry *eventcodec.Registry, opts ReplayOptions) error {
+	entries, err := ReadAll(backend, registry)
+	if err != nil {
+		return err
+	}
+
+	var previous time.Time
+	for _, entry := range entries {
+		if opts.Filter != nil && !opts.Filter(entry) {
+			continue
+		}
+		if opts.RealTime && !previous.IsZero() {
+			select {
+			case <-time.After(entry.Time.Sub(previous)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		previous = entry.Time
+		if err := eb.EmitContext(ctx, entry.Decoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
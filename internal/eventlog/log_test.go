@@ -0,0 +1,181 @@
+package eventlog_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventlog"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type unregistered struct {
+	eventbus.BaseEvent
+}
+
+func recorded() []eventbus.Event {
+	return []eventbus.Event{
+		events.AgentCreateEvent{
+			AgentID:      "agent0",
+			Task:         "count",
+			ToolSchemas:  []llminterface.ToolSchema{{Name: "add", Description: "Add numbers"}},
+			Conversation: llminterface.MessageList{llminterface.UserMessage{Content: "hi"}},
+			SystemPrompt: "be brief",
+		},
+		events.LLMResponseEvent{
+			AgentID: "agent0",
+			Response: llminterface.ResponseMessageList{
+				llminterface.AssistantMessage{Content: "adding"},
+				llminterface.ToolCallMessage{ToolCallID: "call_1", ToolName: "add", Arguments: map[string]any{"a": 1.0, "b": 2.0}},
+			},
+			Route: "main_agent",
+		},
+		events.ToolExecFinishEvent{AgentID: "agent0", ToolCallID: "call_1", ToolName: "add", Result: "3"},
+		events.TaskFinishEvent{AgentID: "agent0", Result: "3"},
+	}
+}
+
+// record emits the events of recorded, and one whose type is not
+// registered, through a bus recording to backend.
+func record(t *testing.T, backend eventlog.Backend) {
+	t.Helper()
+	eb := eventbus.NewEventBus()
+	defer eb.Shutdown(context.Background())
+	eventlog.NewRecorder(eb, backend, events.NewRegistry())
+	done := make(chan struct{})
+	eventbus.Subscribe(eb, func(ctx context.Context, e events.TaskFinishEvent) { close(done) })
+
+	eb.Emit(unregistered{})
+	for _, event := range recorded() {
+		eb.Emit(event)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the events were not dispatched")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	backends := map[string]func(t *testing.T) eventlog.Backend{
+		"memory": func(t *testing.T) eventlog.Backend { return eventlog.NewMemoryBackend() },
+		"jsonl": func(t *testing.T) eventlog.Backend {
+			backend, err := eventlog.OpenJSONL(filepath.Join(t.TempDir(), "events.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return backend
+		},
+	}
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			backend := open(t)
+			defer backend.Close()
+			record(t, backend)
+
+			entries, err := eventlog.ReadAll(backend, events.NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := recorded()
+			if len(entries) != len(expected) {
+				t.Fatalf("expected %d entries, got %d", len(expected), len(entries))
+			}
+			for i, entry := range entries {
+				if entry.Seq != int64(i+1) {
+					t.Errorf("entry %d: expected seq %d, got %d", i, i+1, entry.Seq)
+				}
+				if !reflect.DeepEqual(entry.Decoded, expected[i]) {
+					t.Errorf("entry %d: expected %#v, got %#v", i, expected[i], entry.Decoded)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONLKeepsEarlierRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	for range 2 {
+		backend, err := eventlog.OpenJSONL(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record(t, backend)
+		backend.Close()
+	}
+
+	backend, err := eventlog.OpenJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	entries, err := eventlog.ReadAll(backend, events.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2*len(recorded()) {
+		t.Errorf("expected the events of both runs, got %d", len(entries))
+	}
+}
+
+func TestAppendAfterClose(t *testing.T) {
+	backend, err := eventlog.OpenJSONL(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.Close()
+	if err := backend.Append(eventlog.Record{}); err == nil {
+		t.Error("expected appending to a closed log to fail")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	backend := eventlog.NewMemoryBackend()
+	record(t, backend)
+
+	eb := eventbus.NewEventBus()
+	defer eb.Shutdown(context.Background())
+	eb.WithOrderedDelivery(nil)
+	replayed := make(chan eventbus.Event, 10)
+	eventbus.SubscribeAll(eb, func(ctx context.Context, e eventbus.Event) { replayed <- e })
+
+	err := eventlog.Replay(context.Background(), eb, backend, events.NewRegistry(), eventlog.ReplayOptions{
+		Filter: func(entry eventlog.Entry) bool { return entry.Type != "ToolExecFinishEvent" },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range recorded() {
+		if _, skipped := expected.(events.ToolExecFinishEvent); skipped {
+			continue
+		}
+		select {
+		case e := <-replayed:
+			if !reflect.DeepEqual(e, expected) {
+				t.Errorf("expected %#v, got %#v", expected, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%T was not replayed", expected)
+		}
+	}
+}
+
+func TestReplayRealTimeStopsWithContext(t *testing.T) {
+	backend := eventlog.NewMemoryBackend()
+	start := time.Now()
+	backend.Append(eventlog.Record{Seq: 1, Time: start, Type: "TaskFinishEvent", Event: []byte(`{"agent_id":"agent0"}`)})
+	backend.Append(eventlog.Record{Seq: 2, Time: start.Add(time.Hour), Type: "TaskFinishEvent", Event: []byte(`{"agent_id":"agent0"}`)})
+
+	eb := eventbus.NewEventBus()
+	defer eb.Shutdown(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := eventlog.Replay(ctx, eb, backend, events.NewRegistry(), eventlog.ReplayOptions{RealTime: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the replay to stop with its context, got %v", err)
+	}
+}
@@ -10,8 +10,12 @@ type AgentCreateEvent struct {
 	AgentID      string                    `json:"agent_id"`
 	Task         string                    `json:"task"`
 	ToolSchemas  []llminterface.ToolSchema `json:"tool_schemas"`
-	Conversation []llminterface.Message    `json:"conversation"`
+	Conversation llminterface.MessageList  `json:"conversation"`
 	SystemPrompt string                    `json:"system_prompt"`
+	Profile      string                    `json:"profile,omitempty"`
+	MaxTurns     int                       `json:"max_turns,omitempty"`
+	// RequestID is echoed by the event reporting the agent's result.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type AgentStartEvent struct {
@@ -25,6 +29,15 @@ type AgentFinishEvent struct {
 	Result  string `json:"result"`
 }
 
+// SubAgentFinishEvent reports the result of a sub-agent to the tool call that
+// created it.
+type SubAgentFinishEvent struct {
+	eventbus.BaseEvent
+	AgentID   string `json:"agent_id"`
+	Result    string `json:"result"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
 type AgentRuntimeErrorEvent struct {
 	eventbus.BaseEvent
 	AgentID string `json:"agent_id"`
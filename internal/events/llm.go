@@ -7,17 +7,19 @@ import (
 
 type LLMRequestEvent struct {
 	eventbus.BaseEvent
-	AgentID     string                    `json:"agent_id"`
-	Messages    []llminterface.Message    `json:"messages"`
-	ToolSchemas []llminterface.ToolSchema `json:"tool_schemas"`
-	RetryCount  int                       `json:"retry_count"`
+	AgentID     string                          `json:"agent_id"`
+	Messages    llminterface.RequestMessageList `json:"messages"`
+	ToolSchemas []llminterface.ToolSchema       `json:"tool_schemas"`
+	RetryCount  int                             `json:"retry_count"`
+	Profile     string                          `json:"profile,omitempty"`
 }
 
 type LLMResponseEvent struct {
 	eventbus.BaseEvent
-	AgentID      string                 `json:"agent_id"`
-	RequestEvent LLMRequestEvent        `json:"request_event"`
-	Response     []llminterface.Message `json:"response"`
+	AgentID      string                           `json:"agent_id"`
+	RequestEvent LLMRequestEvent                  `json:"request_event"`
+	Response     llminterface.ResponseMessageList `json:"response"`
+	Route        string                           `json:"route,omitempty"`
 }
 
 type LLMRuntimeErrorEvent struct {
@@ -26,3 +28,28 @@ type LLMRuntimeErrorEvent struct {
 	Error        string          `json:"error"`
 	RequestEvent LLMRequestEvent `json:"request_event"`
 }
+
+type LLMRouteDecisionEvent struct {
+	eventbus.BaseEvent
+	AgentID         string   `json:"agent_id"`
+	Candidates      []string `json:"candidates"`
+	EstimatedTokens int      `json:"estimated_tokens"`
+}
+
+type LLMRouteFailedEvent struct {
+	eventbus.BaseEvent
+	AgentID string `json:"agent_id"`
+	Route   string `json:"route"`
+	Attempt int    `json:"attempt"`
+	Error   string `json:"error"`
+}
+
+// LLMUsageEvent may be emitted by an LLMHandler to report the model that
+// answered and the tokens it used.
+type LLMUsageEvent struct {
+	eventbus.BaseEvent
+	AgentID      string `json:"agent_id"`
+	Model        string `json:"model"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+}
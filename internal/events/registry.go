@@ -0,0 +1,53 @@
+package events
+
+import "agentlauncher/internal/eventcodec"
+
+// NewRegistry returns a codec registry that knows every event in this package.
+func NewRegistry() *eventcodec.Registry {
+	return eventcodec.NewRegistry().
+		Register(
+			AgentCreateEvent{},
+			AgentStartEvent{},
+			AgentFinishEvent{},
+			SubAgentFinishEvent{},
+			AgentRuntimeErrorEvent{},
+			AgentDeletedEvent{},
+			AgentLauncherRunEvent{},
+			AgentLauncherStopEvent{},
+			AgentLauncherShutdownEvent{},
+			AgentLauncherErrorEvent{},
+			LLMRequestEvent{},
+			LLMResponseEvent{},
+			LLMRuntimeErrorEvent{},
+			LLMRouteDecisionEvent{},
+			LLMRouteFailedEvent{},
+			LLMUsageEvent{},
+			MessagesAddEvent{},
+			MessageStartStreamingEvent{},
+			MessageDeltaStreamingEvent{},
+			MessageDoneStreamingEvent{},
+			MessageErrorStreamingEvent{},
+			ToolCallNameStreamingEvent{},
+			ToolCallArgumentsStartStreamingEvent{},
+			ToolCallArgumentsDeltaStreamingEvent{},
+			ToolCallArgumentsDoneStreamingEvent{},
+			ToolCallArgumentsErrorStreamingEvent{},
+			TaskCreateEvent{},
+			TaskFinishEvent{},
+			TaskCancelEvent{},
+			ToolsExecRequestEvent{},
+			ToolsExecResultsEvent{},
+			ToolRuntimeErrorEvent{},
+			ToolExecStartEvent{},
+			ToolExecFinishEvent{},
+			ToolExecErrorEvent{},
+			ToolExecQueuedEvent{},
+			ToolSchemasRequestEvent{},
+			ToolSchemasResponseEvent{},
+			ToolApprovalRequestEvent{},
+			ToolApprovalResponseEvent{},
+			UserInputRequestEvent{},
+			UserInputResponseEvent{},
+			UserFollowUpEvent{},
+		)
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"agentlauncher/internal/eventbus"
+	"strings"
+)
+
+type AgentEvent interface {
+	eventbus.Event
+	GetAgentID() string
+}
+
+func ForAgent(agentID string) func(eventbus.Event) bool {
+	return func(e eventbus.Event) bool {
+		agentEvent, ok := e.(AgentEvent)
+		return ok && agentEvent.GetAgentID() == agentID
+	}
+}
+
+// ForAgentTree matches events of the agent and every sub-agent spawned below it.
+func ForAgentTree(agentID string) func(eventbus.Event) bool {
+	return func(e eventbus.Event) bool {
+		agentEvent, ok := e.(AgentEvent)
+		if !ok {
+			return false
+		}
+		return InAgentTree(agentEvent.GetAgentID(), agentID)
+	}
+}
+
+// InAgentTree reports whether agentID is rootAgentID or one of its sub-agents.
+func InAgentTree(agentID, rootAgentID string) bool {
+	return agentID == rootAgentID || strings.HasPrefix(agentID, rootAgentID+"_")
+}
+
+func (e AgentCreateEvent) GetAgentID() string                     { return e.AgentID }
+func (e AgentStartEvent) GetAgentID() string                      { return e.AgentID }
+func (e AgentFinishEvent) GetAgentID() string                     { return e.AgentID }
+func (e SubAgentFinishEvent) GetAgentID() string                  { return e.AgentID }
+func (e AgentRuntimeErrorEvent) GetAgentID() string               { return e.AgentID }
+func (e AgentDeletedEvent) GetAgentID() string                    { return e.AgentID }
+func (e AgentLauncherRunEvent) GetAgentID() string                { return e.AgentID }
+func (e AgentLauncherStopEvent) GetAgentID() string               { return e.AgentID }
+func (e AgentLauncherShutdownEvent) GetAgentID() string           { return e.AgentID }
+func (e AgentLauncherErrorEvent) GetAgentID() string              { return e.AgentID }
+func (e LLMRequestEvent) GetAgentID() string                      { return e.AgentID }
+func (e LLMResponseEvent) GetAgentID() string                     { return e.AgentID }
+func (e LLMRuntimeErrorEvent) GetAgentID() string                 { return e.AgentID }
+func (e LLMRouteDecisionEvent) GetAgentID() string                { return e.AgentID }
+func (e LLMRouteFailedEvent) GetAgentID() string                  { return e.AgentID }
+func (e LLMUsageEvent) GetAgentID() string                        { return e.AgentID }
+func (e MessagesAddEvent) GetAgentID() string                     { return e.AgentID }
+func (e MessageStartStreamingEvent) GetAgentID() string           { return e.AgentID }
+func (e MessageDeltaStreamingEvent) GetAgentID() string           { return e.AgentID }
+func (e MessageDoneStreamingEvent) GetAgentID() string            { return e.AgentID }
+func (e MessageErrorStreamingEvent) GetAgentID() string           { return e.AgentID }
+func (e ToolCallNameStreamingEvent) GetAgentID() string           { return e.AgentID }
+func (e ToolCallArgumentsStartStreamingEvent) GetAgentID() string { return e.AgentID }
+func (e ToolCallArgumentsDeltaStreamingEvent) GetAgentID() string { return e.AgentID }
+func (e ToolCallArgumentsDoneStreamingEvent) GetAgentID() string  { return e.AgentID }
+func (e ToolCallArgumentsErrorStreamingEvent) GetAgentID() string { return e.AgentID }
+func (e TaskCreateEvent) GetAgentID() string                      { return e.AgentID }
+func (e TaskFinishEvent) GetAgentID() string                      { return e.AgentID }
+func (e TaskCancelEvent) GetAgentID() string                      { return e.AgentID }
+func (e ToolsExecRequestEvent) GetAgentID() string                { return e.AgentID }
+func (e ToolsExecResultsEvent) GetAgentID() string                { return e.AgentID }
+func (e ToolRuntimeErrorEvent) GetAgentID() string                { return e.AgentID }
+func (e ToolExecStartEvent) GetAgentID() string                   { return e.AgentID }
+func (e ToolExecFinishEvent) GetAgentID() string                  { return e.AgentID }
+func (e ToolExecErrorEvent) GetAgentID() string                   { return e.AgentID }
+func (e ToolExecQueuedEvent) GetAgentID() string                  { return e.AgentID }
+func (e ToolSchemasRequestEvent) GetAgentID() string              { return e.AgentID }
+func (e ToolSchemasResponseEvent) GetAgentID() string             { return e.AgentID }
+func (e ToolApprovalRequestEvent) GetAgentID() string             { return e.AgentID }
+func (e ToolApprovalResponseEvent) GetAgentID() string            { return e.AgentID }
+func (e UserInputRequestEvent) GetAgentID() string                { return e.AgentID }
+func (e UserInputResponseEvent) GetAgentID() string               { return e.AgentID }
+func (e UserFollowUpEvent) GetAgentID() string                    { return e.AgentID }
+
+func (e TaskCreateEvent) CorrelationID() string     { return e.RequestID }
+func (e AgentCreateEvent) CorrelationID() string    { return e.RequestID }
+func (e TaskFinishEvent) CorrelationID() string     { return e.RequestID }
+func (e SubAgentFinishEvent) CorrelationID() string { return e.RequestID }
+
+func (e ToolSchemasRequestEvent) CorrelationID() string  { return e.AgentID }
+func (e ToolSchemasResponseEvent) CorrelationID() string { return e.AgentID }
+
+func (e ToolApprovalRequestEvent) CorrelationID() string  { return e.AgentID + "/" + e.ToolCallID }
+func (e ToolApprovalResponseEvent) CorrelationID() string { return e.AgentID + "/" + e.ToolCallID }
+func (e UserInputRequestEvent) CorrelationID() string     { return e.AgentID + "/" + e.RequestID }
+func (e UserInputResponseEvent) CorrelationID() string    { return e.AgentID + "/" + e.RequestID }
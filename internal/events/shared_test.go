@@ -0,0 +1,38 @@
+package events_test
+
+import (
+	"agentlauncher/internal/events"
+	"testing"
+)
+
+func TestForAgentTree(t *testing.T) {
+	inTree := events.ForAgentTree("agent1")
+	tests := []struct {
+		name  string
+		event any
+		want  bool
+	}{
+		{"root", events.AgentCreateEvent{AgentID: "agent1"}, true},
+		{"sub-agent", events.LLMRequestEvent{AgentID: "agent1_abc"}, true},
+		{"nested sub-agent", events.ToolExecStartEvent{AgentID: "agent1_abc_def"}, true},
+		{"other primary agent", events.AgentCreateEvent{AgentID: "agent2"}, false},
+		{"shared prefix", events.AgentCreateEvent{AgentID: "agent10"}, false},
+		{"sub-agent of a shared prefix", events.AgentCreateEvent{AgentID: "agent10_abc"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := inTree(test.event.(events.AgentEvent)); got != test.want {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestInAgentTree(t *testing.T) {
+	if !events.InAgentTree("agent0_a_b", "agent0_a") {
+		t.Error("expected a sub-agent to be in the tree of its parent sub-agent")
+	}
+	if events.InAgentTree("agent0_a", "agent0_a_b") {
+		t.Error("expected a parent not to be in the tree of its sub-agent")
+	}
+}
@@ -11,11 +11,23 @@ type TaskCreateEvent struct {
 	Task         string                    `json:"task"`
 	ToolSchemas  []llminterface.ToolSchema `json:"tool_schemas"`
 	SystemPrompt string                    `json:"system_prompt"`
-	Conversation []llminterface.Message    `json:"conversation"`
+	Conversation llminterface.MessageList  `json:"conversation"`
+	// RequestID is echoed by the TaskFinishEvent answering this task.
+	RequestID string `json:"request_id,omitempty"`
 }
 
+// TaskFinishEvent reports the result of a primary agent, sub-agents report
+// theirs with SubAgentFinishEvent.
 type TaskFinishEvent struct {
+	eventbus.BaseEvent
+	AgentID   string `json:"agent_id"`
+	Result    string `json:"result"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// TaskCancelEvent stops the primary agent AgentID and all of its sub-agents.
+type TaskCancelEvent struct {
 	eventbus.BaseEvent
 	AgentID string `json:"agent_id"`
-	Result  string `json:"result"`
+	Reason  string `json:"reason,omitempty"`
 }
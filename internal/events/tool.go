@@ -1,6 +1,9 @@
 package events
 
-import "agentlauncher/internal/eventbus"
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+)
 
 type ToolCall struct {
 	eventbus.BaseEvent
@@ -59,3 +62,27 @@ type ToolExecErrorEvent struct {
 	ToolName   string `json:"tool_name"`
 	Error      string `json:"error"`
 }
+
+type ToolExecQueuedEvent struct {
+	eventbus.BaseEvent
+	AgentID    string `json:"agent_id"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Limiter    string `json:"limiter"`
+}
+
+// ToolSchemasRequestEvent asks the tool runtime, possibly in another process,
+// for the schemas of every tool available to the primary agent AgentID.
+type ToolSchemasRequestEvent struct {
+	eventbus.BaseEvent
+	AgentID string `json:"agent_id"`
+}
+
+type ToolSchemasResponseEvent struct {
+	eventbus.BaseEvent
+	AgentID     string                    `json:"agent_id"`
+	ToolSchemas []llminterface.ToolSchema `json:"tool_schemas"`
+	// SubAgentsAtOnce is how many sub-agents the agent may run at once, 0
+	// if there is no limit.
+	SubAgentsAtOnce int `json:"sub_agents_at_once"`
+}
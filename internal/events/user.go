@@ -0,0 +1,45 @@
+package events
+
+import "agentlauncher/internal/eventbus"
+
+// ToolApprovalRequestEvent holds a tool call until the user answers with a
+// ToolApprovalResponseEvent for the same agent and tool call.
+type ToolApprovalRequestEvent struct {
+	eventbus.BaseEvent
+	AgentID    string         `json:"agent_id"`
+	ToolCallID string         `json:"tool_call_id"`
+	ToolName   string         `json:"tool_name"`
+	Arguments  map[string]any `json:"arguments"`
+}
+
+type ToolApprovalResponseEvent struct {
+	eventbus.BaseEvent
+	AgentID    string `json:"agent_id"`
+	ToolCallID string `json:"tool_call_id"`
+	Approved   bool   `json:"approved"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// UserInputRequestEvent asks the user a question on behalf of an agent, see
+// the ask_user tool.
+type UserInputRequestEvent struct {
+	eventbus.BaseEvent
+	AgentID   string `json:"agent_id"`
+	RequestID string `json:"request_id"`
+	Question  string `json:"question"`
+}
+
+type UserInputResponseEvent struct {
+	eventbus.BaseEvent
+	AgentID   string `json:"agent_id"`
+	RequestID string `json:"request_id"`
+	Answer    string `json:"answer"`
+}
+
+// UserFollowUpEvent adds a user message to a running agent's conversation,
+// sent with its next LLM request.
+type UserFollowUpEvent struct {
+	eventbus.BaseEvent
+	AgentID string `json:"agent_id"`
+	Content string `json:"content"`
+}
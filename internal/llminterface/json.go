@@ -0,0 +1,143 @@
+package llminterface
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Messages are encoded as a flat object with a "role" discriminator, e.g.
+// {"role":"tool_call","tool_call_id":"call_1","tool_name":"add","arguments":{}}.
+const (
+	RoleUser       = "user"
+	RoleSystem     = "system"
+	RoleAssistant  = "assistant"
+	RoleToolCall   = "tool_call"
+	RoleToolResult = "tool_result"
+)
+
+func Role(msg Message) string {
+	switch msg.(type) {
+	case UserMessage, *UserMessage:
+		return RoleUser
+	case SystemMessage, *SystemMessage:
+		return RoleSystem
+	case AssistantMessage, *AssistantMessage:
+		return RoleAssistant
+	case ToolCallMessage, *ToolCallMessage:
+		return RoleToolCall
+	case ToolResultMessage, *ToolResultMessage:
+		return RoleToolResult
+	}
+	return ""
+}
+
+type userMessage UserMessage
+type systemMessage SystemMessage
+type assistantMessage AssistantMessage
+type toolCallMessage ToolCallMessage
+type toolResultMessage ToolResultMessage
+
+func (m UserMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Role string `json:"role"`
+		userMessage
+	}{RoleUser, userMessage(m)})
+}
+
+func (m SystemMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Role string `json:"role"`
+		systemMessage
+	}{RoleSystem, systemMessage(m)})
+}
+
+func (m AssistantMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Role string `json:"role"`
+		assistantMessage
+	}{RoleAssistant, assistantMessage(m)})
+}
+
+func (m ToolCallMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Role string `json:"role"`
+		toolCallMessage
+	}{RoleToolCall, toolCallMessage(m)})
+}
+
+func (m ToolResultMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Role string `json:"role"`
+		toolResultMessage
+	}{RoleToolResult, toolResultMessage(m)})
+}
+
+func UnmarshalMessage(data []byte) (Message, error) {
+	var header struct {
+		Role string `json:"role"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	switch header.Role {
+	case RoleUser:
+		var m userMessage
+		err := json.Unmarshal(data, &m)
+		return UserMessage(m), err
+	case RoleSystem:
+		var m systemMessage
+		err := json.Unmarshal(data, &m)
+		return SystemMessage(m), err
+	case RoleAssistant:
+		var m assistantMessage
+		err := json.Unmarshal(data, &m)
+		return AssistantMessage(m), err
+	case RoleToolCall:
+		var m toolCallMessage
+		err := json.Unmarshal(data, &m)
+		return ToolCallMessage(m), err
+	case RoleToolResult:
+		var m toolResultMessage
+		err := json.Unmarshal(data, &m)
+		return ToolResultMessage(m), err
+	}
+	return nil, fmt.Errorf("unknown message role %q", header.Role)
+}
+
+func unmarshalMessages(data []byte) ([]Message, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	if items == nil {
+		return nil, nil
+	}
+	messages := make([]Message, len(items))
+	for i, item := range items {
+		msg, err := UnmarshalMessage(item)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i, err)
+		}
+		messages[i] = msg
+	}
+	return messages, nil
+}
+
+func (l *MessageList) UnmarshalJSON(data []byte) error {
+	messages, err := unmarshalMessages(data)
+	*l = messages
+	return err
+}
+
+func (l *RequestMessageList) UnmarshalJSON(data []byte) error {
+	messages, err := unmarshalMessages(data)
+	*l = messages
+	return err
+}
+
+func (l *ResponseMessageList) UnmarshalJSON(data []byte) error {
+	messages, err := unmarshalMessages(data)
+	*l = messages
+	return err
+}
@@ -0,0 +1,115 @@
+package llminterface_test
+
+import (
+	"agentlauncher/internal/llminterface"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func conversation() []llminterface.Message {
+	return []llminterface.Message{
+		llminterface.SystemMessage{Content: "be brief"},
+		llminterface.UserMessage{Content: "add 1 and 2"},
+		llminterface.AssistantMessage{Content: "adding"},
+		llminterface.ToolCallMessage{
+			ToolCallID: "call_1",
+			ToolName:   "add",
+			Arguments: map[string]any{
+				"a":       1.5,
+				"b":       "2",
+				"options": map[string]any{"round": true, "digits": []any{1.0, 2.0}},
+				"none":    nil,
+			},
+		},
+		llminterface.ToolResultMessage{ToolCallID: "call_1", ToolName: "add", Result: "3.5"},
+	}
+}
+
+// roundTrip encodes in and decodes it into a new value of the same type.
+func roundTrip[T any](t *testing.T, in T) T {
+	t.Helper()
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	return out
+}
+
+func TestMessageListRoundTrip(t *testing.T) {
+	in := llminterface.MessageList(conversation())
+	if out := roundTrip(t, in); !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %#v, got %#v", in, out)
+	}
+}
+
+func TestRequestMessageListRoundTrip(t *testing.T) {
+	in := llminterface.RequestMessageList(conversation())
+	if out := roundTrip(t, in); !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %#v, got %#v", in, out)
+	}
+}
+
+func TestResponseMessageListRoundTrip(t *testing.T) {
+	in := llminterface.ResponseMessageList(conversation()[2:4])
+	out := roundTrip(t, in)
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %#v, got %#v", in, out)
+	}
+	for _, msg := range out {
+		if !msg.IsResponse() {
+			t.Errorf("expected %T to be a response", msg)
+		}
+	}
+}
+
+func TestMessageListInStruct(t *testing.T) {
+	type event struct {
+		Conversation llminterface.MessageList `json:"conversation"`
+		Empty        llminterface.MessageList `json:"empty"`
+	}
+	in := event{Conversation: conversation()}
+	if out := roundTrip(t, in); !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %#v, got %#v", in, out)
+	}
+}
+
+func TestToolCallArguments(t *testing.T) {
+	data, err := json.Marshal(conversation()[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"role":"tool_call"`) {
+		t.Errorf("expected the role discriminator, got %s", data)
+	}
+	msg, err := llminterface.UnmarshalMessage(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arguments := msg.(llminterface.ToolCallMessage).Arguments
+	if arguments["a"] != 1.5 || arguments["b"] != "2" || arguments["none"] != nil {
+		t.Errorf("unexpected arguments %#v", arguments)
+	}
+	options := arguments["options"].(map[string]any)
+	if options["round"] != true || !reflect.DeepEqual(options["digits"], []any{1.0, 2.0}) {
+		t.Errorf("unexpected nested arguments %#v", options)
+	}
+}
+
+func TestUnmarshalMessageErrors(t *testing.T) {
+	for _, data := range []string{`{"role":"robot","content":"hi"}`, `{}`, `[1]`} {
+		if _, err := llminterface.UnmarshalMessage([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+	var list llminterface.MessageList
+	err := json.Unmarshal([]byte(`[{"role":"user","content":"hi"},{"role":"robot"}]`), &list)
+	if err == nil || !strings.Contains(err.Error(), "message 1") {
+		t.Errorf("expected the index of the bad message, got %v", err)
+	}
+}
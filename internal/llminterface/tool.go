@@ -6,6 +6,7 @@ type ToolParamSchema struct {
 	Description string         `json:"description"`
 	Required    bool           `json:"required"`
 	Items       map[string]any `json:"items,omitempty"`
+	Enum        []string       `json:"enum,omitempty"`
 }
 
 type ToolSchema struct {
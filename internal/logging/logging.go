@@ -0,0 +1,137 @@
+// Package logging builds the *slog.Logger shared by the launcher, the event
+// bus and the runtimes. Each of them logs through a child logger tagged with
+// its subsystem, so levels can be tuned per subsystem.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const SUBSYSTEM_KEY = "subsystem"
+
+const (
+	EVENTBUS = "eventbus"
+	LAUNCHER = "launcher"
+	AGENT    = "agent"
+	LLM      = "llm"
+	TOOL     = "tool"
+	MESSAGE  = "message"
+)
+
+// LevelTrace sits below Debug. The event bus logs full event payloads at it.
+const LevelTrace = slog.LevelDebug - 4
+
+type Format string
+
+const (
+	TEXT Format = "text"
+	JSON Format = "json"
+)
+
+type Config struct {
+	Format Format
+	Level  slog.Level
+	// Levels overrides Level for individual subsystems, e.g. {LLM: slog.LevelDebug}.
+	Levels map[string]slog.Level
+	// Output defaults to os.Stderr.
+	Output io.Writer
+}
+
+func New(cfg Config) *slog.Logger {
+	output := cfg.Output
+	if output == nil {
+		output = os.Stderr
+	}
+
+	lowest := cfg.Level
+	for _, level := range cfg.Levels {
+		lowest = min(lowest, level)
+	}
+	options := &slog.HandlerOptions{
+		Level: lowest,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.LevelKey && len(groups) == 0 {
+				if level, ok := attr.Value.Any().(slog.Level); ok && level <= LevelTrace {
+					attr.Value = slog.StringValue("TRACE")
+				}
+			}
+			return attr
+		},
+	}
+
+	var handler slog.Handler
+	if cfg.Format == JSON {
+		handler = slog.NewJSONHandler(output, options)
+	} else {
+		handler = slog.NewTextHandler(output, options)
+	}
+	return slog.New(&levelHandler{
+		next:   handler,
+		level:  cfg.Level,
+		levels: cfg.Levels,
+	})
+}
+
+// ParseLevel parses a level name as slog does, plus "trace".
+func ParseLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "trace") {
+		return LevelTrace, nil
+	}
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
+
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// Subsystem returns logger tagged with the subsystem name, or a discarding
+// logger when logger is nil.
+func Subsystem(logger *slog.Logger, name string) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger.With(SUBSYSTEM_KEY, name)
+}
+
+// levelHandler applies the level of the subsystem a logger was tagged with.
+type levelHandler struct {
+	next      slog.Handler
+	level     slog.Level
+	levels    map[string]slog.Level
+	subsystem string
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	minimum := h.level
+	if subsystemLevel, exists := h.levels[h.subsystem]; exists {
+		minimum = subsystemLevel
+	}
+	return level >= minimum && h.next.Enabled(ctx, level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.next.Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	child := *h
+	for _, attr := range attrs {
+		if attr.Key == SUBSYSTEM_KEY {
+			child.subsystem = attr.Value.String()
+		}
+	}
+	child.next = h.next.WithAttrs(attrs)
+	return &child
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	child := *h
+	child.next = h.next.WithGroup(name)
+	return &child
+}
@@ -0,0 +1,135 @@
+package logging_test
+
+import (
+	"agentlauncher/internal/logging"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(logging.Config{
+		Level:  slog.LevelInfo,
+		Levels: map[string]slog.Level{logging.LLM: slog.LevelDebug, logging.TOOL: slog.LevelWarn},
+		Output: &out,
+	})
+
+	for _, check := range []struct {
+		subsystem string
+		level     slog.Level
+		logged    bool
+	}{
+		{"", slog.LevelDebug, false},
+		{"", slog.LevelInfo, true},
+		{logging.LLM, slog.LevelDebug, true},
+		{logging.LLM, logging.LevelTrace, false},
+		{logging.AGENT, slog.LevelDebug, false},
+		{logging.AGENT, slog.LevelInfo, true},
+		{logging.TOOL, slog.LevelInfo, false},
+		{logging.TOOL, slog.LevelWarn, true},
+	} {
+		out.Reset()
+		l := logger
+		if check.subsystem != "" {
+			l = logging.Subsystem(logger, check.subsystem)
+		}
+		l.Log(context.Background(), check.level, "message")
+		if logged := out.Len() > 0; logged != check.logged {
+			t.Errorf("%q at %v: expected logged=%v, got %q", check.subsystem, check.level, check.logged, out.String())
+		}
+		if check.logged && check.subsystem != "" && !strings.Contains(out.String(), "subsystem="+check.subsystem) {
+			t.Errorf("%q at %v: expected the subsystem attribute, got %q", check.subsystem, check.level, out.String())
+		}
+	}
+}
+
+func TestLevelHandlerFollowsDerivedLoggers(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(logging.Config{
+		Level:  slog.LevelWarn,
+		Levels: map[string]slog.Level{logging.EVENTBUS: slog.LevelDebug},
+		Output: &out,
+	})
+
+	// The subsystem is found among other attributes, and survives groups
+	// and further attributes.
+	bus := logger.With("component", "bus", logging.SUBSYSTEM_KEY, logging.EVENTBUS).
+		WithGroup("event").
+		With("type", "ping")
+	if !bus.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug enabled for the event bus subsystem")
+	}
+	bus.Debug("dispatched")
+	if !strings.Contains(out.String(), "event.type=ping") {
+		t.Errorf("expected the grouped attribute, got %q", out.String())
+	}
+
+	// A subsystem without its own level falls back to the default one.
+	agent := logging.Subsystem(bus, logging.AGENT)
+	if agent.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("expected info disabled for the agent subsystem")
+	}
+	if !agent.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("expected warn enabled for the agent subsystem")
+	}
+}
+
+func TestTraceLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(logging.Config{Level: logging.LevelTrace, Output: &out})
+	logger.Log(context.Background(), logging.LevelTrace, "payload")
+	if !strings.Contains(out.String(), "level=TRACE") {
+		t.Errorf("expected the trace level named TRACE, got %q", out.String())
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New(logging.Config{Format: logging.JSON, Level: slog.LevelInfo, Output: &out})
+	logging.Subsystem(logger, logging.TOOL).Info("tool call finished", "tool_name", "add")
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expected a JSON line, got %q: %v", out.String(), err)
+	}
+	if record["subsystem"] != logging.TOOL || record["tool_name"] != "add" || record["msg"] != "tool call finished" {
+		t.Errorf("unexpected record %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, check := range []struct {
+		name  string
+		level slog.Level
+		fails bool
+	}{
+		{name: "trace", level: logging.LevelTrace},
+		{name: "TRACE", level: logging.LevelTrace},
+		{name: "debug", level: slog.LevelDebug},
+		{name: "info", level: slog.LevelInfo},
+		{name: "WARN", level: slog.LevelWarn},
+		{name: "error", level: slog.LevelError},
+		{name: "info+2", level: slog.LevelInfo + 2},
+		{name: "loud", fails: true},
+	} {
+		level, err := logging.ParseLevel(check.name)
+		if (err != nil) != check.fails {
+			t.Errorf("%q: unexpected error %v", check.name, err)
+			continue
+		}
+		if !check.fails && level != check.level {
+			t.Errorf("%q: expected %v, got %v", check.name, check.level, level)
+		}
+	}
+}
+
+func TestSubsystemOfNilLogger(t *testing.T) {
+	logger := logging.Subsystem(nil, logging.LLM)
+	if logger == nil || logger.Enabled(context.Background(), slog.LevelError) {
+		t.Error("expected a discarding logger")
+	}
+}
@@ -0,0 +1,307 @@
+// Package mcp is a minimal Model Context Protocol client for tool servers
+// that speak JSON-RPC over stdio.
+package mcp
+
+import (
+	"agentlauncher/internal/llminterface"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const PROTOCOL_VERSION = "2025-06-18"
+
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema struct {
+		Properties map[string]map[string]any `json:"properties"`
+		Required   []string                  `json:"required"`
+	} `json:"inputSchema"`
+}
+
+// Parameters converts the tool's JSON schema to tool parameters, sorted by
+// name. Nested object schemas are reduced to their type.
+func (t Tool) Parameters() []llminterface.ToolParamSchema {
+	required := make(map[string]bool)
+	for _, name := range t.InputSchema.Required {
+		required[name] = true
+	}
+	params := make([]llminterface.ToolParamSchema, 0, len(t.InputSchema.Properties))
+	for name, property := range t.InputSchema.Properties {
+		param := llminterface.ToolParamSchema{
+			Name:     name,
+			Type:     schemaType(property["type"]),
+			Required: required[name],
+		}
+		param.Description, _ = property["description"].(string)
+		if items, ok := property["items"].(map[string]any); ok {
+			param.Items = items
+		}
+		if values, ok := property["enum"].([]any); ok {
+			for _, value := range values {
+				param.Enum = append(param.Enum, fmt.Sprint(value))
+			}
+		}
+		params = append(params, param)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// schemaType picks the first non-null type of a JSON schema type, which may
+// be a list.
+func schemaType(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []any:
+		for _, item := range v {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	return "string"
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type Client struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	nextID  atomic.Int64
+	pending map[int64]chan message
+	done    chan struct{}
+	err     error
+	mu      sync.Mutex
+	writeMu sync.Mutex
+}
+
+// Start runs the server command with env added to the environment and
+// completes the initialization handshake. The server's stderr goes to
+// stderr, which may be nil.
+func Start(ctx context.Context, command []string, env map[string]string, stderr io.Writer) (*Client, error) {
+	if len(command) == 0 {
+		return nil, errors.New("mcp: empty command")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = os.Environ()
+	for name, value := range env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("mcp: failed to start %s: %w", command[0], err)
+	}
+
+	c := &Client{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan message),
+		done:    make(chan struct{}),
+	}
+	go c.read(stdout)
+
+	var initialized struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	err = c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": PROTOCOL_VERSION,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "agentlauncher", "version": "1.0.0"},
+	}, &initialized)
+	if err == nil {
+		err = c.send(message{JSONRPC: "2.0", Method: "notifications/initialized"})
+	}
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp: initialize %s: %w", command[0], err)
+	}
+	return c, nil
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	tools := []Tool{}
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, err
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool returns the text content of the tool's result. A result the
+// server flags as an error is returned as one.
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (string, error) {
+	var result struct {
+		Content []map[string]any `json:"content"`
+		IsError bool             `json:"isError"`
+	}
+	err := c.call(ctx, "tools/call", map[string]any{
+		"name":      name,
+		"arguments": arguments,
+	}, &result)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(result.Content))
+	for _, content := range result.Content {
+		if text, ok := content["text"].(string); ok && content["type"] == "text" {
+			parts = append(parts, text)
+			continue
+		}
+		data, _ := json.Marshal(content)
+		parts = append(parts, string(data))
+	}
+	text := strings.Join(parts, "\n")
+	if result.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// Close ends the server's stdin and kills it if it does not exit soon after.
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	return nil
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	id := c.nextID.Add(1)
+	responses := make(chan message, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.pending[id] = responses
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(message{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return err
+	}
+	select {
+	case response := <-responses:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(response.Result, result)
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.send(message{JSONRPC: "2.0", Method: "notifications/cancelled", Params: map[string]any{"requestId": id}})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) send(m message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (c *Client) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			continue
+		}
+		switch {
+		case m.ID != nil && m.Method != "":
+			// A request from the server, only pings are supported.
+			response := message{JSONRPC: "2.0", ID: m.ID, Result: json.RawMessage("{}")}
+			if m.Method != "ping" {
+				response = message{JSONRPC: "2.0", ID: m.ID, Error: &rpcError{Code: -32601, Message: "method not found"}}
+			}
+			c.send(response)
+		case m.ID != nil:
+			c.mu.Lock()
+			responses, exists := c.pending[*m.ID]
+			c.mu.Unlock()
+			if exists {
+				responses <- m
+			}
+		}
+	}
+
+	err := c.cmd.Wait()
+	c.mu.Lock()
+	c.err = errors.New("mcp: server exited")
+	if err != nil {
+		c.err = fmt.Errorf("mcp: server exited: %w", err)
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
@@ -0,0 +1,150 @@
+package mcp_test
+
+import (
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/mcp"
+	"agentlauncher/internal/mcp/mcptest"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var server = mcptest.Server{
+	Tools: []map[string]any{
+		{"name": "echo", "description": "Echo the text", "inputSchema": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"text":   map[string]any{"type": "string", "description": "The text"},
+				"times":  map[string]any{"type": []any{"null", "integer"}},
+				"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				"format": map[string]any{"enum": []any{"plain", "upper"}},
+			},
+			"required": []any{"text"},
+		}},
+		{"name": "fail"},
+		{"name": "hang"},
+		{"name": "exit"},
+	},
+	PageSize: 3,
+	Call: func(name string, arguments map[string]any) (*mcptest.Result, error) {
+		switch name {
+		case "echo":
+			return &mcptest.Result{Text: fmt.Sprint(arguments["text"])}, nil
+		case "fail":
+			return &mcptest.Result{Text: "it broke", IsError: true}, nil
+		case "hang":
+			return nil, nil
+		case "exit":
+			os.Exit(1)
+		}
+		return nil, errors.New("unknown tool " + name)
+	},
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mcptest.SERVE_ENV) != "" {
+		server.Serve(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func start(t *testing.T) *mcp.Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := mcp.Start(ctx, mcptest.Command(), mcptest.Env(), os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return client
+}
+
+func TestListTools(t *testing.T) {
+	client := start(t)
+	tools, err := client.ListTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"echo", "fail", "hang", "exit"}) {
+		t.Errorf("expected the tools of every page, got %v", names)
+	}
+}
+
+func TestToolParameters(t *testing.T) {
+	client := start(t)
+	tools, err := client.ListTools(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []llminterface.ToolParamSchema{
+		{Name: "format", Type: "string", Enum: []string{"plain", "upper"}},
+		{Name: "tags", Type: "array", Items: map[string]any{"type": "string"}},
+		{Name: "text", Type: "string", Description: "The text", Required: true},
+		{Name: "times", Type: "integer"},
+	}
+	if params := tools[0].Parameters(); !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %+v, got %+v", expected, params)
+	}
+	if params := tools[1].Parameters(); len(params) != 0 {
+		t.Errorf("expected no parameters without a schema, got %+v", params)
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	client := start(t)
+	ctx := context.Background()
+
+	result, err := client.CallTool(ctx, "echo", map[string]any{"text": "hello"})
+	if err != nil || result != "hello" {
+		t.Errorf("expected the tool's text, got %q, %v", result, err)
+	}
+	if _, err := client.CallTool(ctx, "fail", nil); err == nil || err.Error() != "it broke" {
+		t.Errorf("expected the error result as an error, got %v", err)
+	}
+	if _, err := client.CallTool(ctx, "missing", nil); err == nil || err.Error() != "mcp error -32000: unknown tool missing" {
+		t.Errorf("expected the JSON-RPC error, got %v", err)
+	}
+}
+
+func TestCallToolCancelled(t *testing.T) {
+	client := start(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.CallTool(ctx, "hang", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+	if result, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "still up"}); err != nil || result != "still up" {
+		t.Errorf("expected the client to keep working, got %q, %v", result, err)
+	}
+}
+
+func TestServerExit(t *testing.T) {
+	client := start(t)
+	if _, err := client.CallTool(context.Background(), "exit", nil); err == nil {
+		t.Fatal("expected an error once the server exits")
+	}
+	if _, err := client.ListTools(context.Background()); err == nil {
+		t.Error("expected calls to fail after the server exited")
+	}
+}
+
+func TestStartFails(t *testing.T) {
+	if _, err := mcp.Start(context.Background(), nil, nil, nil); err == nil {
+		t.Error("expected an error for an empty command")
+	}
+	if _, err := mcp.Start(context.Background(), []string{"/nonexistent/mcp-server"}, nil, nil); err == nil {
+		t.Error("expected an error for a missing command")
+	}
+}
@@ -0,0 +1,122 @@
+// Package mcptest serves a fake MCP tool server over stdio for tests. Test
+// binaries run it as a subprocess of themselves:
+//
+//	func TestMain(m *testing.M) {
+//		if os.Getenv(mcptest.SERVE_ENV) != "" {
+//			server.Serve(os.Stdin, os.Stdout)
+//			os.Exit(0)
+//		}
+//		os.Exit(m.Run())
+//	}
+//
+// and start it with mcp.Start(ctx, mcptest.Command(), mcptest.Env(), nil).
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// SERVE_ENV is set in the environment of the subprocess that serves.
+const SERVE_ENV = "MCPTEST_SERVE"
+
+// Result is the result of a tools/call request.
+type Result struct {
+	Text    string
+	IsError bool
+}
+
+type Server struct {
+	// Tools are listed as they are, e.g. {"name": "echo", "inputSchema": {...}}.
+	Tools []map[string]any
+	// PageSize splits tools/list into pages of this many tools.
+	PageSize int
+	// Call answers tools/call. An error is returned as a JSON-RPC error and
+	// a nil result leaves the request unanswered.
+	Call func(name string, arguments map[string]any) (*Result, error)
+}
+
+// Command runs the current test binary, which serves when SERVE_ENV is set.
+func Command() []string {
+	return []string{os.Args[0]}
+}
+
+func Env() map[string]string {
+	return map[string]string{SERVE_ENV: "1"}
+}
+
+type request struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params struct {
+		Cursor    string         `json:"cursor"`
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"params"`
+}
+
+// Serve answers the requests read from in until it is closed.
+func (s Server) Serve(in io.Reader, out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	reply := func(id *json.RawMessage, result any, err error) error {
+		response := map[string]any{"jsonrpc": "2.0", "id": id, "result": result}
+		if err != nil {
+			response = map[string]any{"jsonrpc": "2.0", "id": id, "error": map[string]any{"code": -32000, "message": err.Error()}}
+		}
+		return encoder.Encode(response)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var r request
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.ID == nil {
+			continue
+		}
+		var err error
+		switch r.Method {
+		case "initialize":
+			err = reply(r.ID, map[string]any{
+				"protocolVersion": "2025-06-18",
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "mcptest", "version": "1.0.0"},
+			}, nil)
+		case "tools/list":
+			err = reply(r.ID, s.page(r.Params.Cursor), nil)
+		case "tools/call":
+			result, callErr := s.Call(r.Params.Name, r.Params.Arguments)
+			switch {
+			case callErr != nil:
+				err = reply(r.ID, nil, callErr)
+			case result != nil:
+				err = reply(r.ID, map[string]any{
+					"content": []map[string]any{{"type": "text", "text": result.Text}},
+					"isError": result.IsError,
+				}, nil)
+			}
+		default:
+			err = encoder.Encode(map[string]any{"jsonrpc": "2.0", "id": r.ID, "error": map[string]any{"code": -32601, "message": "method not found"}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// page returns the tools from cursor, the index of the first one.
+func (s Server) page(cursor string) map[string]any {
+	start := 0
+	json.Unmarshal([]byte(cursor), &start)
+	end := len(s.Tools)
+	if s.PageSize > 0 && start+s.PageSize < end {
+		end = start + s.PageSize
+	}
+	page := map[string]any{"tools": s.Tools[start:end]}
+	if end < len(s.Tools) {
+		cursor, _ := json.Marshal(end)
+		page["nextCursor"] = string(cursor)
+	}
+	return page
+}
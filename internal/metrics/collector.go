@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/runtimes"
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Collector derives runtime metrics from the events dispatched on a bus.
+type Collector struct {
+	events       Counter
+	llmDuration  Histogram
+	llmFailures  Counter
+	llmRetries   Counter
+	llmTokens    Counter
+	toolDuration Histogram
+	toolCalls    Counter
+	toolQueued   Counter
+	activeAgents Gauge
+	subAgents    Counter
+	handlerTime  Histogram
+	// agents are the agents counted in activeAgents.
+	agents      map[string]bool
+	llmStarted  map[string]time.Time
+	toolStarted map[toolCall]time.Time
+	now         func() time.Time
+	mu          sync.Mutex
+}
+
+type toolCall struct {
+	agentID    string
+	toolCallID string
+}
+
+func NewCollector(m Metrics) *Collector {
+	return &Collector{
+		events:       m.Counter("agentlauncher_events_total", "Events dispatched on the bus.", "type"),
+		llmDuration:  m.Histogram("agentlauncher_llm_call_duration_seconds", "Duration of LLM calls.", DefaultBuckets, "route", "status"),
+		llmFailures:  m.Counter("agentlauncher_llm_failures_total", "Failed LLM calls, per route attempt.", "route"),
+		llmRetries:   m.Counter("agentlauncher_llm_retries_total", "LLM requests retried after every route failed."),
+		llmTokens:    m.Counter("agentlauncher_llm_tokens_total", "Tokens reported by LLM handlers.", "model", "kind"),
+		toolDuration: m.Histogram("agentlauncher_tool_call_duration_seconds", "Duration of tool calls.", DefaultBuckets, "tool", "status"),
+		toolCalls:    m.Counter("agentlauncher_tool_calls_total", "Finished tool calls.", "tool", "status"),
+		toolQueued:   m.Counter("agentlauncher_tool_calls_queued_total", "Tool calls that waited for a concurrency limit.", "tool", "limiter"),
+		activeAgents: m.Gauge("agentlauncher_active_agents", "Agents that have been created and not finished.", "kind"),
+		subAgents:    m.Counter("agentlauncher_sub_agents_spawned_total", "Sub-agents created.", "profile"),
+		handlerTime:  m.Histogram("agentlauncher_event_handler_duration_seconds", "Duration of event handler calls.", DefaultBuckets, "event_type"),
+		agents:       make(map[string]bool),
+		llmStarted:   make(map[string]time.Time),
+		toolStarted:  make(map[toolCall]time.Time),
+		now:          time.Now,
+	}
+}
+
+// Install collects metrics from eb, including its queue depth and worker
+// utilization, which are read from m when it is collected.
+func Install(eb *eventbus.EventBus, m Metrics) *Collector {
+	c := NewCollector(m)
+	m.GaugeFunc("agentlauncher_event_queue_depth", "Events waiting for the dispatcher.", func() float64 {
+		return float64(eb.Stats().QueueDepth)
+	})
+	m.GaugeFunc("agentlauncher_event_queue_capacity", "Capacity of the event queue.", func() float64 {
+		return float64(eb.Stats().QueueCapacity)
+	})
+	m.GaugeFunc("agentlauncher_event_backlog", "Handler calls waiting for a free worker.", func() float64 {
+		return float64(eb.Stats().Backlog)
+	})
+	m.GaugeFunc("agentlauncher_workers", "Event bus workers.", func() float64 {
+		return float64(eb.Stats().Workers)
+	})
+	m.GaugeFunc("agentlauncher_workers_busy", "Event bus workers running a handler.", func() float64 {
+		return float64(eb.Stats().BusyWorkers)
+	})
+	m.GaugeFunc("agentlauncher_event_handlers_running", "Event handler calls in progress, including ordered deliveries.", func() float64 {
+		return float64(eb.Stats().RunningHandlers)
+	})
+	m.GaugeFunc("agentlauncher_event_partitions", "Agents with ordered event deliveries in progress.", func() float64 {
+		return float64(eb.Stats().Partitions)
+	})
+	m.GaugeFunc("agentlauncher_worker_utilization", "Share of event bus workers running a handler.", func() float64 {
+		stats := eb.Stats()
+		if stats.Workers == 0 {
+			return 0
+		}
+		return float64(stats.BusyWorkers) / float64(stats.Workers)
+	})
+	eb.Use(c.intercept)
+	eb.WrapHandlers(eventbus.TimingMiddleware(func(name string, event eventbus.Event, duration time.Duration) {
+		c.handlerTime.Observe(duration.Seconds(), eventType(event))
+	}))
+	return c
+}
+
+func (c *Collector) intercept(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+	c.events.Add(1, eventType(event))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch e := event.(type) {
+	case events.AgentCreateEvent:
+		// A create for an agent that already runs is refused.
+		if c.agents[e.AgentID] {
+			break
+		}
+		c.agents[e.AgentID] = true
+		c.activeAgents.Add(1, agentKind(e.AgentID))
+		if !runtimes.IsPrimaryAgent(e.AgentID) {
+			c.subAgents.Add(1, e.Profile)
+		}
+
+	case events.TaskFinishEvent:
+		c.agentFinished(e.AgentID)
+
+	case events.SubAgentFinishEvent:
+		c.agentFinished(e.AgentID)
+
+	case events.LLMRequestEvent:
+		if e.RetryCount > 0 {
+			c.llmRetries.Add(1)
+		}
+		c.llmStarted[e.AgentID] = c.now()
+
+	case events.LLMResponseEvent:
+		if started, exists := c.llmStarted[e.AgentID]; exists {
+			c.llmDuration.Observe(c.now().Sub(started).Seconds(), e.Route, "ok")
+			delete(c.llmStarted, e.AgentID)
+		}
+
+	case events.LLMRuntimeErrorEvent:
+		if started, exists := c.llmStarted[e.AgentID]; exists {
+			c.llmDuration.Observe(c.now().Sub(started).Seconds(), "", "error")
+			delete(c.llmStarted, e.AgentID)
+		}
+
+	case events.LLMRouteFailedEvent:
+		c.llmFailures.Add(1, e.Route)
+
+	case events.LLMUsageEvent:
+		c.llmTokens.Add(float64(e.InputTokens), e.Model, "input")
+		c.llmTokens.Add(float64(e.OutputTokens), e.Model, "output")
+
+	case events.ToolExecQueuedEvent:
+		c.toolQueued.Add(1, e.ToolName, e.Limiter)
+
+	case events.ToolExecStartEvent:
+		c.toolStarted[toolCall{e.AgentID, e.ToolCallID}] = c.now()
+
+	case events.ToolExecFinishEvent:
+		c.finishTool(e.AgentID, e.ToolCallID, e.ToolName, "ok")
+
+	case events.ToolExecErrorEvent:
+		c.finishTool(e.AgentID, e.ToolCallID, e.ToolName, "error")
+
+	// Tool calls of a cancelled task or a failed tool runtime may never
+	// report how they ended.
+	case events.TaskCancelEvent:
+		c.forgetTools(func(agentID string) bool { return events.InAgentTree(agentID, e.AgentID) })
+
+	case events.ToolRuntimeErrorEvent:
+		c.forgetTools(func(agentID string) bool { return agentID == e.AgentID })
+	}
+	return event, true
+}
+
+func (c *Collector) agentFinished(agentID string) {
+	if c.agents[agentID] {
+		c.activeAgents.Add(-1, agentKind(agentID))
+		delete(c.agents, agentID)
+	}
+	delete(c.llmStarted, agentID)
+	c.forgetTools(func(id string) bool { return id == agentID })
+}
+
+func (c *Collector) finishTool(agentID, toolCallID, toolName, status string) {
+	c.toolCalls.Add(1, toolName, status)
+	key := toolCall{agentID, toolCallID}
+	if started, exists := c.toolStarted[key]; exists {
+		c.toolDuration.Observe(c.now().Sub(started).Seconds(), toolName, status)
+		delete(c.toolStarted, key)
+	}
+}
+
+// forgetTools stops timing the tool calls of the agents matching agentIDs.
+func (c *Collector) forgetTools(agentIDs func(string) bool) {
+	for key := range c.toolStarted {
+		if agentIDs(key.agentID) {
+			delete(c.toolStarted, key)
+		}
+	}
+}
+
+func eventType(event eventbus.Event) string {
+	return reflect.TypeOf(event).Name()
+}
+
+func agentKind(agentID string) string {
+	if runtimes.IsPrimaryAgent(agentID) {
+		return "primary"
+	}
+	return "sub"
+}
@@ -0,0 +1,116 @@
+package metrics_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/metrics"
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares the text format of reg with testdata/name.
+func assertGolden(t *testing.T, reg *metrics.Registry, name string) {
+	t.Helper()
+	var got bytes.Buffer
+	if _, err := reg.WriteTo(&got); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("%s differs, run with -update to accept\ngot:\n%s", name, got.String())
+	}
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestCollector(t *testing.T) {
+	reg := metrics.NewRegistry()
+	clock := &clock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := metrics.NewCollector(reg).WithClock(clock.Now)
+
+	for _, step := range []struct {
+		event eventbus.Event
+		after time.Duration
+	}{
+		{event: events.AgentCreateEvent{AgentID: "agent0"}},
+		{event: events.LLMRequestEvent{AgentID: "agent0"}, after: 2 * time.Second},
+		{event: events.LLMUsageEvent{AgentID: "agent0", Model: "model-a", InputTokens: 120, OutputTokens: 30}},
+		{event: events.LLMResponseEvent{AgentID: "agent0", Route: "main"}},
+		{event: events.AgentCreateEvent{AgentID: "agent0_1", Profile: "researcher"}},
+		{event: events.ToolExecQueuedEvent{AgentID: "agent0", ToolCallID: "call1", ToolName: "add", Limiter: "add"}},
+		{event: events.ToolExecStartEvent{AgentID: "agent0", ToolCallID: "call1", ToolName: "add"}, after: 300 * time.Millisecond},
+		{event: events.ToolExecFinishEvent{AgentID: "agent0", ToolCallID: "call1", ToolName: "add"}},
+		{event: events.ToolExecStartEvent{AgentID: "agent0_1", ToolCallID: "call2", ToolName: "search"}, after: 40 * time.Millisecond},
+		{event: events.ToolExecErrorEvent{AgentID: "agent0_1", ToolCallID: "call2", ToolName: "search", Error: "no results"}},
+		{event: events.ToolExecStartEvent{AgentID: "agent0_1", ToolCallID: "call3", ToolName: "search"}},
+		{event: events.LLMRequestEvent{AgentID: "agent0"}},
+		{event: events.LLMRouteFailedEvent{AgentID: "agent0", Route: "main", Attempt: 1, Error: "timeout"}},
+		{event: events.LLMRequestEvent{AgentID: "agent0", RetryCount: 1}, after: 5 * time.Second},
+		{event: events.LLMRuntimeErrorEvent{AgentID: "agent0", Error: "every route failed"}},
+		{event: events.TaskCancelEvent{AgentID: "agent0"}},
+		{event: events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "Error: Task cancelled"}},
+		{event: events.TaskFinishEvent{AgentID: "agent0", Result: "Error: Task cancelled"}},
+	} {
+		c.Intercept(step.event)
+		clock.advance(step.after)
+	}
+
+	if llm, tool := c.OpenCalls(); llm != 0 || tool != 0 {
+		t.Errorf("expected no calls left open, got %d LLM and %d tool calls", llm, tool)
+	}
+	assertGolden(t, reg, "collector.golden")
+}
+
+func TestCollectorForgetsAbandonedToolCalls(t *testing.T) {
+	for name, ending := range map[string]eventbus.Event{
+		"task cancelled":      events.TaskCancelEvent{AgentID: "agent0"},
+		"tool runtime failed": events.ToolRuntimeErrorEvent{AgentID: "agent0_1", Error: "crashed"},
+		"agent finished":      events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "Error: Agent not found"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := metrics.NewCollector(metrics.Nop)
+			c.Intercept(events.AgentCreateEvent{AgentID: "agent0_1"})
+			c.Intercept(events.ToolExecStartEvent{AgentID: "agent0_1", ToolCallID: "call1", ToolName: "search"})
+			c.Intercept(ending)
+			if _, tool := c.OpenCalls(); tool != 0 {
+				t.Errorf("expected the tool call to be forgotten, %d still timed", tool)
+			}
+		})
+	}
+}
+
+func TestCollectorKeepsOtherAgentsToolCalls(t *testing.T) {
+	c := metrics.NewCollector(metrics.Nop)
+	c.Intercept(events.ToolExecStartEvent{AgentID: "agent1", ToolCallID: "call1", ToolName: "search"})
+	c.Intercept(events.ToolExecStartEvent{AgentID: "agent10", ToolCallID: "call1", ToolName: "search"})
+	c.Intercept(events.TaskCancelEvent{AgentID: "agent0"})
+	c.Intercept(events.ToolRuntimeErrorEvent{AgentID: "agent1_1", Error: "crashed"})
+	if _, tool := c.OpenCalls(); tool != 2 {
+		t.Errorf("expected the tool calls of other agents to be kept, %d timed", tool)
+	}
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"agentlauncher/internal/eventbus"
+	"context"
+	"time"
+)
+
+// WithClock times calls with now instead of the wall clock.
+func (c *Collector) WithClock(now func() time.Time) *Collector {
+	c.now = now
+	return c
+}
+
+// Intercept feeds event to the collector as the bus it is installed on does.
+func (c *Collector) Intercept(event eventbus.Event) {
+	c.intercept(context.Background(), event)
+}
+
+// OpenCalls returns how many LLM and tool calls are being timed.
+func (c *Collector) OpenCalls() (llm, tool int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.llmStarted), len(c.toolStarted)
+}
@@ -0,0 +1,39 @@
+// Package metrics defines the instruments the runtimes report to, and a
+// Prometheus text-format implementation of them.
+package metrics
+
+type Metrics interface {
+	Counter(name, help string, labels ...string) Counter
+	Gauge(name, help string, labels ...string) Gauge
+	// GaugeFunc reports the value of fn each time metrics are collected.
+	GaugeFunc(name, help string, fn func() float64)
+	Histogram(name, help string, buckets []float64, labels ...string) Histogram
+}
+
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+type Gauge interface {
+	Set(value float64, labelValues ...string)
+	Add(delta float64, labelValues ...string)
+}
+
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+type nop struct{}
+
+func (nop) Counter(string, string, ...string) Counter                { return nop{} }
+func (nop) Gauge(string, string, ...string) Gauge                    { return nop{} }
+func (nop) GaugeFunc(string, string, func() float64)                 {}
+func (nop) Histogram(string, string, []float64, ...string) Histogram { return nop{} }
+func (nop) Add(float64, ...string)                                   {}
+func (nop) Set(float64, ...string)                                   {}
+func (nop) Observe(float64, ...string)                               {}
+
+// Nop discards everything.
+var Nop Metrics = nop{}
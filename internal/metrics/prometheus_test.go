@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"agentlauncher/internal/metrics"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryTextFormat(t *testing.T) {
+	reg := metrics.NewRegistry()
+	requests := reg.Counter("requests_total", "Requests served.", "method", "path")
+	requests.Add(1, "GET", "/")
+	requests.Add(2, "GET", "/")
+	requests.Add(1, "POST", `/say "hi"\n`)
+	reg.Counter("unlabelled_total", "A counter\nwith a second line.").Add(0.5)
+
+	inFlight := reg.Gauge("in_flight", "Requests in flight.", "kind")
+	inFlight.Set(3, "sub")
+	inFlight.Add(-1, "sub")
+	inFlight.Add(1, "primary")
+	reg.GaugeFunc("queue_depth", "Read when collected.", func() float64 { return 7 })
+
+	latency := reg.Histogram("latency_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "main")
+	latency.Observe(0.5, "main")
+	latency.Observe(3, "main")
+	latency.Observe(1, "backup")
+
+	assertGolden(t, reg, "registry.golden")
+}
+
+func TestRegistryServesTextFormat(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Counter("requests_total", "Requests served.").Add(1)
+
+	recorder := httptest.NewRecorder()
+	reg.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+	body, _ := io.ReadAll(recorder.Body)
+	if !strings.Contains(string(body), "requests_total 1\n") {
+		t.Errorf("expected the counter in the response, got\n%s", body)
+	}
+}
+
+func TestRegistryRejectsKindChange(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.Counter("requests_total", "Requests served.")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a gauge under a counter's name to panic")
+		}
+	}()
+	reg.Gauge("requests_total", "Requests served.")
+}
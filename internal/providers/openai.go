@@ -0,0 +1,171 @@
+// Package providers contains LLMHandler implementations for hosted models.
+package providers
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+const DEFAULT_OPENAI_MODEL = "gpt-4.1"
+
+// OpenAIConfig configures a handler for the OpenAI Chat Completions API or
+// any compatible server. Empty BaseURL and APIKey fall back to the
+// OPENAI_BASE_URL and OPENAI_API_KEY environment variables.
+type OpenAIConfig struct {
+	BaseURL     string
+	APIKey      string
+	Model       string
+	Temperature *float64
+	Options     []option.RequestOption
+}
+
+func NewOpenAI(config OpenAIConfig) llminterface.LLMHandler {
+	options := []option.RequestOption{}
+	if config.BaseURL != "" {
+		options = append(options, option.WithBaseURL(config.BaseURL))
+	}
+	if config.APIKey != "" {
+		options = append(options, option.WithAPIKey(config.APIKey))
+	}
+	options = append(options, config.Options...)
+	client := openai.NewClient(options...)
+	model := config.Model
+	if model == "" {
+		model = DEFAULT_OPENAI_MODEL
+	}
+
+	return func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		params := openai.ChatCompletionNewParams{
+			Model:    model,
+			Messages: toOpenAIMessages(messages),
+			Tools:    toOpenAITools(tools),
+		}
+		if config.Temperature != nil {
+			params.Temperature = openai.Float(*config.Temperature)
+		}
+		completion, err := client.Chat.Completions.New(context.Background(), params)
+		if err != nil {
+			panic(err)
+		}
+		if len(completion.Choices) == 0 {
+			panic(fmt.Errorf("openai: response has no choices"))
+		}
+		eb.Emit(events.LLMUsageEvent{
+			AgentID:      agentID,
+			Model:        completion.Model,
+			InputTokens:  int(completion.Usage.PromptTokens),
+			OutputTokens: int(completion.Usage.CompletionTokens),
+		})
+		return fromOpenAIMessage(completion.Choices[0].Message)
+	}
+}
+
+func toOpenAIMessages(messages llminterface.RequestMessageList) []openai.ChatCompletionMessageParamUnion {
+	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(messages))
+	// Tool calls follow the assistant message they belong to, if any.
+	var assistant *openai.ChatCompletionAssistantMessageParam
+	flush := func() {
+		if assistant != nil {
+			result = append(result, openai.ChatCompletionMessageParamUnion{OfAssistant: assistant})
+			assistant = nil
+		}
+	}
+
+	for _, message := range messages {
+		switch m := message.(type) {
+		case llminterface.SystemMessage:
+			flush()
+			result = append(result, openai.SystemMessage(m.Content))
+		case llminterface.UserMessage:
+			flush()
+			result = append(result, openai.UserMessage(m.Content))
+		case llminterface.AssistantMessage:
+			flush()
+			assistant = &openai.ChatCompletionAssistantMessageParam{}
+			if m.Content != "" {
+				assistant.Content.OfString = openai.String(m.Content)
+			}
+		case llminterface.ToolCallMessage:
+			if assistant == nil {
+				assistant = &openai.ChatCompletionAssistantMessageParam{}
+			}
+			arguments, _ := json.Marshal(m.Arguments)
+			assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+				OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+					ID: m.ToolCallID,
+					Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{
+						Name:      m.ToolName,
+						Arguments: string(arguments),
+					},
+				},
+			})
+		case llminterface.ToolResultMessage:
+			flush()
+			result = append(result, openai.ToolMessage(m.Result, m.ToolCallID))
+		}
+	}
+	flush()
+	return result
+}
+
+func toOpenAITools(tools llminterface.RequestToolList) []openai.ChatCompletionToolUnionParam {
+	result := make([]openai.ChatCompletionToolUnionParam, len(tools))
+	for i, tool := range tools {
+		properties := make(map[string]any)
+		required := []string{}
+		for _, param := range tool.Parameters {
+			property := map[string]any{
+				"type":        param.Type,
+				"description": param.Description,
+			}
+			if param.Type == "array" && param.Items != nil {
+				property["items"] = param.Items
+			}
+			if len(param.Enum) > 0 {
+				property["enum"] = param.Enum
+			}
+			properties[param.Name] = property
+			if param.Required {
+				required = append(required, param.Name)
+			}
+		}
+		result[i] = openai.ChatCompletionToolUnionParam{
+			OfFunction: &openai.ChatCompletionFunctionToolParam{
+				Function: openai.FunctionDefinitionParam{
+					Name:        tool.Name,
+					Description: openai.String(tool.Description),
+					Parameters: openai.FunctionParameters{
+						"type":       "object",
+						"properties": properties,
+						"required":   required,
+					},
+				},
+			},
+		}
+	}
+	return result
+}
+
+func fromOpenAIMessage(message openai.ChatCompletionMessage) llminterface.ResponseMessageList {
+	response := llminterface.ResponseMessageList{}
+	if message.Content != "" {
+		response = append(response, llminterface.AssistantMessage{Content: message.Content})
+	}
+	for _, toolCall := range message.ToolCalls {
+		var arguments map[string]any
+		json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments)
+		response = append(response, llminterface.ToolCallMessage{
+			ToolCallID: toolCall.ID,
+			ToolName:   toolCall.Function.Name,
+			Arguments:  arguments,
+		})
+	}
+	return response
+}
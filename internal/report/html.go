@@ -0,0 +1,185 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+type timelineRow struct {
+	Label  string
+	Kind   string
+	Status Status
+	Depth  int
+	Offset float64
+	Width  float64
+	Title  string
+}
+
+type timelinePage struct {
+	Report *Report
+	Rows   []timelineRow
+	Ticks  []timelineTick
+}
+
+type timelineTick struct {
+	Offset float64
+	Label  string
+}
+
+// WriteHTML writes a self-contained HTML page with a Gantt-style timeline of
+// every agent, LLM call and tool call, so overlapping sub-agents are visible
+// at a glance.
+func (r *Report) WriteHTML(w io.Writer) error {
+	page := timelinePage{Report: r}
+	for _, agent := range r.Agents {
+		page.Rows = r.appendRows(page.Rows, agent, 0)
+	}
+	for i := 0; i <= 4; i++ {
+		page.Ticks = append(page.Ticks, timelineTick{
+			Offset: float64(i) * 25,
+			Label:  formatDuration(r.Duration * time.Duration(i) / 4),
+		})
+	}
+	return timelineTemplate.Execute(w, page)
+}
+
+func (r *Report) appendRows(rows []timelineRow, agent *Agent, depth int) []timelineRow {
+	title := fmt.Sprintf("%s\nstatus: %s\nduration: %s\ntask: %s", agent.ID, agent.Status, formatDuration(agent.Duration), agent.Task)
+	if agent.Error != "" {
+		title += "\nerror: " + agent.Error
+	}
+	label := agent.ID
+	if agent.Profile != "" {
+		label += " (" + agent.Profile + ")"
+	}
+	rows = append(rows, r.row(label, "agent", agent.Status, depth, agent.Start, agent.End, title))
+
+	for i, call := range agent.LLMCalls {
+		title := fmt.Sprintf("LLM turn %d\nstatus: %s\nduration: %s", i+1, call.Status, formatDuration(call.Duration))
+		if call.Model != "" {
+			title += fmt.Sprintf("\nmodel: %s\ntokens: %d in, %d out", call.Model, call.InputTokens, call.OutputTokens)
+		}
+		if call.Route != "" {
+			title += "\nroute: " + call.Route
+		}
+		if call.RetryCount > 0 {
+			title += fmt.Sprintf("\nretry: %d", call.RetryCount)
+		}
+		for _, failure := range call.RouteFailures {
+			title += fmt.Sprintf("\nroute %s failed: %s", failure.Route, failure.Error)
+		}
+		if call.Error != "" {
+			title += "\nerror: " + call.Error
+		}
+		rows = append(rows, r.row(fmt.Sprintf("llm #%d", i+1), "llm", call.Status, depth+1, call.Start, call.End, title))
+	}
+
+	for _, call := range agent.ToolCalls {
+		arguments, _ := json.Marshal(call.Arguments)
+		title := fmt.Sprintf("%s (%s)\nstatus: %s\nduration: %s\narguments: %s", call.Name, call.ID, call.Status, formatDuration(call.Duration), arguments)
+		if !call.Queued.IsZero() {
+			title += fmt.Sprintf("\nqueued on %s for %s", call.Limiter, formatDuration(call.Start.Sub(call.Queued)))
+		}
+		if call.Error != "" {
+			title += "\nerror: " + call.Error
+		} else if call.Result != "" {
+			title += "\nresult: " + truncate(call.Result, 500)
+		}
+		rows = append(rows, r.row(call.Name, "tool", call.Status, depth+1, call.Start, call.End, title))
+	}
+
+	for _, subAgent := range agent.SubAgents {
+		rows = r.appendRows(rows, subAgent, depth+1)
+	}
+	return rows
+}
+
+func (r *Report) row(label, kind string, status Status, depth int, start, end time.Time, title string) timelineRow {
+	if end.IsZero() {
+		end = r.End
+	}
+	row := timelineRow{
+		Label:  label,
+		Kind:   kind,
+		Status: status,
+		Depth:  depth,
+		Title:  title,
+		Width:  100,
+	}
+	if r.Duration > 0 {
+		row.Offset = 100 * float64(start.Sub(r.Start)) / float64(r.Duration)
+		row.Width = 100 * float64(end.Sub(start)) / float64(r.Duration)
+	}
+	return row
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "…"
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"indent": func(depth int) string {
+		return fmt.Sprintf("%.1fem", float64(depth)*1.2)
+	},
+	"percent": func(value float64) string {
+		return fmt.Sprintf("%.3f%%", value)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Run report {{.Report.Start.Format "2006-01-02 15:04:05"}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 1.5em; color: #222; }
+h1 { font-size: 1.3em; margin: 0 0 .3em; }
+.meta { color: #666; margin-bottom: 1em; }
+table.totals { border-collapse: collapse; margin-bottom: 1.5em; }
+table.totals td { padding: .15em 1em .15em 0; }
+table.totals td:nth-child(odd) { color: #666; }
+.timeline { display: grid; grid-template-columns: minmax(14em, max-content) 1fr; font-size: 13px; }
+.label { white-space: nowrap; overflow: hidden; text-overflow: ellipsis; padding: 2px .5em 2px 0; border-bottom: 1px solid #f0f0f0; }
+.track { position: relative; border-bottom: 1px solid #f0f0f0; border-left: 1px solid #ddd; }
+.bar { position: absolute; top: 3px; bottom: 3px; min-width: 2px; border-radius: 3px; }
+.bar.agent { background: #9db4d8; }
+.bar.llm { background: #f0b86e; }
+.bar.tool { background: #8ccf9a; }
+.bar.running { opacity: .5; background-image: repeating-linear-gradient(45deg, transparent 0 4px, rgba(255,255,255,.6) 4px 8px); }
+.bar.failed { background: #e27c7c; }
+.label.agent { font-weight: 600; }
+.label.llm, .label.tool { color: #555; }
+.axis { position: relative; height: 1.4em; color: #888; font-size: 11px; }
+.axis span { position: absolute; transform: translateX(-50%); }
+.axis span:first-child { transform: none; }
+.axis span:last-child { transform: translateX(-100%); }
+.legend span { display: inline-block; width: .9em; height: .9em; border-radius: 2px; margin: 0 .3em 0 1em; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Run report</h1>
+<div class="meta">{{.Report.Start.Format "2006-01-02 15:04:05.000"}} &ndash; {{.Report.End.Format "15:04:05.000"}} ({{duration .Report.Duration}})
+<span class="legend"><span class="bar agent" style="position:static"></span>agent<span class="bar llm" style="position:static"></span>LLM call<span class="bar tool" style="position:static"></span>tool call<span class="bar failed" style="position:static"></span>failed</span></div>
+{{with .Report.Totals}}<table class="totals">
+<tr><td>agents</td><td>{{.Agents}} ({{.SubAgents}} sub-agents, {{.FailedAgents}} failed)</td><td>LLM calls</td><td>{{.LLMCalls}} ({{.LLMFailures}} failed, {{.Retries}} retries, {{.RouteFailures}} route failures)</td></tr>
+<tr><td>tool calls</td><td>{{.ToolCalls}} ({{.ToolErrors}} failed)</td><td>tokens</td><td>{{.InputTokens}} in, {{.OutputTokens}} out</td></tr>
+<tr><td>LLM time</td><td>{{duration .LLMTime}}</td><td>tool time</td><td>{{duration .ToolTime}}</td></tr>
+<tr><td>errors</td><td>{{.Errors}}</td><td></td><td></td></tr>
+</table>{{end}}
+<div class="timeline">
+<div></div><div class="axis">{{range .Ticks}}<span style="left:{{percent .Offset}}">{{.Label}}</span>{{end}}</div>
+{{range .Rows}}<div class="label {{.Kind}}" style="padding-left:{{indent .Depth}}" title="{{.Title}}">{{.Label}}</div><div class="track"><div class="bar {{.Kind}} {{.Status}}" style="left:{{percent .Offset}};width:{{percent .Width}}" title="{{.Title}}"></div></div>
+{{end}}</div>
+</body>
+</html>
+`))
@@ -0,0 +1,442 @@
+// Package report reconstructs a run from its event stream: the tree of
+// agents and sub-agents, each LLM turn and tool call with its timing, errors,
+// retries and totals. A report can be written as JSON or as a self-contained
+// HTML timeline.
+package report
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventcodec"
+	"agentlauncher/internal/eventlog"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/runtimes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Status string
+
+const (
+	RUNNING  Status = "running"
+	FINISHED Status = "finished"
+	FAILED   Status = "failed"
+)
+
+// Durations are encoded in nanoseconds, like time.Duration.
+type Report struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Agents   []*Agent      `json:"agents"`
+	Totals   Totals        `json:"totals"`
+}
+
+type Agent struct {
+	ID        string        `json:"id"`
+	ParentID  string        `json:"parent_id,omitempty"`
+	Profile   string        `json:"profile,omitempty"`
+	Task      string        `json:"task"`
+	Status    Status        `json:"status"`
+	Result    string        `json:"result,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end,omitzero"`
+	Duration  time.Duration `json:"duration"`
+	LLMCalls  []LLMCall     `json:"llm_calls"`
+	ToolCalls []ToolCall    `json:"tool_calls"`
+	Errors    []string      `json:"errors,omitempty"`
+	SubAgents []*Agent      `json:"sub_agents,omitempty"`
+}
+
+type LLMCall struct {
+	Status        Status         `json:"status"`
+	RetryCount    int            `json:"retry_count"`
+	Route         string         `json:"route,omitempty"`
+	Model         string         `json:"model,omitempty"`
+	InputTokens   int            `json:"input_tokens,omitempty"`
+	OutputTokens  int            `json:"output_tokens,omitempty"`
+	RouteFailures []RouteFailure `json:"route_failures,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end,omitzero"`
+	Duration      time.Duration  `json:"duration"`
+}
+
+type RouteFailure struct {
+	Route   string `json:"route"`
+	Attempt int    `json:"attempt"`
+	Error   string `json:"error"`
+}
+
+type ToolCall struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Status    Status         `json:"status"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Result    string         `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Limiter   string         `json:"limiter,omitempty"`
+	Queued    time.Time      `json:"queued,omitzero"`
+	Start     time.Time      `json:"start"`
+	End       time.Time      `json:"end,omitzero"`
+	Duration  time.Duration  `json:"duration"`
+}
+
+type Totals struct {
+	Agents        int           `json:"agents"`
+	SubAgents     int           `json:"sub_agents"`
+	FailedAgents  int           `json:"failed_agents"`
+	LLMCalls      int           `json:"llm_calls"`
+	LLMFailures   int           `json:"llm_failures"`
+	Retries       int           `json:"retries"`
+	RouteFailures int           `json:"route_failures"`
+	ToolCalls     int           `json:"tool_calls"`
+	ToolErrors    int           `json:"tool_errors"`
+	InputTokens   int           `json:"input_tokens"`
+	OutputTokens  int           `json:"output_tokens"`
+	Errors        int           `json:"errors"`
+	LLMTime       time.Duration `json:"llm_time"`
+	ToolTime      time.Duration `json:"tool_time"`
+}
+
+// Builder accumulates events into a report. It can watch a live bus with
+// Install, or be fed recorded events with Observe.
+type Builder struct {
+	agents    map[string]*Agent
+	llmCalls  map[string]int
+	toolCalls map[string]int
+	start     time.Time
+	end       time.Time
+	mu        sync.Mutex
+}
+
+func NewBuilder() *Builder {
+	return &Builder{
+		agents:    make(map[string]*Agent),
+		llmCalls:  make(map[string]int),
+		toolCalls: make(map[string]int),
+	}
+}
+
+func (b *Builder) Install(eb *eventbus.EventBus) {
+	eb.Use(b.intercept)
+}
+
+func (b *Builder) intercept(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+	b.Observe(time.Now(), event)
+	return event, true
+}
+
+// Observe applies an event that happened at the given time.
+func (b *Builder) Observe(at time.Time, event eventbus.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.start.IsZero() || at.Before(b.start) {
+		b.start = at
+	}
+	if at.After(b.end) {
+		b.end = at
+	}
+
+	switch e := event.(type) {
+	case events.TaskCreateEvent:
+		b.agent(e.AgentID, at).Task = e.Task
+
+	case events.AgentCreateEvent:
+		agent := b.agent(e.AgentID, at)
+		agent.Task = e.Task
+		agent.Profile = e.Profile
+
+	case events.AgentFinishEvent:
+		agent := b.agent(e.AgentID, at)
+		agent.Result = e.Result
+		finishAgent(agent, at, FINISHED)
+
+	case events.AgentRuntimeErrorEvent:
+		agent := b.agent(e.AgentID, at)
+		agent.Errors = append(agent.Errors, e.Error)
+
+	case events.TaskFinishEvent:
+		b.finish(e.AgentID, e.Result, at)
+
+	case events.SubAgentFinishEvent:
+		b.finish(e.AgentID, e.Result, at)
+
+	case events.LLMRequestEvent:
+		agent := b.agent(e.AgentID, at)
+		b.llmCalls[e.AgentID] = len(agent.LLMCalls)
+		agent.LLMCalls = append(agent.LLMCalls, LLMCall{
+			Status:     RUNNING,
+			RetryCount: e.RetryCount,
+			Start:      at,
+		})
+
+	case events.LLMRouteFailedEvent:
+		if call := b.llmCall(e.AgentID); call != nil {
+			call.RouteFailures = append(call.RouteFailures, RouteFailure{
+				Route:   e.Route,
+				Attempt: e.Attempt,
+				Error:   e.Error,
+			})
+		}
+
+	case events.LLMUsageEvent:
+		if call := b.llmCall(e.AgentID); call != nil {
+			call.Model = e.Model
+			call.InputTokens += e.InputTokens
+			call.OutputTokens += e.OutputTokens
+		}
+
+	case events.LLMResponseEvent:
+		if call := b.llmCall(e.AgentID); call != nil {
+			call.Route = e.Route
+		}
+		b.endLLMCall(e.AgentID, at, "")
+
+	case events.LLMRuntimeErrorEvent:
+		b.endLLMCall(e.AgentID, at, e.Error)
+
+	case events.ToolExecQueuedEvent:
+		call := b.toolCall(e.AgentID, e.ToolCallID, e.ToolName, at)
+		call.Queued = at
+		call.Limiter = e.Limiter
+
+	case events.ToolExecStartEvent:
+		call := b.toolCall(e.AgentID, e.ToolCallID, e.ToolName, at)
+		call.Start = at
+		call.Arguments = e.Arguments
+
+	case events.ToolExecFinishEvent:
+		call := b.toolCall(e.AgentID, e.ToolCallID, e.ToolName, at)
+		call.Result = e.Result
+		endToolCall(call, at, "")
+
+	case events.ToolExecErrorEvent:
+		call := b.toolCall(e.AgentID, e.ToolCallID, e.ToolName, at)
+		endToolCall(call, at, e.Error)
+
+	case events.ToolRuntimeErrorEvent:
+		agent := b.agent(e.AgentID, at)
+		agent.Errors = append(agent.Errors, e.Error)
+	}
+}
+
+func (b *Builder) agent(agentID string, at time.Time) *Agent {
+	agent, exists := b.agents[agentID]
+	if !exists {
+		agent = &Agent{
+			ID:        agentID,
+			ParentID:  runtimes.GetParentAgentID(agentID),
+			Status:    RUNNING,
+			Start:     at,
+			LLMCalls:  []LLMCall{},
+			ToolCalls: []ToolCall{},
+		}
+		b.agents[agentID] = agent
+	}
+	return agent
+}
+
+func (b *Builder) llmCall(agentID string) *LLMCall {
+	index, exists := b.llmCalls[agentID]
+	if !exists {
+		return nil
+	}
+	return &b.agents[agentID].LLMCalls[index]
+}
+
+func (b *Builder) endLLMCall(agentID string, at time.Time, errorMessage string) {
+	call := b.llmCall(agentID)
+	if call == nil {
+		return
+	}
+	delete(b.llmCalls, agentID)
+	call.End = at
+	call.Duration = at.Sub(call.Start)
+	call.Status = FINISHED
+	if errorMessage != "" {
+		call.Status = FAILED
+		call.Error = errorMessage
+	}
+}
+
+func (b *Builder) toolCall(agentID, toolCallID, toolName string, at time.Time) *ToolCall {
+	agent := b.agent(agentID, at)
+	key := agentID + "/" + toolCallID
+	index, exists := b.toolCalls[key]
+	if !exists {
+		index = len(agent.ToolCalls)
+		b.toolCalls[key] = index
+		agent.ToolCalls = append(agent.ToolCalls, ToolCall{
+			ID:     toolCallID,
+			Name:   toolName,
+			Status: RUNNING,
+			Start:  at,
+		})
+	}
+	return &agent.ToolCalls[index]
+}
+
+func endToolCall(call *ToolCall, at time.Time, errorMessage string) {
+	call.End = at
+	call.Duration = at.Sub(call.Start)
+	call.Status = FINISHED
+	if errorMessage != "" {
+		call.Status = FAILED
+		call.Error = errorMessage
+	}
+}
+
+// finish records the result reported for an agent. The caller holds b.mu.
+func (b *Builder) finish(agentID, result string, at time.Time) {
+	agent := b.agent(agentID, at)
+	if trimmed := strings.TrimSpace(result); strings.HasPrefix(trimmed, "Error: ") {
+		agent.Error = strings.TrimPrefix(trimmed, "Error: ")
+		b.endLLMCall(agentID, at, agent.Error)
+		finishAgent(agent, at, FAILED)
+		return
+	}
+	if agent.Result == "" {
+		agent.Result = result
+	}
+	finishAgent(agent, at, FINISHED)
+}
+
+// finishAgent ends a running agent. A failure reported once it has ended,
+// e.g. the error result of an agent that finished its turn, still marks it
+// failed.
+func finishAgent(agent *Agent, at time.Time, status Status) {
+	if agent.Status == RUNNING {
+		agent.End = at
+		agent.Duration = at.Sub(agent.Start)
+	} else if status != FAILED {
+		return
+	}
+	agent.Status = status
+}
+
+// Report returns a snapshot of everything observed so far. Agents, LLM calls
+// and tool calls that have not ended yet are reported as running.
+func (b *Builder) Report() *Report {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	report := &Report{
+		Start:    b.start,
+		End:      b.end,
+		Duration: b.end.Sub(b.start),
+		Agents:   []*Agent{},
+	}
+
+	agents := make(map[string]*Agent, len(b.agents))
+	for id, agent := range b.agents {
+		snapshot := *agent
+		snapshot.LLMCalls = slices.Clone(agent.LLMCalls)
+		for i := range snapshot.LLMCalls {
+			snapshot.LLMCalls[i].RouteFailures = slices.Clone(snapshot.LLMCalls[i].RouteFailures)
+		}
+		snapshot.ToolCalls = slices.Clone(agent.ToolCalls)
+		snapshot.Errors = slices.Clone(agent.Errors)
+		snapshot.SubAgents = nil
+		agents[id] = &snapshot
+	}
+
+	for _, agent := range agents {
+		if parent, exists := agents[agent.ParentID]; exists {
+			parent.SubAgents = append(parent.SubAgents, agent)
+		} else {
+			report.Agents = append(report.Agents, agent)
+		}
+	}
+	for _, agent := range agents {
+		sortAgents(agent.SubAgents)
+		report.Totals.add(agent)
+	}
+	sortAgents(report.Agents)
+	return report
+}
+
+func sortAgents(agents []*Agent) {
+	slices.SortFunc(agents, func(a, b *Agent) int {
+		if c := a.Start.Compare(b.Start); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+}
+
+func (t *Totals) add(agent *Agent) {
+	t.Agents++
+	if !runtimes.IsPrimaryAgent(agent.ID) {
+		t.SubAgents++
+	}
+	if agent.Status == FAILED {
+		t.FailedAgents++
+	}
+	t.Errors += len(agent.Errors)
+	for _, call := range agent.LLMCalls {
+		t.LLMCalls++
+		if call.Status == FAILED {
+			t.LLMFailures++
+			t.Errors++
+		}
+		if call.RetryCount > 0 {
+			t.Retries++
+		}
+		t.RouteFailures += len(call.RouteFailures)
+		t.InputTokens += call.InputTokens
+		t.OutputTokens += call.OutputTokens
+		t.LLMTime += call.Duration
+	}
+	for _, call := range agent.ToolCalls {
+		t.ToolCalls++
+		if call.Status == FAILED {
+			t.ToolErrors++
+			t.Errors++
+		}
+		t.ToolTime += call.Duration
+	}
+}
+
+// FromLog builds a report from a recorded event log, using the recorded
+// timestamps.
+func FromLog(backend eventlog.Backend, registry *eventcodec.Registry) (*Report, error) {
+	entries, err := eventlog.ReadAll(backend, registry)
+	if err != nil {
+		return nil, err
+	}
+	builder := NewBuilder()
+	for _, entry := range entries {
+		builder.Observe(entry.Time, entry.Decoded)
+	}
+	return builder.Report(), nil
+}
+
+// Find returns the agent with the given ID anywhere in the tree.
+func (r *Report) Find(agentID string) *Agent {
+	var find func([]*Agent) *Agent
+	find = func(agents []*Agent) *Agent {
+		for _, agent := range agents {
+			if agent.ID == agentID {
+				return agent
+			}
+			if found := find(agent.SubAgents); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return find(r.Agents)
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,220 @@
+package report_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventlog"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/report"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// at returns the time ms milliseconds into the run.
+func at(ms int) time.Time {
+	return start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+type observed struct {
+	ms    int
+	event eventbus.Event
+}
+
+func build(steps ...observed) *report.Report {
+	builder := report.NewBuilder()
+	for _, step := range steps {
+		builder.Observe(at(step.ms), step.event)
+	}
+	return builder.Report()
+}
+
+func TestReportTree(t *testing.T) {
+	r := build(
+		observed{0, events.TaskCreateEvent{AgentID: "agent0", Task: "sum things"}},
+		observed{1, events.AgentCreateEvent{AgentID: "agent0", Task: "sum things"}},
+		observed{2, events.LLMRequestEvent{AgentID: "agent0"}},
+		observed{50, events.LLMRouteFailedEvent{AgentID: "agent0", Route: "fast", Attempt: 1, Error: "timeout"}},
+		observed{100, events.LLMUsageEvent{AgentID: "agent0", Model: "model-a", InputTokens: 100, OutputTokens: 20}},
+		observed{102, events.LLMResponseEvent{AgentID: "agent0", Route: "main"}},
+		observed{103, events.ToolExecStartEvent{AgentID: "agent0", ToolCallID: "call1", ToolName: "create_sub_agent"}},
+		observed{104, events.AgentCreateEvent{AgentID: "agent0_1", Task: "add", Profile: "math"}},
+		observed{105, events.LLMRequestEvent{AgentID: "agent0_1"}},
+		observed{110, events.LLMRuntimeErrorEvent{AgentID: "agent0_1", Error: "overloaded"}},
+		observed{120, events.LLMRequestEvent{AgentID: "agent0_1", RetryCount: 1}},
+		observed{130, events.LLMResponseEvent{AgentID: "agent0_1", Route: "main"}},
+		observed{131, events.ToolExecQueuedEvent{AgentID: "agent0_1", ToolCallID: "call2", ToolName: "add", Limiter: "add"}},
+		observed{140, events.ToolExecStartEvent{AgentID: "agent0_1", ToolCallID: "call2", ToolName: "add", Arguments: map[string]any{"a": 1.0}}},
+		observed{150, events.ToolExecErrorEvent{AgentID: "agent0_1", ToolCallID: "call2", ToolName: "add", Error: "missing b"}},
+		observed{160, events.AgentFinishEvent{AgentID: "agent0_1", Result: "3"}},
+		observed{161, events.AgentDeletedEvent{AgentID: "agent0_1"}},
+		observed{162, events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "3"}},
+		observed{163, events.ToolExecFinishEvent{AgentID: "agent0", ToolCallID: "call1", ToolName: "create_sub_agent", Result: "3"}},
+		observed{200, events.AgentFinishEvent{AgentID: "agent0", Result: "the sum is 3"}},
+		observed{201, events.AgentDeletedEvent{AgentID: "agent0"}},
+		observed{202, events.TaskFinishEvent{AgentID: "agent0", Result: "the sum is 3"}},
+	)
+
+	if len(r.Agents) != 1 || len(r.Agents[0].SubAgents) != 1 {
+		t.Fatalf("expected a primary agent with one sub-agent, got %+v", r.Agents)
+	}
+	primary, sub := r.Agents[0], r.Agents[0].SubAgents[0]
+	if primary.Status != report.FINISHED || primary.Result != "the sum is 3" || primary.Duration != 200*time.Millisecond {
+		t.Errorf("unexpected primary agent %+v", primary)
+	}
+	if sub.ParentID != "agent0" || sub.Profile != "math" || sub.Status != report.FINISHED || sub.End != at(160) {
+		t.Errorf("unexpected sub-agent %+v", sub)
+	}
+	if r.Find("agent0_1") != sub || r.Find("agent9") != nil {
+		t.Error("Find does not walk the tree")
+	}
+
+	llm := primary.LLMCalls[0]
+	if llm.Route != "main" || llm.Model != "model-a" || llm.Duration != 100*time.Millisecond || len(llm.RouteFailures) != 1 {
+		t.Errorf("unexpected LLM call %+v", llm)
+	}
+	if failed := sub.LLMCalls[0]; failed.Status != report.FAILED || failed.Error != "overloaded" {
+		t.Errorf("unexpected failed LLM call %+v", failed)
+	}
+	add := sub.ToolCalls[0]
+	if add.Status != report.FAILED || add.Limiter != "add" || add.Queued != at(131) || add.Start != at(140) || add.Duration != 10*time.Millisecond {
+		t.Errorf("unexpected tool call %+v", add)
+	}
+
+	expected := report.Totals{
+		Agents:        2,
+		SubAgents:     1,
+		LLMCalls:      3,
+		LLMFailures:   1,
+		Retries:       1,
+		RouteFailures: 1,
+		ToolCalls:     2,
+		ToolErrors:    1,
+		InputTokens:   100,
+		OutputTokens:  20,
+		Errors:        2,
+		LLMTime:       115 * time.Millisecond,
+		ToolTime:      70 * time.Millisecond,
+	}
+	if r.Totals != expected {
+		t.Errorf("expected totals %+v, got %+v", expected, r.Totals)
+	}
+	if r.Start != at(0) || r.Duration != 202*time.Millisecond {
+		t.Errorf("unexpected run span %v for %v", r.Start, r.Duration)
+	}
+}
+
+// The runtime deletes an agent before it reports the result, the result
+// decides how the agent ended.
+func TestFailedAgents(t *testing.T) {
+	for name, steps := range map[string][]observed{
+		"cancelled": {
+			{0, events.AgentCreateEvent{AgentID: "agent0"}},
+			{1, events.AgentCreateEvent{AgentID: "agent0_1"}},
+			{5, events.AgentDeletedEvent{AgentID: "agent0"}},
+			{5, events.AgentDeletedEvent{AgentID: "agent0_1"}},
+			{6, events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "Error: Task cancelled"}},
+			{6, events.TaskFinishEvent{AgentID: "agent0", Result: "Error: Task cancelled"}},
+		},
+		"failed": {
+			{0, events.AgentCreateEvent{AgentID: "agent0"}},
+			{1, events.AgentCreateEvent{AgentID: "agent0_1"}},
+			{2, events.AgentRuntimeErrorEvent{AgentID: "agent0_1", Error: "Task cancelled"}},
+			{3, events.AgentDeletedEvent{AgentID: "agent0_1"}},
+			{4, events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "Error: Task cancelled"}},
+			{5, events.AgentDeletedEvent{AgentID: "agent0"}},
+			{6, events.TaskFinishEvent{AgentID: "agent0", Result: "\nError: Task cancelled"}},
+		},
+		"finished with an error result": {
+			{0, events.AgentCreateEvent{AgentID: "agent0"}},
+			{1, events.AgentCreateEvent{AgentID: "agent0_1"}},
+			{2, events.AgentFinishEvent{AgentID: "agent0_1", Result: "Error: Task cancelled"}},
+			{3, events.SubAgentFinishEvent{AgentID: "agent0_1", Result: "Error: Task cancelled"}},
+			{4, events.AgentFinishEvent{AgentID: "agent0", Result: "Error: Task cancelled"}},
+			{6, events.TaskFinishEvent{AgentID: "agent0", Result: "Error: Task cancelled"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := build(steps...)
+			for _, agentID := range []string{"agent0", "agent0_1"} {
+				agent := r.Find(agentID)
+				if agent.Status != report.FAILED || agent.Error != "Task cancelled" || agent.End.IsZero() {
+					t.Errorf("expected %s failed, got %+v", agentID, agent)
+				}
+			}
+			if r.Totals.FailedAgents != 2 {
+				t.Errorf("expected 2 failed agents, got %d", r.Totals.FailedAgents)
+			}
+		})
+	}
+}
+
+func TestCancelEndsRunningLLMCall(t *testing.T) {
+	r := build(
+		observed{0, events.AgentCreateEvent{AgentID: "agent0"}},
+		observed{1, events.LLMRequestEvent{AgentID: "agent0"}},
+		observed{9, events.AgentDeletedEvent{AgentID: "agent0"}},
+		observed{10, events.TaskFinishEvent{AgentID: "agent0", Result: "Error: Task cancelled"}},
+	)
+	call := r.Agents[0].LLMCalls[0]
+	if call.Status != report.FAILED || call.Error != "Task cancelled" || call.Duration != 9*time.Millisecond {
+		t.Errorf("expected the LLM call failed with the task, got %+v", call)
+	}
+}
+
+func TestDeletedAgentWithoutResultKeepsRunning(t *testing.T) {
+	r := build(
+		observed{0, events.AgentCreateEvent{AgentID: "agent0"}},
+		observed{5, events.AgentLauncherShutdownEvent{}},
+		observed{5, events.AgentDeletedEvent{AgentID: "agent0"}},
+	)
+	if agent := r.Agents[0]; agent.Status != report.RUNNING || !agent.End.IsZero() {
+		t.Errorf("expected the agent still running at shutdown, got %+v", agent)
+	}
+}
+
+func TestFromLog(t *testing.T) {
+	backend := eventlog.NewMemoryBackend()
+	registry := events.NewRegistry()
+	for i, step := range []observed{
+		{0, events.AgentCreateEvent{AgentID: "agent0", Task: "hello"}},
+		{30, events.TaskFinishEvent{AgentID: "agent0", Result: "hi"}},
+	} {
+		envelope, err := registry.Encode(step.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend.Append(eventlog.Record{Seq: int64(i + 1), Time: at(step.ms), Type: envelope.Type, Event: envelope.Event})
+	}
+
+	r, err := report.FromLog(backend, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent := r.Find("agent0"); agent == nil || agent.Status != report.FINISHED || agent.Duration != 30*time.Millisecond {
+		t.Errorf("unexpected agent %+v", agent)
+	}
+
+	var out bytes.Buffer
+	if err := r.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded report.Report
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Totals != r.Totals || decoded.Agents[0].Task != "hello" {
+		t.Errorf("JSON round trip lost data: %s", out.String())
+	}
+
+	out.Reset()
+	if err := r.WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "agent0") {
+		t.Error("expected the agent in the HTML report")
+	}
+}
@@ -3,20 +3,27 @@ package runtimes
 import (
 	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
+	"agentlauncher/internal/logging"
 	"context"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 type AgentRuntime struct {
-	Agents   map[string]*Agent `json:"agents"`
-	eventBus *eventbus.EventBus
-	mu       sync.RWMutex
+	Agents    map[string]*Agent `json:"agents"`
+	cancelled *cancelledTasks
+	eventBus  *eventbus.EventBus
+	logger    *slog.Logger
+	mu        sync.RWMutex
 }
 
 func NewAgentRuntime(eb *eventbus.EventBus) *AgentRuntime {
 	agentRuntime := &AgentRuntime{
-		Agents:   make(map[string]*Agent),
-		eventBus: eb,
+		Agents:    make(map[string]*Agent),
+		cancelled: newCancelledTasks(),
+		eventBus:  eb,
+		logger:    logging.Discard(),
 	}
 
 	eventbus.Subscribe(eb, agentRuntime.HandleTaskCreateEvent)
@@ -26,11 +33,17 @@ func NewAgentRuntime(eb *eventbus.EventBus) *AgentRuntime {
 	eventbus.Subscribe(eb, agentRuntime.HandleAgentFinishEvent)
 	eventbus.Subscribe(eb, agentRuntime.HandleAgentRuntimeErrorEvent)
 	eventbus.Subscribe(eb, agentRuntime.HandleAgentLauncherShutdownEvent)
-	eventbus.Subscribe(eb, agentRuntime.HandleTaskFinishEvent)
+	eventbus.Subscribe(eb, agentRuntime.HandleTaskCancelEvent)
+	eventbus.Subscribe(eb, agentRuntime.HandleUserFollowUpEvent)
 
 	return agentRuntime
 }
 
+func (r *AgentRuntime) WithLogger(logger *slog.Logger) *AgentRuntime {
+	r.logger = logging.Subsystem(logger, logging.AGENT)
+	return r
+}
+
 func (r *AgentRuntime) GetAgent(agentID string) (*Agent, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -39,6 +52,7 @@ func (r *AgentRuntime) GetAgent(agentID string) (*Agent, bool) {
 }
 
 func (r *AgentRuntime) HandleTaskCreateEvent(ctx context.Context, e events.TaskCreateEvent) {
+	r.cancelled.remove(e.AgentID)
 	r.eventBus.Emit(events.AgentCreateEvent{
 		AgentID:      e.AgentID,
 		Task:         e.Task,
@@ -47,85 +61,128 @@ func (r *AgentRuntime) HandleTaskCreateEvent(ctx context.Context, e events.TaskC
 			if e.SystemPrompt != "" {
 				return e.SystemPrompt
 			}
-			return PRIMARY_AGENT_SYSTEM_PROMPT
+			return PrimaryAgentSystemPrompt(DEFAULT_SUB_AGENTS_PER_PRIMARY_AGENT)
 		}(),
 		ToolSchemas: e.ToolSchemas,
+		RequestID:   e.RequestID,
 	})
 }
 
 func (r *AgentRuntime) HandleAgentCreateEvent(ctx context.Context, e events.AgentCreateEvent) {
+	if r.cancelled.contains(e.AgentID) {
+		return
+	}
 	if _, exists := r.GetAgent(e.AgentID); exists {
-		r.eventBus.Emit(events.AgentRuntimeErrorEvent{
-			AgentID: e.AgentID,
-			Error:   "Agent with this ID already exists",
-		})
+		// Answer the request without touching the agent that is running.
+		r.logger.Warn("agent already exists", "agent_id", e.AgentID)
+		r.emitFinish(e.AgentID, e.RequestID, "Error: Agent with this ID already exists")
 		return
 	}
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.Agents[e.AgentID] = NewAgent(
+	agent := NewAgent(
 		e.AgentID,
 		e.Task,
 		e.ToolSchemas,
 		r.eventBus,
 		e.SystemPrompt,
+		e.Profile,
+		e.MaxTurns,
+	)
+	agent.Conversation = append(agent.Conversation, e.Conversation...)
+	agent.RequestID = e.RequestID
+	r.mu.Lock()
+	r.Agents[e.AgentID] = agent
+	r.mu.Unlock()
+	r.logger.Info("agent created",
+		"agent_id", e.AgentID,
+		"parent_agent_id", GetParentAgentID(e.AgentID),
+		"profile", e.Profile,
+		"tools", len(e.ToolSchemas),
 	)
-	go r.Agents[e.AgentID].Start()
+	agent.Start()
 }
 
 func (r *AgentRuntime) HandleLLMResponseEvent(ctx context.Context, e events.LLMResponseEvent) {
 	if agent, exists := r.GetAgent(e.AgentID); !exists {
+		if r.cancelled.contains(e.AgentID) {
+			return
+		}
 		r.eventBus.Emit(events.AgentRuntimeErrorEvent{
 			AgentID: e.AgentID,
 			Error:   "Agent not found",
 		})
 	} else {
-		go agent.HandleLLMResponse(e.Response)
+		agent.HandleLLMResponse(e.Response)
 	}
 }
 
 func (r *AgentRuntime) HandleToolsExecResults(ctx context.Context, e events.ToolsExecResultsEvent) {
 	if agent, exists := r.GetAgent(e.AgentID); !exists {
+		if r.cancelled.contains(e.AgentID) {
+			return
+		}
 		r.eventBus.Emit(events.AgentRuntimeErrorEvent{
 			AgentID: e.AgentID,
 			Error:   "Agent not found",
 		})
 	} else {
-		go agent.HandleToolsExecResults(e.ToolResults)
+		agent.HandleToolsExecResults(e.ToolResults)
 	}
 }
 
 func (r *AgentRuntime) HandleAgentFinishEvent(ctx context.Context, e events.AgentFinishEvent) {
-	if _, exists := r.GetAgent(e.AgentID); !exists {
+	if agent, exists := r.GetAgent(e.AgentID); !exists {
+		if r.cancelled.contains(e.AgentID) {
+			return
+		}
 		r.eventBus.Emit(events.AgentRuntimeErrorEvent{
 			AgentID: e.AgentID,
 			Error:   "Agent not found",
 		})
 	} else {
-		if !IsPrimaryAgent(e.AgentID) {
-			r.mu.Lock()
-			delete(r.Agents, e.AgentID)
-			r.mu.Unlock()
-			r.eventBus.Emit(events.AgentDeletedEvent{AgentID: e.AgentID})
-		} else {
-			r.eventBus.Emit(events.TaskFinishEvent{
-				AgentID: e.AgentID,
-				Result:  e.Result,
-			})
-		}
+		r.logger.Info("agent finished",
+			"agent_id", e.AgentID,
+			"parent_agent_id", GetParentAgentID(e.AgentID),
+			"turns", agent.Turns,
+			"duration", time.Since(agent.CreatedAt),
+		)
+		r.mu.Lock()
+		delete(r.Agents, e.AgentID)
+		r.mu.Unlock()
+		r.eventBus.Emit(events.AgentDeletedEvent{AgentID: e.AgentID})
+		r.emitFinish(e.AgentID, agent.RequestID, e.Result)
 	}
 }
 
 func (r *AgentRuntime) HandleAgentRuntimeErrorEvent(ctx context.Context, e events.AgentRuntimeErrorEvent) {
-	if _, exists := r.GetAgent(e.AgentID); exists {
+	r.logger.Warn("agent error",
+		"agent_id", e.AgentID,
+		"parent_agent_id", GetParentAgentID(e.AgentID),
+		"error", e.Error,
+	)
+	if agent, exists := r.GetAgent(e.AgentID); exists {
 		r.mu.Lock()
 		delete(r.Agents, e.AgentID)
 		r.mu.Unlock()
 		r.eventBus.Emit(events.AgentDeletedEvent{AgentID: e.AgentID})
+		r.emitFinish(e.AgentID, agent.RequestID, "Error: "+e.Error)
+	}
+}
+
+// emitFinish reports the result of an agent to whoever created it: the task
+// for a primary agent, the create_sub_agent tool call for a sub-agent.
+func (r *AgentRuntime) emitFinish(agentID, requestID, result string) {
+	if IsPrimaryAgent(agentID) {
+		r.eventBus.Emit(events.TaskFinishEvent{
+			AgentID:   agentID,
+			Result:    result,
+			RequestID: requestID,
+		})
+		return
 	}
-	r.eventBus.Emit(events.TaskFinishEvent{
-		AgentID: e.AgentID,
-		Result:  "Error: " + e.Error,
+	r.eventBus.Emit(events.SubAgentFinishEvent{
+		AgentID:   agentID,
+		Result:    result,
+		RequestID: requestID,
 	})
 }
 
@@ -138,11 +195,34 @@ func (r *AgentRuntime) HandleAgentLauncherShutdownEvent(ctx context.Context, e e
 	}
 }
 
-func (r *AgentRuntime) HandleTaskFinishEvent(ctx context.Context, e events.TaskFinishEvent) {
-	if _, exists := r.GetAgent(e.AgentID); exists && !IsPrimaryAgent(e.AgentID) {
-		r.mu.Lock()
-		delete(r.Agents, e.AgentID)
-		r.mu.Unlock()
-		r.eventBus.Emit(events.AgentDeletedEvent{AgentID: e.AgentID})
+func (r *AgentRuntime) HandleTaskCancelEvent(ctx context.Context, e events.TaskCancelEvent) {
+	reason := e.Reason
+	if reason == "" {
+		reason = "Task cancelled"
+	}
+	r.cancelled.add(e.AgentID)
+	r.mu.Lock()
+	agents := []*Agent{}
+	for agentID, agent := range r.Agents {
+		if events.InAgentTree(agentID, e.AgentID) {
+			delete(r.Agents, agentID)
+			agents = append(agents, agent)
+		}
+	}
+	r.mu.Unlock()
+
+	r.logger.Info("task cancelled", "agent_id", e.AgentID, "agents", len(agents), "reason", reason)
+	for _, agent := range agents {
+		r.eventBus.Emit(events.AgentDeletedEvent{AgentID: agent.AgentID})
+		r.emitFinish(agent.AgentID, agent.RequestID, "Error: "+reason)
+	}
+}
+
+func (r *AgentRuntime) HandleUserFollowUpEvent(ctx context.Context, e events.UserFollowUpEvent) {
+	agent, exists := r.GetAgent(e.AgentID)
+	if !exists {
+		r.logger.Warn("follow-up for unknown agent dropped", "agent_id", e.AgentID)
+		return
 	}
+	agent.AddFollowUp(e.Content)
 }
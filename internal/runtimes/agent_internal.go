@@ -4,15 +4,25 @@ import (
 	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"fmt"
+	"sync"
+	"time"
 )
 
 type Agent struct {
 	AgentID      string                    `json:"agent_id"`
 	Task         string                    `json:"task"`
-	Conversation []llminterface.Message    `json:"conversation"`
+	Conversation llminterface.MessageList  `json:"conversation"`
 	SystemPrompt string                    `json:"system_prompt"`
 	ToolSchemas  []llminterface.ToolSchema `json:"tool_schemas"`
+	Profile      string                    `json:"profile"`
+	MaxTurns     int                       `json:"max_turns"`
+	Turns        int                       `json:"turns"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	RequestID    string                    `json:"request_id,omitempty"`
 	EventBus     *eventbus.EventBus
+	followUps    []string
+	mu           sync.Mutex
 }
 
 func NewAgent(
@@ -21,33 +31,79 @@ func NewAgent(
 	toolSchemas []llminterface.ToolSchema,
 	eventBus *eventbus.EventBus,
 	systemPrompt string,
+	profile string,
+	maxTurns int,
 ) *Agent {
 	return &Agent{
 		AgentID:      agentID,
 		Task:         task,
-		Conversation: []llminterface.Message{},
+		Conversation: llminterface.MessageList{},
 		SystemPrompt: systemPrompt,
 		ToolSchemas:  toolSchemas,
+		Profile:      profile,
+		MaxTurns:     maxTurns,
+		CreatedAt:    time.Now(),
 		EventBus:     eventBus,
 	}
 }
 
 func (a *Agent) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.EventBus.Emit(events.AgentStartEvent{AgentID: a.AgentID})
 	a.Conversation = append(a.Conversation, llminterface.UserMessage{Content: a.Task})
+	a.requestLLM()
+}
+
+// AddFollowUp queues a user message for the agent's next LLM request. A
+// follow-up that arrives before the agent's final answer keeps it going.
+func (a *Agent) AddFollowUp(content string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.followUps = append(a.followUps, content)
+}
+
+// takeFollowUps moves queued follow-ups into the conversation and reports
+// whether there were any.
+func (a *Agent) takeFollowUps() bool {
+	if len(a.followUps) == 0 {
+		return false
+	}
+	messages := llminterface.MessageList{}
+	for _, content := range a.followUps {
+		messages = append(messages, llminterface.UserMessage{Content: content})
+	}
+	a.followUps = nil
+	a.Conversation = append(a.Conversation, messages...)
+	a.EventBus.Emit(events.MessagesAddEvent{
+		AgentID:  a.AgentID,
+		Messages: messages,
+	})
+	return true
+}
+
+func (a *Agent) requestLLM() {
 	messageList := []llminterface.Message{}
 	if a.SystemPrompt != "" {
 		messageList = append(messageList, llminterface.SystemMessage{Content: a.SystemPrompt})
 	}
 	messageList = append(messageList, a.Conversation...)
+	a.Turns++
 	a.EventBus.Emit(events.LLMRequestEvent{
 		AgentID:     a.AgentID,
 		Messages:    messageList,
 		ToolSchemas: a.ToolSchemas,
+		Profile:     a.Profile,
 	})
 }
 
+func (a *Agent) turnsExhausted() bool {
+	return a.MaxTurns > 0 && a.Turns >= a.MaxTurns
+}
+
 func (a *Agent) HandleLLMResponse(response llminterface.ResponseMessageList) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	a.Conversation = append(a.Conversation, response...)
 	toolCalls := []events.ToolCall{}
 	for _, msg := range response {
@@ -59,7 +115,9 @@ func (a *Agent) HandleLLMResponse(response llminterface.ResponseMessageList) {
 			})
 		}
 	}
-	if len(toolCalls) == 0 {
+	if len(toolCalls) == 0 && !a.turnsExhausted() && a.takeFollowUps() {
+		a.requestLLM()
+	} else if len(toolCalls) == 0 {
 		assistantContents := ""
 		for _, msg := range response {
 			if assistantMsg, ok := msg.(llminterface.AssistantMessage); ok {
@@ -79,6 +137,8 @@ func (a *Agent) HandleLLMResponse(response llminterface.ResponseMessageList) {
 }
 
 func (a *Agent) HandleToolsExecResults(toolResults []events.ToolResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 	for _, result := range toolResults {
 		a.Conversation = append(a.Conversation, llminterface.ToolResultMessage{
 			ToolCallID: result.ToolCallID,
@@ -86,14 +146,13 @@ func (a *Agent) HandleToolsExecResults(toolResults []events.ToolResult) {
 			Result:     result.Result,
 		})
 	}
-	messageList := []llminterface.Message{}
-	if a.SystemPrompt != "" {
-		messageList = append(messageList, llminterface.SystemMessage{Content: a.SystemPrompt})
+	if a.turnsExhausted() {
+		a.EventBus.Emit(events.AgentFinishEvent{
+			AgentID: a.AgentID,
+			Result:  fmt.Sprintf("Error: agent stopped after reaching the maximum of %d turns", a.MaxTurns),
+		})
+		return
 	}
-	messageList = append(messageList, a.Conversation...)
-	a.EventBus.Emit(events.LLMRequestEvent{
-		AgentID:     a.AgentID,
-		Messages:    messageList,
-		ToolSchemas: a.ToolSchemas,
-	})
+	a.takeFollowUps()
+	a.requestLLM()
 }
@@ -0,0 +1,39 @@
+package runtimes
+
+import (
+	"strings"
+	"sync"
+)
+
+// cancelledTasks remembers cancelled tasks so runtimes can drop the events
+// still in flight for their agents.
+type cancelledTasks struct {
+	ids map[string]bool
+	mu  sync.RWMutex
+}
+
+func newCancelledTasks() *cancelledTasks {
+	return &cancelledTasks{ids: make(map[string]bool)}
+}
+
+func (c *cancelledTasks) add(taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ids[taskID] = true
+}
+
+// remove forgets a task whose ID is reused by a new run.
+func (c *cancelledTasks) remove(taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.ids, taskID)
+}
+
+// contains reports whether the task of agentID, or of its primary agent, was
+// cancelled.
+func (c *cancelledTasks) contains(agentID string) bool {
+	primaryAgentID, _, _ := strings.Cut(agentID, "_")
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ids[primaryAgentID]
+}
@@ -4,13 +4,23 @@ import (
 	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
 	"context"
+	"log/slog"
+	"sync"
+	"time"
 )
 
 type LLMRuntime struct {
 	eventBus               *eventbus.EventBus
 	main_agent_llm_handler llminterface.LLMHandler
 	sub_agent_llm_handler  llminterface.LLMHandler
+	profile_llm_handlers   map[string]llminterface.LLMHandler
+	routes                 []LLMRoute
+	retry                  eventbus.RetryPolicy
+	logger                 *slog.Logger
+	cancelled              *cancelledTasks
+	mu                     sync.RWMutex
 }
 
 func NewLLMRuntime(eventBus *eventbus.EventBus, mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler) *LLMRuntime {
@@ -18,21 +28,100 @@ func NewLLMRuntime(eventBus *eventbus.EventBus, mainAgentHandler llminterface.LL
 		eventBus:               eventBus,
 		main_agent_llm_handler: mainAgentHandler,
 		sub_agent_llm_handler:  subAgentHandler,
+		profile_llm_handlers:   make(map[string]llminterface.LLMHandler),
+		retry:                  DefaultRetryPolicy(),
+		logger:                 logging.Discard(),
+		cancelled:              newCancelledTasks(),
 	}
 	eventbus.Subscribe(eventBus, llmRuntime.HandleLLMRequestEvent)
 	eventbus.Subscribe(eventBus, llmRuntime.HandleLLMRuntimeErrorEvent)
+	eventbus.Subscribe(eventBus, llmRuntime.HandleTaskCreateEvent)
+	eventbus.Subscribe(eventBus, llmRuntime.HandleTaskCancelEvent)
 	return llmRuntime
 }
 
-func (r *LLMRuntime) HandleLLMRequestEvent(ctx context.Context, event events.LLMRequestEvent) {
-	var handler llminterface.LLMHandler
+func (r *LLMRuntime) WithLogger(logger *slog.Logger) *LLMRuntime {
+	r.logger = logging.Subsystem(logger, logging.LLM)
+	return r
+}
+
+func (r *LLMRuntime) RegisterProfileHandler(profile string, handler llminterface.LLMHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profile_llm_handlers[profile] = handler
+}
+
+func (r *LLMRuntime) WithRoutes(routes ...LLMRoute) *LLMRuntime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, routes...)
+	return r
+}
+
+func (r *LLMRuntime) WithRetryPolicy(policy eventbus.RetryPolicy) *LLMRuntime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retry = policy
+	return r
+}
+
+func (r *LLMRuntime) defaultRoute(event events.LLMRequestEvent) LLMRoute {
+	r.mu.RLock()
+	handler, exists := r.profile_llm_handlers[event.Profile]
+	r.mu.RUnlock()
+	if exists && handler != nil {
+		return LLMRoute{Name: "profile:" + event.Profile, Handler: handler}
+	}
 	if IsPrimaryAgent(event.AgentID) {
-		handler = r.main_agent_llm_handler
-	} else {
-		handler = r.sub_agent_llm_handler
+		return LLMRoute{Name: "main_agent", Handler: r.main_agent_llm_handler}
+	}
+	return LLMRoute{Name: "sub_agent", Handler: r.sub_agent_llm_handler}
+}
+
+// candidatesFor returns the matching routes in registration order, with the
+// agent's own handler as the last fallback.
+func (r *LLMRuntime) candidatesFor(event events.LLMRequestEvent) ([]LLMRoute, LLMRouteRequest) {
+	request := LLMRouteRequest{
+		AgentID:  event.AgentID,
+		Profile:  event.Profile,
+		Depth:    GetAgentDepth(event.AgentID),
+		Messages: event.Messages,
+		Tools:    event.ToolSchemas,
+	}
+
+	r.mu.RLock()
+	routes := r.routes
+	r.mu.RUnlock()
+
+	candidates := []LLMRoute{}
+	if len(routes) > 0 {
+		request.EstimatedTokens = EstimateTokens(request.Messages, request.Tools)
+		for _, route := range routes {
+			if route.Handler != nil && route.matches(request) {
+				candidates = append(candidates, route)
+			}
+		}
+	}
+	if route := r.defaultRoute(event); route.Handler != nil {
+		candidates = append(candidates, route)
+	}
+	return candidates, request
+}
+
+func (r *LLMRuntime) HandleLLMRequestEvent(ctx context.Context, event events.LLMRequestEvent) {
+	if r.cancelled.contains(event.AgentID) {
+		return
 	}
+	// LLM calls are slow, keep them off the bus workers so the agent's
+	// later events, like its cancellation, are not held back.
+	go r.callLLM(event)
+}
+
+func (r *LLMRuntime) callLLM(event events.LLMRequestEvent) {
+	candidates, request := r.candidatesFor(event)
 
-	if handler == nil {
+	if len(candidates) == 0 {
+		r.logger.Error("no LLM handler configured", "agent_id", event.AgentID, "profile", event.Profile)
 		r.eventBus.Emit(events.LLMRuntimeErrorEvent{
 			AgentID:      event.AgentID,
 			Error:        "No LLM handler configured",
@@ -41,23 +130,90 @@ func (r *LLMRuntime) HandleLLMRequestEvent(ctx context.Context, event events.LLM
 		return
 	}
 
-	response := handler(event.Messages, event.ToolSchemas, event.AgentID, r.eventBus)
-	r.eventBus.Emit(events.LLMResponseEvent{
+	r.mu.RLock()
+	routing := len(r.routes) > 0
+	r.mu.RUnlock()
+	if routing {
+		names := make([]string, len(candidates))
+		for i, route := range candidates {
+			names[i] = route.Name
+		}
+		r.eventBus.Emit(events.LLMRouteDecisionEvent{
+			AgentID:         event.AgentID,
+			Candidates:      names,
+			EstimatedTokens: request.EstimatedTokens,
+		})
+	}
+
+	var lastErr error
+	for attempt, route := range candidates {
+		start := time.Now()
+		response, err := callLLMRoute(route, event.Messages, event.ToolSchemas, event.AgentID, r.eventBus)
+		logger := r.logger.With(
+			"agent_id", event.AgentID,
+			"parent_agent_id", GetParentAgentID(event.AgentID),
+			"route", route.Name,
+			"retry_count", event.RetryCount,
+			"duration", time.Since(start),
+		)
+		if err == nil {
+			logger.Info("llm call finished", "messages", len(event.Messages), "response_messages", len(response))
+			r.eventBus.Emit(events.LLMResponseEvent{
+				AgentID:      event.AgentID,
+				RequestEvent: event,
+				Response:     response,
+				Route:        route.Name,
+			})
+			return
+		}
+		lastErr = err
+		logger.Warn("llm call failed", "attempt", attempt+1, "error", err)
+		if routing {
+			r.eventBus.Emit(events.LLMRouteFailedEvent{
+				AgentID: event.AgentID,
+				Route:   route.Name,
+				Attempt: attempt + 1,
+				Error:   err.Error(),
+			})
+		}
+	}
+
+	r.eventBus.Emit(events.LLMRuntimeErrorEvent{
 		AgentID:      event.AgentID,
+		Error:        lastErr.Error(),
 		RequestEvent: event,
-		Response:     response,
 	})
 }
 
 func (r *LLMRuntime) HandleLLMRuntimeErrorEvent(ctx context.Context, event events.LLMRuntimeErrorEvent) {
-	if event.RequestEvent.RetryCount < 5 {
-		r.eventBus.Emit(events.LLMRequestEvent{
+	if r.cancelled.contains(event.AgentID) {
+		return
+	}
+	r.mu.RLock()
+	policy := r.retry
+	r.mu.RUnlock()
+	if event.RequestEvent.RetryCount < policy.MaxRetries {
+		retry := events.LLMRequestEvent{
 			AgentID:     event.AgentID,
 			Messages:    event.RequestEvent.Messages,
 			ToolSchemas: event.RequestEvent.ToolSchemas,
 			RetryCount:  event.RequestEvent.RetryCount + 1,
+			Profile:     event.RequestEvent.Profile,
+		}
+		delay := policy.Delay(retry.RetryCount)
+		r.logger.Info("retrying llm request", "agent_id", event.AgentID, "retry_count", retry.RetryCount, "delay", delay, "error", event.Error)
+		if delay == 0 {
+			r.eventBus.Emit(retry)
+			return
+		}
+		// Wait off the bus, the agent may be cancelled meanwhile.
+		time.AfterFunc(delay, func() {
+			if !r.cancelled.contains(event.AgentID) {
+				r.eventBus.Emit(retry)
+			}
 		})
 	} else {
+		r.logger.Error("llm request failed after retries", "agent_id", event.AgentID, "retry_count", event.RequestEvent.RetryCount, "error", event.Error)
 		response := []llminterface.Message{
 			llminterface.AssistantMessage{Content: "Runtime error: " + event.Error},
 		}
@@ -68,3 +224,11 @@ func (r *LLMRuntime) HandleLLMRuntimeErrorEvent(ctx context.Context, event event
 		})
 	}
 }
+
+func (r *LLMRuntime) HandleTaskCreateEvent(ctx context.Context, event events.TaskCreateEvent) {
+	r.cancelled.remove(event.AgentID)
+}
+
+func (r *LLMRuntime) HandleTaskCancelEvent(ctx context.Context, event events.TaskCancelEvent) {
+	r.cancelled.add(event.AgentID)
+}
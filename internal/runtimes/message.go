@@ -4,15 +4,19 @@ import (
 	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
 	"context"
+	"log/slog"
 	"sync"
 )
 
 type MessageRuntime struct {
-	History                  map[string][]llminterface.Message `json:"history"`
+	History                  map[string]llminterface.MessageList `json:"history"`
 	eventBus                 *eventbus.EventBus
 	response_message_handler func(llminterface.ResponseMessageList) llminterface.ResponseMessageList
 	conversation_handler     func(llminterface.MessageList) llminterface.MessageList
+	logger                   *slog.Logger
+	cancelled                *cancelledTasks
 	mu                       sync.RWMutex
 }
 
@@ -20,8 +24,10 @@ func NewMessageRuntime(
 	eventBus *eventbus.EventBus,
 ) *MessageRuntime {
 	messageRuntime := &MessageRuntime{
-		History:  make(map[string][]llminterface.Message),
-		eventBus: eventBus,
+		History:   make(map[string]llminterface.MessageList),
+		eventBus:  eventBus,
+		logger:    logging.Discard(),
+		cancelled: newCancelledTasks(),
 	}
 	eventbus.Subscribe(eventBus, messageRuntime.HandleLLMResponseEvent)
 	eventbus.Subscribe(eventBus, messageRuntime.HandleTaskCreateEvent)
@@ -29,9 +35,15 @@ func NewMessageRuntime(
 	eventbus.Subscribe(eventBus, messageRuntime.HandleMessagesAddEvent)
 	eventbus.Subscribe(eventBus, messageRuntime.HandleAgentLauncherShutdownEvent)
 	eventbus.Subscribe(eventBus, messageRuntime.HandleTaskFinishEvent)
+	eventbus.Subscribe(eventBus, messageRuntime.HandleTaskCancelEvent)
 	return messageRuntime
 }
 
+func (r *MessageRuntime) WithLogger(logger *slog.Logger) *MessageRuntime {
+	r.logger = logging.Subsystem(logger, logging.MESSAGE)
+	return r
+}
+
 func (r *MessageRuntime) WithResponseMessageHandler(handler func(llminterface.ResponseMessageList) llminterface.ResponseMessageList) *MessageRuntime {
 	r.response_message_handler = handler
 	return r
@@ -43,7 +55,7 @@ func (r *MessageRuntime) WithConversationHandler(handler func(llminterface.Messa
 }
 
 func (r *MessageRuntime) HandleLLMResponseEvent(ctx context.Context, e events.LLMResponseEvent) {
-	if !IsPrimaryAgent(e.AgentID) {
+	if !IsPrimaryAgent(e.AgentID) || r.cancelled.contains(e.AgentID) {
 		return
 	}
 
@@ -68,9 +80,10 @@ func (r *MessageRuntime) HandleTaskCreateEvent(ctx context.Context, e events.Tas
 	if !IsPrimaryAgent(e.AgentID) {
 		return
 	}
+	r.cancelled.remove(e.AgentID)
 	r.mu.Lock()
 	if _, exists := r.History[e.AgentID]; !exists {
-		r.History[e.AgentID] = []llminterface.Message{}
+		r.History[e.AgentID] = llminterface.MessageList{}
 	}
 	if e.Conversation != nil {
 		r.History[e.AgentID] = append(r.History[e.AgentID], e.Conversation...)
@@ -84,7 +97,7 @@ func (r *MessageRuntime) HandleTaskCreateEvent(ctx context.Context, e events.Tas
 }
 
 func (r *MessageRuntime) HandleToolsExecResults(ctx context.Context, e events.ToolsExecResultsEvent) {
-	if !IsPrimaryAgent(e.AgentID) {
+	if !IsPrimaryAgent(e.AgentID) || r.cancelled.contains(e.AgentID) {
 		return
 	}
 	toolMessages := []llminterface.Message{}
@@ -104,7 +117,7 @@ func (r *MessageRuntime) HandleToolsExecResults(ctx context.Context, e events.To
 }
 
 func (r *MessageRuntime) HandleMessagesAddEvent(ctx context.Context, e events.MessagesAddEvent) {
-	if !IsPrimaryAgent(e.AgentID) {
+	if !IsPrimaryAgent(e.AgentID) || r.cancelled.contains(e.AgentID) {
 		return
 	}
 	r.mu.Lock()
@@ -113,6 +126,7 @@ func (r *MessageRuntime) HandleMessagesAddEvent(ctx context.Context, e events.Me
 		panic("History for primary agent " + e.AgentID + " does not exist")
 	}
 	r.History[e.AgentID] = append(r.History[e.AgentID], e.Messages...)
+	r.logger.Debug("messages added", "agent_id", e.AgentID, "messages", len(e.Messages), "history", len(r.History[e.AgentID]))
 }
 
 func (r *MessageRuntime) HandleAgentLauncherShutdownEvent(ctx context.Context, e events.AgentLauncherShutdownEvent) {
@@ -121,20 +135,20 @@ func (r *MessageRuntime) HandleAgentLauncherShutdownEvent(ctx context.Context, e
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, exists := r.History[e.AgentID]; !exists {
-		panic("History for primary agent " + e.AgentID + " does not exist")
-	}
 	delete(r.History, e.AgentID)
 }
 
+// HandleTaskFinishEvent drops the task's history, which is already gone when
+// the task was cancelled before it created its agent.
 func (r *MessageRuntime) HandleTaskFinishEvent(ctx context.Context, e events.TaskFinishEvent) {
 	if !IsPrimaryAgent(e.AgentID) {
 		return
 	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, exists := r.History[e.AgentID]; !exists {
-		panic("History for primary agent " + e.AgentID + " does not exist")
-	}
 	delete(r.History, e.AgentID)
 }
+
+func (r *MessageRuntime) HandleTaskCancelEvent(ctx context.Context, e events.TaskCancelEvent) {
+	r.cancelled.add(e.AgentID)
+}
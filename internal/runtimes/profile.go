@@ -0,0 +1,15 @@
+package runtimes
+
+import (
+	"agentlauncher/internal/llminterface"
+)
+
+type AgentProfile struct {
+	Name                   string                  `json:"name"`
+	Description            string                  `json:"description"`
+	LLMHandler             llminterface.LLMHandler `json:"-"`
+	SystemPrompt           string                  `json:"system_prompt"`
+	ToolNames              []string                `json:"tool_names"`
+	MaxTurns               int                     `json:"max_turns"`
+	MaxConcurrentToolCalls int                     `json:"max_concurrent_tool_calls"`
+}
@@ -0,0 +1,11 @@
+package runtimes
+
+import "agentlauncher/internal/eventbus"
+
+const DEFAULT_LLM_MAX_RETRIES int = 5
+
+// DefaultRetryPolicy retries a failed LLM request DEFAULT_LLM_MAX_RETRIES
+// times without waiting before the agent gets the error as its response.
+func DefaultRetryPolicy() eventbus.RetryPolicy {
+	return eventbus.RetryPolicy{MaxRetries: DEFAULT_LLM_MAX_RETRIES}
+}
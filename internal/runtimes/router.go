@@ -0,0 +1,121 @@
+package runtimes
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type LLMRouteRequest struct {
+	AgentID         string
+	Profile         string
+	Depth           int
+	Messages        llminterface.RequestMessageList
+	Tools           llminterface.RequestToolList
+	EstimatedTokens int
+}
+
+type LLMRoute struct {
+	Name    string
+	Handler llminterface.LLMHandler
+	Timeout time.Duration
+	Match   func(LLMRouteRequest) bool
+}
+
+func (route LLMRoute) matches(request LLMRouteRequest) bool {
+	return route.Match == nil || route.Match(request)
+}
+
+func RouteWhenTokensAbove(tokens int) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool { return r.EstimatedTokens > tokens }
+}
+
+func RouteWhenTokensAtMost(tokens int) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool { return r.EstimatedTokens <= tokens }
+}
+
+func RouteWhenDepthAtLeast(depth int) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool { return r.Depth >= depth }
+}
+
+func RouteWhenTools(present bool) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool { return (len(r.Tools) > 0) == present }
+}
+
+func RouteWhenProfile(profile string) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool { return r.Profile == profile }
+}
+
+func RouteWhenAll(matchers ...func(LLMRouteRequest) bool) func(LLMRouteRequest) bool {
+	return func(r LLMRouteRequest) bool {
+		for _, match := range matchers {
+			if !match(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// EstimateTokens is a rough chars/4 estimate, good enough to pick a model.
+func EstimateTokens(messages llminterface.RequestMessageList, tools llminterface.RequestToolList) int {
+	chars := 0
+	for _, msg := range messages {
+		switch m := msg.(type) {
+		case llminterface.UserMessage:
+			chars += len(m.Content)
+		case llminterface.SystemMessage:
+			chars += len(m.Content)
+		case llminterface.AssistantMessage:
+			chars += len(m.Content)
+		case llminterface.ToolResultMessage:
+			chars += len(m.Result)
+		case llminterface.ToolCallMessage:
+			args, _ := json.Marshal(m.Arguments)
+			chars += len(m.ToolName) + len(args)
+		}
+	}
+	if len(tools) > 0 {
+		schemas, _ := json.Marshal(tools)
+		chars += len(schemas)
+	}
+	return chars / 4
+}
+
+func callLLMRoute(route LLMRoute, messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) (llminterface.ResponseMessageList, error) {
+	type result struct {
+		response llminterface.ResponseMessageList
+		err      error
+	}
+	done := make(chan result, 1)
+	call := func(eb *eventbus.EventBus) {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("llm handler panicked: %v", p)}
+			}
+		}()
+		done <- result{response: route.Handler(messages, tools, agentID, eb)}
+	}
+
+	if route.Timeout <= 0 {
+		go call(eb)
+		r := <-done
+		return r.response, r.err
+	}
+
+	// The handler cannot be stopped, its events reach eb only until the
+	// route is abandoned.
+	scope := eb.Scope()
+	go call(scope)
+	select {
+	case r := <-done:
+		scope.Shutdown(context.Background())
+		return r.response, r.err
+	case <-time.After(route.Timeout):
+		scope.Shutdown(context.Background())
+		return nil, fmt.Errorf("llm handler timed out after %s", route.Timeout)
+	}
+}
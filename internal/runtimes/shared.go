@@ -9,9 +9,14 @@ import (
 )
 
 const (
-	PRIMARY_AGENT_PREFIX        string = "agent"
-	CREATE_SUB_AGENT_TOOL_NAME  string = "create_sub_agent"
-	PRIMARY_AGENT_SYSTEM_PROMPT string = `Your primary role is to wisely delegate tasks
+	PRIMARY_AGENT_PREFIX                 string = "agent"
+	CREATE_SUB_AGENT_TOOL_NAME           string = "create_sub_agent"
+	DEFAULT_SUB_AGENTS_PER_PRIMARY_AGENT int    = 3
+)
+
+// primaryAgentSystemPrompt is completed with how many sub-agents may run at
+// once by PrimaryAgentSystemPrompt.
+const primaryAgentSystemPrompt = `Your primary role is to wisely delegate tasks
 by creating sub-agents whenever a task requires multiple steps or tools.
 Try to avoid creating sub-agents for tasks that only require a single step.
 Direct execution is 10x more costly than delegation and increases the workload.
@@ -23,8 +28,7 @@ For example, if a task requires 5 steps,
 but the sub-agent slm calls are 10x cheaper,
 so the total cost is 1 + 5/10 = 1.5 llm calls, 0.3x of doing it yourself.
 
-You may launch up to 3 sub-agents at once,
-and should run them in parallel whenever possible.
+%s
 Sub-agents lack access to your task or conversation history,
 so always provide complete context and instructions.
 Sub-agents will be deleted after returning their results,
@@ -32,7 +36,20 @@ so conversation is not available for back-and-forth interactions.
 Describe your efficient delegation strategy before creating sub-agents.
 Organize results for easy understanding, you don't need to report how you delegated.
 `
-)
+
+// PrimaryAgentSystemPrompt returns the default system prompt of primary
+// agents, telling them how many sub-agents they may run at once, 0 meaning
+// any number.
+func PrimaryAgentSystemPrompt(subAgentsAtOnce int) string {
+	launch := "You may launch several sub-agents at once,\nand should run them in parallel whenever possible."
+	switch {
+	case subAgentsAtOnce == 1:
+		launch = "You may run only one sub-agent at a time,\nso wait for its result before creating the next one."
+	case subAgentsAtOnce > 1:
+		launch = fmt.Sprintf("You may launch up to %d sub-agents at once,\nand should run them in parallel whenever possible.", subAgentsAtOnce)
+	}
+	return fmt.Sprintf(primaryAgentSystemPrompt, launch)
+}
 
 func GeneratePrimaryAgentID(index int) string {
 	return fmt.Sprintf("%s%d", PRIMARY_AGENT_PREFIX, index)
@@ -42,15 +59,30 @@ func GenerateSubAgentID(primaryAgentID string) string {
 	return fmt.Sprintf("%s_%s", primaryAgentID, uuid.New().String())
 }
 
+// NewRequestID returns a unique ID to correlate a request with its reply.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
 func GetPrimaryAgentIDFromSubAgentID(subAgentID string) (string, error) {
-	var primaryAgentID string
-	n, err := fmt.Sscanf(subAgentID, PRIMARY_AGENT_PREFIX+"%d_", &primaryAgentID)
-	if n != 1 || err != nil {
+	primaryAgentID, _, found := strings.Cut(subAgentID, "_")
+	if !found || !IsPrimaryAgent(primaryAgentID) {
 		return "", fmt.Errorf("invalid sub-agent ID format")
 	}
 	return primaryAgentID, nil
 }
 
+func GetParentAgentID(agentID string) string {
+	if index := strings.LastIndex(agentID, "_"); index >= 0 {
+		return agentID[:index]
+	}
+	return ""
+}
+
+func GetAgentDepth(agentID string) int {
+	return strings.Count(agentID, "_")
+}
+
 func IsPrimaryAgent(agentID string) bool {
     if !strings.HasPrefix(agentID, PRIMARY_AGENT_PREFIX) {
         return false
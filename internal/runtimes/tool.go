@@ -3,14 +3,20 @@ package runtimes
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"agentlauncher/internal/eventbus"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
 )
 
 type Tool struct {
@@ -18,32 +24,130 @@ type Tool struct {
 	Function any
 }
 
+// RawToolFunction receives the arguments of a call as the LLM sent them, for
+// tools whose parameters are only known at run time.
+type RawToolFunction func(ctx context.Context, arguments map[string]any) (string, error)
+
 type ToolRuntime struct {
-	eventBus        *eventbus.EventBus
-	tools           map[string]*Tool
-	subAgentTool    bool
-	subAgentResults map[string]chan string
-	mu              sync.RWMutex
+	eventBus     *eventbus.EventBus
+	tools        map[string]*Tool
+	subAgentTool bool
+	logger       *slog.Logger
+	mu           sync.RWMutex
+
+	toolExecLimit             semaphore
+	toolLimits                map[string]semaphore
+	subAgentLimit             semaphore
+	subAgentsPerPrimaryLimit  int
+	subAgentsPerPrimaryAgents map[string]*sharedSemaphore
+	// subAgentSlots are the slots held by running sub-agents.
+	subAgentSlots map[string]*heldLimits
+
+	profiles            map[string]AgentProfile
+	agentToolCallLimits map[string]int
+
+	running     map[string]context.CancelFunc
+	approvals   map[string]bool
+	userTimeout time.Duration
 }
 
 func NewToolRuntime(eventBus *eventbus.EventBus) *ToolRuntime {
 	toolRuntime := &ToolRuntime{
-		eventBus:        eventBus,
-		tools:           make(map[string]*Tool),
-		subAgentTool:    true,
-		subAgentResults: make(map[string]chan string),
+		eventBus:                  eventBus,
+		tools:                     make(map[string]*Tool),
+		subAgentTool:              true,
+		toolLimits:                make(map[string]semaphore),
+		subAgentsPerPrimaryLimit:  DEFAULT_SUB_AGENTS_PER_PRIMARY_AGENT,
+		subAgentsPerPrimaryAgents: make(map[string]*sharedSemaphore),
+		subAgentSlots:             make(map[string]*heldLimits),
+		profiles:                  make(map[string]AgentProfile),
+		agentToolCallLimits:       make(map[string]int),
+		running:                   make(map[string]context.CancelFunc),
+		approvals:                 make(map[string]bool),
+		userTimeout:               DEFAULT_USER_TIMEOUT,
+		logger:                    logging.Discard(),
 	}
 	eventbus.Subscribe(eventBus, toolRuntime.handleToolsExecRequest)
-	eventbus.Subscribe(eventBus, toolRuntime.HandleAgentFinishEvent)
 	eventbus.Subscribe(eventBus, toolRuntime.HandleToolRuntimeErrorEvent)
-	eventbus.Subscribe(eventBus, toolRuntime.HandleAgentLauncherShutdownEvent)
+	eventbus.Subscribe(eventBus, toolRuntime.HandleToolSchemasRequestEvent)
+	eventbus.Subscribe(eventBus, toolRuntime.HandleTaskCancelEvent)
 	return toolRuntime
 }
 
+func (tr *ToolRuntime) WithLogger(logger *slog.Logger) *ToolRuntime {
+	tr.logger = logging.Subsystem(logger, logging.TOOL)
+	return tr
+}
+
 func (tr *ToolRuntime) DisableSubAgentTool() {
 	tr.subAgentTool = false
 }
 
+func (tr *ToolRuntime) WithToolExecLimit(limit int) *ToolRuntime {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.toolExecLimit = newSemaphore(limit)
+	return tr
+}
+
+func (tr *ToolRuntime) WithToolLimit(name string, limit int) *ToolRuntime {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if limit <= 0 {
+		delete(tr.toolLimits, name)
+		return tr
+	}
+	tr.toolLimits[name] = newSemaphore(limit)
+	return tr
+}
+
+// WithSubAgentLimit bounds the sub-agents running at once in the tree of each
+// primary agent and in all of them. A sub-agent gives its slot back while it
+// waits for its own sub-agents, and takes it again once they are done.
+func (tr *ToolRuntime) WithSubAgentLimit(perPrimaryAgent, global int) *ToolRuntime {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.subAgentsPerPrimaryLimit = perPrimaryAgent
+	tr.subAgentsPerPrimaryAgents = make(map[string]*sharedSemaphore)
+	tr.subAgentLimit = newSemaphore(global)
+	return tr
+}
+
+// SubAgentsAtOnce returns how many sub-agents a primary agent may run at
+// once, 0 if there is no limit.
+func (tr *ToolRuntime) SubAgentsAtOnce() int {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	limit := max(tr.subAgentsPerPrimaryLimit, 0)
+	if global := cap(tr.subAgentLimit); global > 0 && (limit == 0 || global < limit) {
+		limit = global
+	}
+	return limit
+}
+
+// RegisterProfile adds a profile sub-agents can be created from. Its tools
+// must be registered first.
+func (tr *ToolRuntime) RegisterProfile(profile AgentProfile) error {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if _, exists := tr.profiles[profile.Name]; exists {
+		return fmt.Errorf("agent profile '%s' is already registered", profile.Name)
+	}
+	unknown := []string{}
+	for _, name := range profile.ToolNames {
+		if _, exists := tr.tools[name]; !exists && name != CREATE_SUB_AGENT_TOOL_NAME {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("agent profile '%s' uses unknown tools: %s", profile.Name, strings.Join(unknown, ", "))
+	}
+	tr.profiles[profile.Name] = profile
+	// The sub-agent tool schema lists the profiles, rebuild it on the next run.
+	delete(tr.tools, CREATE_SUB_AGENT_TOOL_NAME)
+	return nil
+}
+
 func (tr *ToolRuntime) Register(name, description string, fn any, params []llminterface.ToolParamSchema) {
 	if !isValidToolFunction(fn) {
 		panic(fmt.Sprintf("invalid tool function signature for %s", name))
@@ -58,6 +162,8 @@ func (tr *ToolRuntime) Register(name, description string, fn any, params []llmin
 		Function: fn,
 	}
 
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
 	if _, exists := tr.tools[name]; exists {
 		if name == CREATE_SUB_AGENT_TOOL_NAME {
 			return
@@ -68,6 +174,13 @@ func (tr *ToolRuntime) Register(name, description string, fn any, params []llmin
 	tr.tools[name] = tool
 }
 
+func (tr *ToolRuntime) tool(name string) (*Tool, bool) {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+	tool, exists := tr.tools[name]
+	return tool, exists
+}
+
 func isValidToolFunction(fn any) bool {
 	fnType := reflect.TypeOf(fn)
 
@@ -93,14 +206,20 @@ func isValidToolFunction(fn any) bool {
 }
 
 func (tr *ToolRuntime) handleToolsExecRequest(ctx context.Context, event events.ToolsExecRequestEvent) {
+	// Tool calls may wait on limiters or sub-agents, so keep them off the bus workers.
+	go tr.execToolCalls(ctx, event)
+}
+
+func (tr *ToolRuntime) execToolCalls(ctx context.Context, event events.ToolsExecRequestEvent) {
 	missingTools := make([]string, 0)
 	for _, toolCall := range event.ToolCalls {
-		if _, exists := tr.tools[toolCall.ToolName]; !exists {
+		if _, exists := tr.tool(toolCall.ToolName); !exists {
 			missingTools = append(missingTools, toolCall.ToolName)
 		}
 	}
 
 	if len(missingTools) > 0 {
+		tr.logger.Error("missing tools", "agent_id", event.AgentID, "tool_names", missingTools)
 		tr.eventBus.Emit(events.ToolRuntimeErrorEvent{
 			AgentID: event.AgentID,
 			Error:   fmt.Sprintf("Missing tools: %v", missingTools),
@@ -108,15 +227,34 @@ func (tr *ToolRuntime) handleToolsExecRequest(ctx context.Context, event events.
 		return
 	}
 
+	ctx, cancel := context.WithCancel(ctx)
+	tr.mu.Lock()
+	tr.running[event.AgentID] = cancel
+	tr.mu.Unlock()
+	defer func() {
+		tr.mu.Lock()
+		delete(tr.running, event.AgentID)
+		tr.mu.Unlock()
+		cancel()
+	}()
+
 	results := make([]events.ToolResult, len(event.ToolCalls))
 	resultsChan := make(chan struct {
 		index  int
 		result events.ToolResult
 	}, len(event.ToolCalls))
 
+	tr.mu.RLock()
+	agentLimit := newSemaphore(tr.agentToolCallLimits[event.AgentID])
+	tr.mu.RUnlock()
+
 	for i, toolCall := range event.ToolCalls {
 		go func(index int, tc events.ToolCall) {
-			result, err := tr.toolExec(ctx, tc.ToolName, tc.Arguments, event.AgentID, tc.ToolCallID)
+			result, err := "", agentLimit.acquire(ctx)
+			if err == nil {
+				result, err = tr.toolExec(ctx, tc.ToolName, tc.Arguments, event.AgentID, tc.ToolCallID)
+				agentLimit.release()
+			}
 			toolResult := events.ToolResult{
 				AgentID:    event.AgentID,
 				ToolName:   tc.ToolName,
@@ -140,6 +278,10 @@ func (tr *ToolRuntime) handleToolsExecRequest(ctx context.Context, event events.
 	}
 	close(resultsChan)
 
+	if ctx.Err() != nil {
+		// The task was cancelled, nobody is waiting for these results.
+		return
+	}
 	tr.eventBus.Emit(events.ToolsExecResultsEvent{
 		AgentID:     event.AgentID,
 		ToolResults: results,
@@ -147,6 +289,23 @@ func (tr *ToolRuntime) handleToolsExecRequest(ctx context.Context, event events.
 }
 
 func (tr *ToolRuntime) toolExec(ctx context.Context, toolName string, arguments map[string]any, agentID, toolCallID string) (string, error) {
+	ctx = withToolCall(ctx, ToolCallInfo{
+		AgentID:    agentID,
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+	})
+	// Ask before taking a limiter slot, the user may take a while to answer.
+	if err := tr.approve(ctx, toolName, arguments, agentID, toolCallID); err != nil {
+		tr.emitErrorEvent(agentID, toolCallID, toolName, err)
+		return "", err
+	}
+	release, err := tr.acquireLimits(ctx, toolName, agentID, toolCallID)
+	if err != nil {
+		tr.emitErrorEvent(agentID, toolCallID, toolName, err)
+		return "", err
+	}
+	defer release()
+
 	tr.eventBus.Emit(events.ToolExecStartEvent{
 		AgentID:    agentID,
 		ToolCallID: toolCallID,
@@ -154,7 +313,7 @@ func (tr *ToolRuntime) toolExec(ctx context.Context, toolName string, arguments
 		Arguments:  arguments,
 	})
 
-	tool, exists := tr.tools[toolName]
+	tool, exists := tr.tool(toolName)
 	if !exists {
 		err := fmt.Errorf("tool '%s' not found", toolName)
 		tr.emitErrorEvent(agentID, toolCallID, toolName, err)
@@ -162,14 +321,29 @@ func (tr *ToolRuntime) toolExec(ctx context.Context, toolName string, arguments
 	}
 
 	if toolName == CREATE_SUB_AGENT_TOOL_NAME {
+		// The arguments are shared with the events of the LLM response.
+		arguments = maps.Clone(arguments)
+		if arguments == nil {
+			arguments = make(map[string]any)
+		}
 		arguments["agentID"] = agentID
 	}
+	start := time.Now()
 	result, err := tr.executeToolFunction(ctx, tool, arguments)
+	logger := tr.logger.With(
+		"agent_id", agentID,
+		"parent_agent_id", GetParentAgentID(agentID),
+		"tool_name", toolName,
+		"tool_call_id", toolCallID,
+		"duration", time.Since(start),
+	)
 
 	if err != nil {
+		logger.Warn("tool call failed", "error", err)
 		tr.emitErrorEvent(agentID, toolCallID, toolName, err)
 		return "", err
 	}
+	logger.Info("tool call finished")
 
 	tr.eventBus.Emit(events.ToolExecFinishEvent{
 		AgentID:    agentID,
@@ -181,7 +355,186 @@ func (tr *ToolRuntime) toolExec(ctx context.Context, toolName string, arguments
 	return result, nil
 }
 
+type namedSemaphore struct {
+	name string
+	sem  semaphore
+	// done is called once the call is over with the semaphore.
+	done func()
+}
+
+// sharedSemaphore is a per-primary agent semaphore, dropped once nobody
+// holds or waits for it.
+type sharedSemaphore struct {
+	sem   semaphore
+	users int
+}
+
+func (tr *ToolRuntime) limitsFor(toolName, agentID string) []namedSemaphore {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	limits := []namedSemaphore{}
+	if sem, exists := tr.toolLimits[toolName]; exists {
+		limits = append(limits, namedSemaphore{name: "tool:" + toolName, sem: sem})
+	}
+	// Sub-agents are not counted against tool_exec, so a waiting parent
+	// never holds a slot its sub-agent needs. Their own limits are taken
+	// by runSubAgent.
+	if toolName != CREATE_SUB_AGENT_TOOL_NAME {
+		limits = append(limits, namedSemaphore{name: "tool_exec", sem: tr.toolExecLimit})
+	}
+	return limits
+}
+
+// subAgentLimitsFor returns the limits a sub-agent created by agentID runs
+// in, counted against the primary agent at the root of its tree.
+func (tr *ToolRuntime) subAgentLimitsFor(agentID string) []namedSemaphore {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	primaryAgentID := agentID
+	if !IsPrimaryAgent(agentID) {
+		if id, err := GetPrimaryAgentIDFromSubAgentID(agentID); err == nil {
+			primaryAgentID = id
+		}
+	}
+	shared, exists := tr.subAgentsPerPrimaryAgents[primaryAgentID]
+	if !exists {
+		shared = &sharedSemaphore{sem: newSemaphore(tr.subAgentsPerPrimaryLimit)}
+		tr.subAgentsPerPrimaryAgents[primaryAgentID] = shared
+	}
+	shared.users++
+	done := func() {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		if shared.users--; shared.users == 0 && tr.subAgentsPerPrimaryAgents[primaryAgentID] == shared {
+			delete(tr.subAgentsPerPrimaryAgents, primaryAgentID)
+		}
+	}
+	return []namedSemaphore{
+		{name: "sub_agents:" + primaryAgentID, sem: shared.sem, done: done},
+		{name: "sub_agents", sem: tr.subAgentLimit},
+	}
+}
+
+func (tr *ToolRuntime) acquireLimits(ctx context.Context, toolName, agentID, toolCallID string) (func(), error) {
+	limits := tr.limitsFor(toolName, agentID)
+	if err := tr.acquireQueued(ctx, limits, toolName, agentID, toolCallID); err != nil {
+		return nil, err
+	}
+	return func() { releaseSemaphores(limits) }, nil
+}
+
+// acquireQueued takes the semaphores of limits in order, reporting the
+// call as queued on each one it has to wait for.
+func (tr *ToolRuntime) acquireQueued(ctx context.Context, limits []namedSemaphore, toolName, agentID, toolCallID string) error {
+	return acquireSemaphores(ctx, limits, func(limit namedSemaphore) {
+		tr.logger.Debug("tool call queued", "agent_id", agentID, "tool_name", toolName, "tool_call_id", toolCallID, "limiter", limit.name)
+		tr.eventBus.Emit(events.ToolExecQueuedEvent{
+			AgentID:    agentID,
+			ToolCallID: toolCallID,
+			ToolName:   toolName,
+			Limiter:    limit.name,
+		})
+	})
+}
+
+// acquireSemaphores takes the semaphores of limits in order, calling queued
+// before waiting for one. Nothing is held if it fails.
+func acquireSemaphores(ctx context.Context, limits []namedSemaphore, queued func(namedSemaphore)) error {
+	for i, limit := range limits {
+		if limit.sem.tryAcquire() {
+			continue
+		}
+		if queued != nil {
+			queued(limit)
+		}
+		if err := limit.sem.acquire(ctx); err != nil {
+			releaseSemaphores(limits[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func releaseSemaphores(limits []namedSemaphore) {
+	for i := len(limits) - 1; i >= 0; i-- {
+		limits[i].sem.release()
+	}
+}
+
+// heldLimits are the slots of a running sub-agent. They are given back while
+// the sub-agent waits for its own sub-agents, which would otherwise never
+// get a slot once every slot is taken by a waiting parent.
+type heldLimits struct {
+	mu        sync.Mutex
+	limits    []namedSemaphore
+	held      bool
+	suspended int
+	closed    bool
+}
+
+// suspend gives the slots back until the matching resume.
+func (h *heldLimits) suspend() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.suspended++
+	if h.held {
+		releaseSemaphores(h.limits)
+		h.held = false
+	}
+}
+
+// resume takes the slots again once no sub-agent is waited for. It does
+// not hold the lock while it waits, a suspend or close coming meanwhile
+// finds the slots released and gets them released again afterwards.
+func (h *heldLimits) resume(ctx context.Context) {
+	h.mu.Lock()
+	h.suspended--
+	if h.suspended > 0 || h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.mu.Unlock()
+
+	if err := acquireSemaphores(ctx, h.limits, nil); err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.suspended > 0 || h.closed || h.held {
+		releaseSemaphores(h.limits)
+		return
+	}
+	h.held = true
+}
+
+// close gives the slots back for good.
+func (h *heldLimits) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	if h.held {
+		releaseSemaphores(h.limits)
+		h.held = false
+	}
+	for _, limit := range h.limits {
+		if limit.done != nil {
+			limit.done()
+		}
+	}
+}
+
 func (tr *ToolRuntime) executeToolFunction(ctx context.Context, tool *Tool, arguments map[string]any) (string, error) {
+	if raw, ok := tool.Function.(RawToolFunction); ok {
+		for _, param := range tool.Parameters {
+			if _, exists := arguments[param.Name]; !exists && param.Required {
+				return "", fmt.Errorf("missing required argument: %s", param.Name)
+			}
+		}
+		return raw(ctx, arguments)
+	}
+
 	fnValue := reflect.ValueOf(tool.Function)
 	fnType := reflect.TypeOf(tool.Function)
 
@@ -304,6 +657,18 @@ func (tr *ToolRuntime) resultToString(result any) (string, error) {
 }
 
 func (tr *ToolRuntime) SetupSubAgentTool() {
+	tr.mu.RLock()
+	profiles := make([]AgentProfile, 0, len(tr.profiles))
+	for _, profile := range tr.profiles {
+		profiles = append(profiles, profile)
+	}
+	tr.mu.RUnlock()
+
+	if len(profiles) > 0 {
+		tr.setupProfiledSubAgentTool(profiles)
+		return
+	}
+
 	tr.Register(CREATE_SUB_AGENT_TOOL_NAME,
 		"Create a sub-agent to handle a specific task",
 		tr.createSubAgentTool,
@@ -326,34 +691,121 @@ func (tr *ToolRuntime) SetupSubAgentTool() {
 		})
 }
 
-func (tr *ToolRuntime) createSubAgentTool(ctx context.Context, task string, toolNameList []string, agentID string) (string, error) {
-	subAgentID := GenerateSubAgentID(agentID)
+func (tr *ToolRuntime) setupProfiledSubAgentTool(profiles []AgentProfile) {
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	names := make([]string, 0, len(profiles))
+	var description strings.Builder
+	description.WriteString("Create a sub-agent from one of the available profiles to handle a specific task.\nProfiles:")
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+		description.WriteString("\n- " + profile.Name)
+		if profile.Description != "" {
+			description.WriteString(": " + profile.Description)
+		}
+	}
 
-	tr.mu.Lock()
-	tr.subAgentResults[subAgentID] = make(chan string, 1)
-	resultChan := tr.subAgentResults[subAgentID]
-	tr.mu.Unlock()
-	tr.eventBus.Emit(events.AgentCreateEvent{
-		AgentID:     subAgentID,
+	tr.Register(CREATE_SUB_AGENT_TOOL_NAME,
+		description.String(),
+		tr.createProfiledSubAgentTool,
+		[]llminterface.ToolParamSchema{
+			{
+				Type:        "string",
+				Name:        "task",
+				Description: "Task for the sub-agent to accomplish",
+				Required:    true,
+			},
+			{
+				Type:        "string",
+				Name:        "profile",
+				Description: "Name of the profile the sub-agent runs with",
+				Required:    true,
+				Enum:        names,
+			},
+		})
+}
+
+func (tr *ToolRuntime) createSubAgentTool(ctx context.Context, task string, toolNameList []string, agentID string) (string, error) {
+	return tr.runSubAgent(ctx, agentID, events.AgentCreateEvent{
 		Task:        task,
 		ToolSchemas: tr.getToolSchemas(toolNameList),
-	})
+	}, 0)
+}
+
+func (tr *ToolRuntime) createProfiledSubAgentTool(ctx context.Context, task string, profileName string, agentID string) (string, error) {
+	tr.mu.RLock()
+	profile, exists := tr.profiles[profileName]
+	tr.mu.RUnlock()
+	if !exists {
+		return "", fmt.Errorf("unknown agent profile '%s'", profileName)
+	}
 
-	select {
-	case result := <-resultChan:
-		return result, nil
-	case <-ctx.Done():
-		return "", ctx.Err()
-	case <-time.After(5 * time.Minute):
+	return tr.runSubAgent(ctx, agentID, events.AgentCreateEvent{
+		Task:         task,
+		ToolSchemas:  tr.getToolSchemas(profile.ToolNames),
+		SystemPrompt: profile.SystemPrompt,
+		Profile:      profile.Name,
+		MaxTurns:     profile.MaxTurns,
+	}, profile.MaxConcurrentToolCalls)
+}
+
+func (tr *ToolRuntime) runSubAgent(ctx context.Context, agentID string, createEvent events.AgentCreateEvent, toolCallLimit int) (string, error) {
+	subAgentID := GenerateSubAgentID(agentID)
+	createEvent.AgentID = subAgentID
+	createEvent.RequestID = NewRequestID()
+
+	// A sub-agent waiting for its own sub-agent does not hold a slot.
+	tr.mu.RLock()
+	parent := tr.subAgentSlots[agentID]
+	tr.mu.RUnlock()
+	if parent != nil {
+		parent.suspend()
+		defer parent.resume(ctx)
+	}
+	slots := &heldLimits{limits: tr.subAgentLimitsFor(agentID)}
+	defer slots.close()
+	toolCall, _ := ToolCallFromContext(ctx)
+	if err := tr.acquireQueued(ctx, slots.limits, CREATE_SUB_AGENT_TOOL_NAME, agentID, toolCall.ToolCallID); err != nil {
+		return "", err
+	}
+	slots.held = true
+	tr.mu.Lock()
+	tr.subAgentSlots[subAgentID] = slots
+	tr.mu.Unlock()
+	defer func() {
+		tr.mu.Lock()
+		delete(tr.subAgentSlots, subAgentID)
+		tr.mu.Unlock()
+	}()
+
+	if toolCallLimit > 0 {
+		tr.mu.Lock()
+		tr.agentToolCallLimits[subAgentID] = toolCallLimit
+		tr.mu.Unlock()
+		defer func() {
+			tr.mu.Lock()
+			delete(tr.agentToolCallLimits, subAgentID)
+			tr.mu.Unlock()
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	finish, err := eventbus.Request[events.AgentCreateEvent, events.SubAgentFinishEvent](ctx, tr.eventBus, createEvent)
+	if errors.Is(err, context.DeadlineExceeded) {
 		return "", fmt.Errorf("sub-agent timeout")
 	}
+	if err != nil {
+		return "", err
+	}
+	return finish.Result, nil
 }
 
 func (tr *ToolRuntime) getToolSchemas(toolNames []string) []llminterface.ToolSchema {
 	schemas := make([]llminterface.ToolSchema, 0, len(toolNames))
 
 	for _, toolName := range toolNames {
-		if tool, exists := tr.tools[toolName]; exists {
+		if tool, exists := tr.tool(toolName); exists {
 			schemas = append(schemas, llminterface.ToolSchema{
 				Name:        tool.Name,
 				Description: tool.Description,
@@ -369,7 +821,7 @@ func (tr *ToolRuntime) GetToolSchemas(names []string) []llminterface.ToolSchema
 	schemas := make([]llminterface.ToolSchema, 0, len(names))
 
 	for _, name := range names {
-		if tool, exists := tr.tools[name]; exists {
+		if tool, exists := tr.tool(name); exists {
 			schemas = append(schemas, tool.ToolSchema)
 		}
 	}
@@ -386,31 +838,19 @@ func (tr *ToolRuntime) emitErrorEvent(agentID, toolCallID, toolName string, err
 	})
 }
 
+// GetToolNames returns the names of the registered tools, sorted so that
+// the schemas offered to the LLM keep the same order from run to run.
 func (tr *ToolRuntime) GetToolNames() []string {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
 	names := make([]string, 0, len(tr.tools))
 	for name := range tr.tools {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-func (tr *ToolRuntime) HandleAgentFinishEvent(ctx context.Context, event events.AgentFinishEvent) {
-	if IsPrimaryAgent(event.AgentID) {
-		return
-	}
-	tr.mu.Lock()
-	defer tr.mu.Unlock()
-	if _, exists := tr.subAgentResults[event.AgentID]; !exists {
-		panic("sub-agent result channel for " + event.AgentID + " does not exist")
-	}
-	select {
-	case tr.subAgentResults[event.AgentID] <- event.Result:
-	default:
-	}
-	close(tr.subAgentResults[event.AgentID])
-	delete(tr.subAgentResults, event.AgentID)
-}
-
 func (tr *ToolRuntime) HandleToolRuntimeErrorEvent(ctx context.Context, event events.ToolRuntimeErrorEvent) {
 	tr.eventBus.Emit(events.ToolsExecResultsEvent{
 		AgentID:     event.AgentID,
@@ -418,11 +858,23 @@ func (tr *ToolRuntime) HandleToolRuntimeErrorEvent(ctx context.Context, event ev
 	})
 }
 
-func (tr *ToolRuntime) HandleAgentLauncherShutdownEvent(ctx context.Context, event events.AgentLauncherShutdownEvent) {
+func (tr *ToolRuntime) HandleToolSchemasRequestEvent(ctx context.Context, event events.ToolSchemasRequestEvent) {
+	tr.SetupSubAgentTool()
+	tr.eventBus.Emit(events.ToolSchemasResponseEvent{
+		AgentID:         event.AgentID,
+		ToolSchemas:     tr.GetToolSchemas(tr.GetToolNames()),
+		SubAgentsAtOnce: tr.SubAgentsAtOnce(),
+	})
+}
+
+// HandleTaskCancelEvent cancels the context of every tool call still running
+// for the task's agents.
+func (tr *ToolRuntime) HandleTaskCancelEvent(ctx context.Context, event events.TaskCancelEvent) {
 	tr.mu.Lock()
 	defer tr.mu.Unlock()
-	for agentID, ch := range tr.subAgentResults {
-		close(ch)
-		delete(tr.subAgentResults, agentID)
+	for agentID, cancel := range tr.running {
+		if events.InAgentTree(agentID, event.AgentID) {
+			cancel()
+		}
 	}
 }
@@ -0,0 +1,136 @@
+package runtimes
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ASK_USER_TOOL_NAME   string        = "ask_user"
+	DEFAULT_USER_TIMEOUT time.Duration = 10 * time.Minute
+)
+
+type ToolCallInfo struct {
+	AgentID    string
+	ToolCallID string
+	ToolName   string
+}
+
+type toolCallContextKey struct{}
+
+// ToolCallFromContext returns the tool call a tool function is running for.
+func ToolCallFromContext(ctx context.Context) (ToolCallInfo, bool) {
+	info, ok := ctx.Value(toolCallContextKey{}).(ToolCallInfo)
+	return info, ok
+}
+
+func withToolCall(ctx context.Context, info ToolCallInfo) context.Context {
+	return context.WithValue(ctx, toolCallContextKey{}, info)
+}
+
+// RequireApproval makes calls to the named tools wait for a
+// ToolApprovalResponseEvent before they run.
+func (tr *ToolRuntime) RequireApproval(names ...string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for _, name := range names {
+		tr.approvals[name] = true
+	}
+}
+
+// WithUserTimeout sets how long tool approvals and ask_user questions wait
+// for the user, DEFAULT_USER_TIMEOUT by default. Unanswered approvals are
+// denied. Either wait also ends when the task is cancelled or times out.
+func (tr *ToolRuntime) WithUserTimeout(timeout time.Duration) *ToolRuntime {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if timeout > 0 {
+		tr.userTimeout = timeout
+	}
+	return tr
+}
+
+// waitForUser bounds ctx by the user timeout.
+func (tr *ToolRuntime) waitForUser(ctx context.Context) (context.Context, context.CancelFunc, time.Duration) {
+	tr.mu.RLock()
+	timeout := tr.userTimeout
+	tr.mu.RUnlock()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+func (tr *ToolRuntime) approve(ctx context.Context, toolName string, arguments map[string]any, agentID, toolCallID string) error {
+	tr.mu.RLock()
+	required := tr.approvals[toolName]
+	tr.mu.RUnlock()
+	if !required {
+		return nil
+	}
+
+	tr.logger.Info("waiting for tool approval", "agent_id", agentID, "tool_name", toolName, "tool_call_id", toolCallID)
+	waitCtx, cancel, timeout := tr.waitForUser(ctx)
+	defer cancel()
+	response, err := eventbus.Request[events.ToolApprovalRequestEvent, events.ToolApprovalResponseEvent](waitCtx, tr.eventBus, events.ToolApprovalRequestEvent{
+		AgentID:    agentID,
+		ToolCallID: toolCallID,
+		ToolName:   toolName,
+		Arguments:  arguments,
+	})
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		tr.logger.Warn("tool approval timed out", "agent_id", agentID, "tool_name", toolName, "tool_call_id", toolCallID)
+		return fmt.Errorf("tool call denied: no approval within %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("tool approval: %w", err)
+	}
+	if !response.Approved {
+		if response.Reason != "" {
+			return fmt.Errorf("tool call denied by user: %s", response.Reason)
+		}
+		return fmt.Errorf("tool call denied by user")
+	}
+	return nil
+}
+
+// SetupAskUserTool registers the ask_user tool, which lets agents ask the
+// user a question and wait for a UserInputResponseEvent.
+func (tr *ToolRuntime) SetupAskUserTool() {
+	tr.Register(ASK_USER_TOOL_NAME,
+		"Ask the user a question and wait for the answer. Use it when the task is ambiguous or needs information only the user has.",
+		tr.askUserTool,
+		[]llminterface.ToolParamSchema{
+			{
+				Type:        "string",
+				Name:        "question",
+				Description: "The question for the user",
+				Required:    true,
+			},
+		})
+}
+
+func (tr *ToolRuntime) askUserTool(ctx context.Context, question string) (string, error) {
+	info, ok := ToolCallFromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("ask_user called outside of a tool call")
+	}
+	waitCtx, cancel, timeout := tr.waitForUser(ctx)
+	defer cancel()
+	response, err := eventbus.Request[events.UserInputRequestEvent, events.UserInputResponseEvent](waitCtx, tr.eventBus, events.UserInputRequestEvent{
+		AgentID:   info.AgentID,
+		RequestID: info.ToolCallID,
+		Question:  question,
+	})
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		tr.logger.Warn("user question timed out", "agent_id", info.AgentID, "tool_call_id", info.ToolCallID)
+		return "", fmt.Errorf("the user did not answer within %s", timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+	return response.Answer, nil
+}
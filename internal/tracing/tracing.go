@@ -0,0 +1,308 @@
+// Package tracing turns the event stream of a bus into OpenTelemetry spans:
+// one root span per task, with child spans for every agent, LLM call and
+// tool execution.
+//
+// Spans are rebuilt from the events a process dispatches. Across a
+// transport, events carry the W3C trace context of the span they belong to:
+// a process does not start spans again for remote events that came with a
+// trace, it records the remote span as the parent of the spans it starts
+// for the same agent, e.g. the tool calls of a worker, so a launcher and its
+// workers record one trace per task.
+package tracing
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/runtimes"
+	"context"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "agentlauncher"
+
+type Tracer struct {
+	tracer    trace.Tracer
+	tasks     map[string]trace.Span
+	agents    map[string]trace.Span
+	llmCalls  map[string]trace.Span
+	toolCalls map[string]trace.Span
+	// remote holds the latest span of another process for an agent.
+	remote map[string]trace.SpanContext
+	mu     sync.Mutex
+}
+
+func New(provider trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer:    provider.Tracer(instrumentationName),
+		tasks:     make(map[string]trace.Span),
+		agents:    make(map[string]trace.Span),
+		llmCalls:  make(map[string]trace.Span),
+		toolCalls: make(map[string]trace.Span),
+		remote:    make(map[string]trace.SpanContext),
+	}
+}
+
+// Install records spans from the events dispatched on eb, and gives every
+// handler, and the transport of eb, a context carrying the span of the agent
+// its event belongs to.
+func (t *Tracer) Install(eb *eventbus.EventBus) {
+	eb.Use(t.intercept)
+	eb.WrapHandlers(t.propagate)
+	eb.UseTransportContext(t.ContextFor)
+}
+
+func (t *Tracer) intercept(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The process that emitted a traced remote event started its spans.
+	traced := false
+	if remote := trace.SpanContextFromContext(ctx); remote.IsValid() && eventbus.Remote(ctx) {
+		if agentID := eventbus.AgentIDOf(event); agentID != "" {
+			t.remote[agentID] = remote
+		}
+		traced = true
+	}
+
+	switch e := event.(type) {
+	case events.TaskCreateEvent:
+		if traced {
+			break
+		}
+		_, span := t.tracer.Start(context.Background(), "task",
+			trace.WithNewRoot(),
+			trace.WithAttributes(
+				attribute.String("agent.id", e.AgentID),
+				attribute.String("task", e.Task),
+			))
+		t.tasks[e.AgentID] = span
+
+	case events.AgentCreateEvent:
+		if traced {
+			break
+		}
+		parent := t.parent(t.tasks, e.AgentID)
+		if !runtimes.IsPrimaryAgent(e.AgentID) {
+			parent = t.parent(t.agents, runtimes.GetParentAgentID(e.AgentID))
+		}
+		_, span := t.tracer.Start(parent, "agent",
+			trace.WithAttributes(
+				attribute.String("agent.id", e.AgentID),
+				attribute.String("agent.parent_id", runtimes.GetParentAgentID(e.AgentID)),
+				attribute.String("agent.profile", e.Profile),
+				attribute.Int("agent.depth", runtimes.GetAgentDepth(e.AgentID)),
+			))
+		t.agents[e.AgentID] = span
+
+	case events.AgentFinishEvent:
+		endSpan(t.agents, e.AgentID, "")
+
+	case events.AgentRuntimeErrorEvent:
+		if span, exists := t.agents[e.AgentID]; exists {
+			span.AddEvent("agent_error", trace.WithAttributes(attribute.String("error", e.Error)))
+		}
+
+	case events.TaskFinishEvent:
+		errorMessage := resultError(e.Result)
+		endSpan(t.llmCalls, e.AgentID, errorMessage)
+		endSpan(t.agents, e.AgentID, errorMessage)
+		endSpan(t.tasks, e.AgentID, errorMessage)
+		delete(t.remote, e.AgentID)
+
+	case events.SubAgentFinishEvent:
+		errorMessage := resultError(e.Result)
+		endSpan(t.llmCalls, e.AgentID, errorMessage)
+		endSpan(t.agents, e.AgentID, errorMessage)
+		delete(t.remote, e.AgentID)
+
+	case events.LLMRequestEvent:
+		if traced {
+			break
+		}
+		_, span := t.tracer.Start(t.parent(t.agents, e.AgentID), "llm",
+			trace.WithAttributes(
+				attribute.String("agent.id", e.AgentID),
+				attribute.String("agent.profile", e.Profile),
+				attribute.Int("llm.retry_count", e.RetryCount),
+				attribute.Int("llm.request.messages", len(e.Messages)),
+				attribute.Int("llm.request.tools", len(e.ToolSchemas)),
+			))
+		t.llmCalls[e.AgentID] = span
+
+	case events.LLMRouteDecisionEvent:
+		if span, exists := t.llmCalls[e.AgentID]; exists {
+			span.SetAttributes(
+				attribute.StringSlice("llm.route.candidates", e.Candidates),
+				attribute.Int("llm.estimated_tokens", e.EstimatedTokens),
+			)
+		}
+
+	case events.LLMRouteFailedEvent:
+		if span, exists := t.llmCalls[e.AgentID]; exists {
+			span.AddEvent("route_failed", trace.WithAttributes(
+				attribute.String("llm.route", e.Route),
+				attribute.Int("llm.route.attempt", e.Attempt),
+				attribute.String("error", e.Error),
+			))
+		}
+
+	case events.LLMUsageEvent:
+		if span, exists := t.llmCalls[e.AgentID]; exists {
+			span.SetAttributes(
+				attribute.String("llm.model", e.Model),
+				attribute.Int("llm.usage.input_tokens", e.InputTokens),
+				attribute.Int("llm.usage.output_tokens", e.OutputTokens),
+			)
+		}
+
+	case events.LLMResponseEvent:
+		if span, exists := t.llmCalls[e.AgentID]; exists {
+			span.SetAttributes(
+				attribute.String("llm.route", e.Route),
+				attribute.Int("llm.response.messages", len(e.Response)),
+			)
+		}
+		endSpan(t.llmCalls, e.AgentID, "")
+
+	case events.LLMRuntimeErrorEvent:
+		endSpan(t.llmCalls, e.AgentID, e.Error)
+
+	case events.ToolExecQueuedEvent:
+		if span, exists := t.agents[e.AgentID]; exists {
+			span.AddEvent("tool_queued", trace.WithAttributes(
+				attribute.String("tool.name", e.ToolName),
+				attribute.String("tool.call_id", e.ToolCallID),
+				attribute.String("tool.limiter", e.Limiter),
+			))
+		}
+
+	case events.ToolExecStartEvent:
+		if traced {
+			break
+		}
+		_, span := t.tracer.Start(t.parent(t.agents, e.AgentID), "tool "+e.ToolName,
+			trace.WithAttributes(
+				attribute.String("agent.id", e.AgentID),
+				attribute.String("tool.name", e.ToolName),
+				attribute.String("tool.call_id", e.ToolCallID),
+			))
+		t.toolCalls[toolCallKey(e.AgentID, e.ToolCallID)] = span
+
+	case events.ToolExecFinishEvent:
+		endSpan(t.toolCalls, toolCallKey(e.AgentID, e.ToolCallID), "")
+
+	case events.ToolExecErrorEvent:
+		endSpan(t.toolCalls, toolCallKey(e.AgentID, e.ToolCallID), e.Error)
+	}
+	return event, true
+}
+
+// parent returns a context with the span of key in spans, or else with the
+// remote span of the agent key, to start a child span from. The caller holds
+// t.mu.
+func (t *Tracer) parent(spans map[string]trace.Span, key string) context.Context {
+	if span, exists := spans[key]; exists {
+		return trace.ContextWithSpan(context.Background(), span)
+	}
+	if remote, exists := t.remote[key]; exists {
+		return trace.ContextWithRemoteSpanContext(context.Background(), remote)
+	}
+	return context.Background()
+}
+
+func (t *Tracer) propagate(name string, next eventbus.HandlerFunc) eventbus.HandlerFunc {
+	return func(ctx context.Context, event eventbus.Event) {
+		next(t.ContextFor(ctx, event), event)
+	}
+}
+
+// ContextFor returns ctx carrying the innermost open span for event: the
+// tool call it belongs to, or else its agent's LLM call, the agent itself,
+// its task or the span of another process it was last seen in.
+func (t *Tracer) ContextFor(ctx context.Context, event eventbus.Event) context.Context {
+	agentID := eventbus.AgentIDOf(event)
+	if agentID == "" {
+		return ctx
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if toolCallID := toolCallIDOf(event); toolCallID != "" {
+		if span, exists := t.toolCalls[toolCallKey(agentID, toolCallID)]; exists {
+			return trace.ContextWithSpan(ctx, span)
+		}
+	}
+	for _, spans := range []map[string]trace.Span{t.llmCalls, t.agents, t.tasks} {
+		if span, exists := spans[agentID]; exists {
+			return trace.ContextWithSpan(ctx, span)
+		}
+	}
+	if remote, exists := t.remote[agentID]; exists {
+		return trace.ContextWithRemoteSpanContext(ctx, remote)
+	}
+	return ctx
+}
+
+// Close ends every span that is still open, e.g. for tasks that never finished.
+func (t *Tracer) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, spans := range []map[string]trace.Span{t.toolCalls, t.llmCalls, t.agents, t.tasks} {
+		for key := range spans {
+			endSpan(spans, key, "unfinished")
+		}
+	}
+	clear(t.remote)
+}
+
+func endSpan(spans map[string]trace.Span, key, errorMessage string) {
+	span, exists := spans[key]
+	if !exists {
+		return
+	}
+	delete(spans, key)
+	if errorMessage != "" {
+		span.SetStatus(codes.Error, errorMessage)
+	}
+	span.End()
+}
+
+// resultError returns the error of an agent's result, or "" when it succeeded.
+func resultError(result string) string {
+	if result := strings.TrimSpace(result); strings.HasPrefix(result, "Error: ") {
+		return strings.TrimPrefix(result, "Error: ")
+	}
+	return ""
+}
+
+func toolCallKey(agentID, toolCallID string) string {
+	return agentID + "/" + toolCallID
+}
+
+// toolCallIDOf returns the tool call an event belongs to, if any. The
+// ask_user tool asks with the ID of its tool call.
+func toolCallIDOf(event eventbus.Event) string {
+	switch e := event.(type) {
+	case events.ToolExecStartEvent:
+		return e.ToolCallID
+	case events.ToolExecFinishEvent:
+		return e.ToolCallID
+	case events.ToolExecErrorEvent:
+		return e.ToolCallID
+	case events.ToolExecQueuedEvent:
+		return e.ToolCallID
+	case events.ToolApprovalRequestEvent:
+		return e.ToolCallID
+	case events.ToolApprovalResponseEvent:
+		return e.ToolCallID
+	case events.UserInputRequestEvent:
+		return e.RequestID
+	case events.UserInputResponseEvent:
+		return e.RequestID
+	}
+	return ""
+}
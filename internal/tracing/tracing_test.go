@@ -0,0 +1,197 @@
+package tracing_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/internal/transport"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newInMemoryProvider returns a provider that exports finished spans
+// synchronously to the returned exporter.
+func newInMemoryProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string, attribute, value string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name != name {
+			continue
+		}
+		for _, kv := range span.Attributes {
+			if string(kv.Key) == attribute && kv.Value.Emit() == value {
+				return span
+			}
+		}
+	}
+	t.Fatalf("no %s span with %s=%s", name, attribute, value)
+	return tracetest.SpanStub{}
+}
+
+func TestSpans(t *testing.T) {
+	provider, exporter := newInMemoryProvider()
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("add 1 and 2", "add"), llmtest.Text("3")).
+		ForSubAgent(llmtest.ToolCall("add", map[string]any{"a": 1.0, "b": 2.0}), llmtest.Text("3"))
+	add := func(ctx context.Context, a, b int) (string, error) {
+		return fmt.Sprint(a + b), nil
+	}
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTracing(provider).
+		WithTool("add", "Add two numbers", add, []llminterface.ToolParamSchema{
+			{Name: "a", Type: "integer", Required: true},
+			{Name: "b", Type: "integer", Required: true},
+		})
+	al.Run("what is 1 + 2?", nil)
+	al.Close()
+
+	spans := exporter.GetSpans()
+	subAgentID := llm.SubAgentIDs()[0]
+	task := spanNamed(t, spans, "task", "agent.id", "agent0")
+	primary := spanNamed(t, spans, "agent", "agent.id", "agent0")
+	subAgent := spanNamed(t, spans, "agent", "agent.id", subAgentID)
+	createSubAgent := spanNamed(t, spans, "tool "+runtimes.CREATE_SUB_AGENT_TOOL_NAME, "agent.id", "agent0")
+	addTool := spanNamed(t, spans, "tool add", "agent.id", subAgentID)
+
+	for _, check := range []struct {
+		name          string
+		child, parent tracetest.SpanStub
+	}{
+		{"primary agent", primary, task},
+		{"sub-agent", subAgent, primary},
+		{"create_sub_agent", createSubAgent, primary},
+		{"add", addTool, subAgent},
+	} {
+		if check.child.Parent.SpanID() != check.parent.SpanContext.SpanID() {
+			t.Errorf("%s span has the wrong parent", check.name)
+		}
+	}
+	for _, span := range spans {
+		if span.SpanContext.TraceID() != task.SpanContext.TraceID() {
+			t.Errorf("%s span is not in the task's trace", span.Name)
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s span failed: %s", span.Name, span.Status.Description)
+		}
+	}
+	if llmCalls := len(spans) - 5; llmCalls != 4 {
+		t.Errorf("expected 4 llm spans, got %d", llmCalls)
+	}
+}
+
+func TestContextForToolCall(t *testing.T) {
+	provider, exporter := newInMemoryProvider()
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.ToolCall(runtimes.ASK_USER_TOOL_NAME, map[string]any{"question": "which?"}), llmtest.Text("ok"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTracing(provider).
+		EnableAskUserTool()
+
+	asked := make(chan trace.SpanContext, 1)
+	launcher.SubscribeEvent(al, func(ctx context.Context, e events.UserInputRequestEvent) {
+		asked <- trace.SpanContextFromContext(ctx)
+		al.AnswerUser(e.AgentID, e.RequestID, "this one")
+	})
+	al.Run("pick one", nil)
+	al.Close()
+
+	askUser := spanNamed(t, exporter.GetSpans(), "tool "+runtimes.ASK_USER_TOOL_NAME, "agent.id", "agent0")
+	if spanContext := <-asked; spanContext.SpanID() != askUser.SpanContext.SpanID() {
+		t.Errorf("expected the question to be handled in the ask_user tool span")
+	}
+}
+
+func TestSpansAcrossBroker(t *testing.T) {
+	broker := transport.NewBroker()
+	if err := broker.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	dial := func() *transport.Conn {
+		conn, err := transport.Dial(broker.Addr(), events.NewRegistry())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("add 1 and 2", "add"), llmtest.Text("3")).
+		ForSubAgent(llmtest.ToolCall("add", map[string]any{"a": 1.0, "b": 2.0}), llmtest.Text("3"))
+	add := func(ctx context.Context, a, b int) (string, error) {
+		return fmt.Sprint(a + b), nil
+	}
+	workerProvider, workerExporter := newInMemoryProvider()
+	worker := launcher.NewWorker(llm.Handler(), llm.Handler(), eventbus.WithTransport(dial())).
+		WithTracing(workerProvider).
+		WithTool("add", "Add two numbers", add, []llminterface.ToolParamSchema{
+			{Name: "a", Type: "integer", Required: true},
+			{Name: "b", Type: "integer", Required: true},
+		})
+	launcherProvider, launcherExporter := newInMemoryProvider()
+	al := launcher.NewRemoteAgentLauncher(eventbus.WithTransport(dial())).
+		WithTracing(launcherProvider)
+
+	// The broker forwards to the worker once it has accepted it.
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := al.ToolSchemas(ctx)
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	al.Run("what is 1 + 2?", nil)
+	al.Close()
+	worker.Close()
+
+	launcherSpans := launcherExporter.GetSpans()
+	workerSpans := workerExporter.GetSpans()
+	spans := append(slices.Clone(launcherSpans), workerSpans...)
+	subAgentID := llm.SubAgentIDs()[0]
+	task := spanNamed(t, launcherSpans, "task", "agent.id", "agent0")
+	primary := spanNamed(t, launcherSpans, "agent", "agent.id", "agent0")
+	subAgent := spanNamed(t, spans, "agent", "agent.id", subAgentID)
+	createSubAgent := spanNamed(t, workerSpans, "tool "+runtimes.CREATE_SUB_AGENT_TOOL_NAME, "agent.id", "agent0")
+	addTool := spanNamed(t, workerSpans, "tool add", "agent.id", subAgentID)
+
+	for _, check := range []struct {
+		name          string
+		child, parent tracetest.SpanStub
+	}{
+		{"primary agent", primary, task},
+		{"sub-agent", subAgent, primary},
+		{"create_sub_agent", createSubAgent, primary},
+		{"add", addTool, subAgent},
+	} {
+		if check.child.Parent.SpanID() != check.parent.SpanContext.SpanID() {
+			t.Errorf("%s span has the wrong parent", check.name)
+		}
+	}
+	for _, span := range spans {
+		if span.SpanContext.TraceID() != task.SpanContext.TraceID() {
+			t.Errorf("%s span is not in the task's trace", span.Name)
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s span failed: %s", span.Name, span.Status.Description)
+		}
+	}
+	if llmCalls := len(spans) - 5; llmCalls != 4 {
+		t.Errorf("expected 4 llm spans in both processes together, got %d", llmCalls)
+	}
+}
@@ -0,0 +1,241 @@
+// Package transport carries bus events between processes over TCP.
+//
+// The protocol is JSON lines: every line is an eventcodec.Envelope,
+// {"type":"LLMRequestEvent","event":{...}}, with the W3C traceparent of the
+// trace the event belongs to, if any, in "traceparent". Clients connect to a
+// Broker, which forwards each line it receives to the other clients, in the
+// order it arrived.
+//
+// A client may claim event types with a {"claim":["LLMRequestEvent"]} line.
+// Each event of a claimed type then goes to only one of the clients claiming
+// it, taking turns, so workers share requests instead of all handling them.
+// Clients that did not claim the type still receive every event of it, and
+// an event sent by a claiming client is not handed to the others, it was
+// handled where it was emitted.
+package transport
+
+import (
+	"agentlauncher/internal/logging"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"sync"
+)
+
+const maxLineSize = 64 * 1024 * 1024
+
+type Broker struct {
+	listener net.Listener
+	// clients are kept in connection order, claims are served in turns.
+	clients []*brokerClient
+	turns   map[string]int
+	closed  bool
+	logger  *slog.Logger
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+}
+
+type brokerClient struct {
+	conn     net.Conn
+	outgoing chan []byte
+	claims   map[string]bool
+}
+
+// header is the part of a line the broker routes on.
+type header struct {
+	Type  string   `json:"type"`
+	Claim []string `json:"claim"`
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		turns:  make(map[string]int),
+		logger: logging.Discard(),
+	}
+}
+
+// WithLogger reports the clients dropped for not keeping up and the events
+// lost with them.
+func (b *Broker) WithLogger(logger *slog.Logger) *Broker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.logger = logging.Subsystem(logger, logging.EVENTBUS)
+	return b
+}
+
+// Listen starts accepting clients on addr, e.g. "127.0.0.1:0".
+func (b *Broker) Listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	b.listener = listener
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go b.accept(listener)
+	return nil
+}
+
+func (b *Broker) Addr() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.listener == nil {
+		return ""
+	}
+	return b.listener.Addr().String()
+}
+
+func (b *Broker) accept(listener net.Listener) {
+	defer b.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		client := &brokerClient{
+			conn:     conn,
+			outgoing: make(chan []byte, 1024),
+			claims:   make(map[string]bool),
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		b.clients = append(b.clients, client)
+		b.mu.Unlock()
+
+		b.wg.Add(2)
+		go b.read(client)
+		go b.write(client)
+	}
+}
+
+func (b *Broker) read(client *brokerClient) {
+	defer b.wg.Done()
+	defer b.remove(client)
+
+	scanner := bufio.NewScanner(client.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var h header
+		if err := json.Unmarshal(scanner.Bytes(), &h); err != nil {
+			// Forwarded as is, receivers skip what they cannot decode.
+			h = header{}
+		}
+		line := append(append([]byte{}, scanner.Bytes()...), '\n')
+
+		b.mu.Lock()
+		if h.Claim != nil {
+			for _, eventType := range h.Claim {
+				client.claims[eventType] = true
+			}
+		} else {
+			b.route(client, h.Type, line)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// route sends line to every client but the sender that did not claim its
+// type, and to one of those that did unless the sender claimed it too. The
+// caller holds b.mu.
+func (b *Broker) route(sender *brokerClient, eventType string, line []byte) {
+	var claimants []*brokerClient
+	for _, other := range slices.Clone(b.clients) {
+		if other == sender {
+			continue
+		}
+		if eventType != "" && other.claims[eventType] {
+			claimants = append(claimants, other)
+			continue
+		}
+		if !b.send(other, line) {
+			b.logger.Error("slow client dropped", "client", other.conn.RemoteAddr().String(), "event_type", eventType)
+			b.drop(other)
+		}
+	}
+	if len(claimants) == 0 || sender.claims[eventType] {
+		return
+	}
+
+	// Take turns, skipping claimants that cannot keep up.
+	turn := b.turns[eventType]
+	for i := range claimants {
+		claimant := claimants[(turn+i)%len(claimants)]
+		if b.send(claimant, line) {
+			b.turns[eventType] = turn + i + 1
+			return
+		}
+	}
+	b.logger.Error("claimed event lost, every claimant is too slow", "event_type", eventType, "claimants", len(claimants))
+}
+
+// send queues line for client without blocking the other clients.
+func (b *Broker) send(client *brokerClient, line []byte) bool {
+	select {
+	case client.outgoing <- line:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *Broker) write(client *brokerClient) {
+	defer b.wg.Done()
+
+	writer := bufio.NewWriter(client.conn)
+	for line := range client.outgoing {
+		if _, err := writer.Write(line); err != nil {
+			client.conn.Close()
+			continue
+		}
+		if len(client.outgoing) == 0 {
+			if err := writer.Flush(); err != nil {
+				client.conn.Close()
+			}
+		}
+	}
+}
+
+func (b *Broker) remove(client *brokerClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(client)
+}
+
+// drop disconnects a client. The caller holds b.mu.
+func (b *Broker) drop(client *brokerClient) {
+	if i := slices.Index(b.clients, client); i >= 0 {
+		b.clients = slices.Delete(b.clients, i, i+1)
+		close(client.outgoing)
+	}
+	client.conn.Close()
+}
+
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	var err error
+	if b.listener != nil {
+		err = b.listener.Close()
+	}
+	for _, client := range b.clients {
+		client.conn.Close()
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
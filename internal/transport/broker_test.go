@@ -0,0 +1,190 @@
+package transport_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/transport"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func startBroker(t *testing.T) *transport.Broker {
+	t.Helper()
+	broker := transport.NewBroker()
+	if err := broker.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+func dial(t *testing.T, broker *transport.Broker) (*transport.Conn, <-chan eventbus.Event) {
+	t.Helper()
+	conn, err := transport.Dial(broker.Addr(), events.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	received := make(chan eventbus.Event, 16)
+	go conn.Receive(func(_ context.Context, event eventbus.Event) { received <- event })
+	return conn, received
+}
+
+// await publishes until the event arrives, the broker only forwards to
+// clients it has accepted.
+func await(t *testing.T, publish func(), received <-chan eventbus.Event) eventbus.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		publish()
+		select {
+		case event := <-received:
+			return event
+		case <-time.After(20 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
+
+func TestBrokerRoundTrip(t *testing.T) {
+	broker := startBroker(t)
+	sender, echoed := dial(t, broker)
+	_, received := dial(t, broker)
+
+	event := await(t, func() {
+		if err := sender.Publish(context.Background(), events.TaskCancelEvent{AgentID: "agent0", Reason: "stop"}); err != nil {
+			t.Fatal(err)
+		}
+	}, received)
+	cancel, ok := event.(events.TaskCancelEvent)
+	if !ok || cancel.AgentID != "agent0" || cancel.Reason != "stop" {
+		t.Errorf("expected the published TaskCancelEvent, got %#v", event)
+	}
+	select {
+	case event := <-echoed:
+		t.Errorf("the sender received its own event %#v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestConnSkipsUndecodableLines(t *testing.T) {
+	broker := startBroker(t)
+	raw, err := net.Dial("tcp", broker.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	_, received := dial(t, broker)
+
+	line, err := events.NewRegistry().Marshal(events.TaskCancelEvent{AgentID: "agent1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := await(t, func() {
+		raw.Write([]byte("not an event\n{\"type\":\"UnknownEvent\",\"event\":{}}\n"))
+		raw.Write(append(line, '\n'))
+	}, received)
+	if cancel, ok := event.(events.TaskCancelEvent); !ok || cancel.AgentID != "agent1" {
+		t.Errorf("expected the TaskCancelEvent after the bad lines, got %#v", event)
+	}
+}
+
+// ready returns once the broker has read everything conn sent so far: the
+// marker it publishes after it reaches observer.
+func ready(t *testing.T, conn *transport.Conn, observer <-chan eventbus.Event, marker string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		if err := conn.Publish(context.Background(), events.TaskCancelEvent{AgentID: marker}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case event := <-observer:
+			if cancel, ok := event.(events.TaskCancelEvent); ok && cancel.AgentID == marker {
+				return
+			}
+		case <-time.After(20 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("marker not received")
+		}
+	}
+}
+
+func requestsIn(received <-chan eventbus.Event, wait time.Duration) []string {
+	agentIDs := []string{}
+	for {
+		select {
+		case event := <-received:
+			if request, ok := event.(events.LLMRequestEvent); ok {
+				agentIDs = append(agentIDs, request.AgentID)
+			}
+		case <-time.After(wait):
+			return agentIDs
+		}
+	}
+}
+
+func TestBrokerHandsClaimedEventsToOneClaimant(t *testing.T) {
+	broker := startBroker(t)
+	_, observed := dial(t, broker)
+	first, firstReceived := dial(t, broker)
+	second, secondReceived := dial(t, broker)
+	for i, claimant := range []*transport.Conn{first, second} {
+		if err := claimant.Claim(events.LLMRequestEvent{}); err != nil {
+			t.Fatal(err)
+		}
+		ready(t, claimant, observed, fmt.Sprint("ready", i))
+	}
+	sender, _ := dial(t, broker)
+
+	const requests = 10
+	for i := range requests {
+		if err := sender.Publish(context.Background(), events.LLMRequestEvent{AgentID: fmt.Sprint("agent", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all := requestsIn(observed, 200*time.Millisecond)
+	firstGot := requestsIn(firstReceived, 50*time.Millisecond)
+	secondGot := requestsIn(secondReceived, 50*time.Millisecond)
+
+	if len(all) != requests {
+		t.Errorf("expected the observer to receive all %d requests, got %v", requests, all)
+	}
+	if len(firstGot) != requests/2 || len(secondGot) != requests/2 {
+		t.Errorf("expected the claimants to take turns, got %v and %v", firstGot, secondGot)
+	}
+	seen := map[string]bool{}
+	for _, agentID := range append(firstGot, secondGot...) {
+		if seen[agentID] {
+			t.Errorf("request of %s handed to both claimants", agentID)
+		}
+		seen[agentID] = true
+	}
+}
+
+func TestBrokerKeepsClaimedEventsOfAClaimant(t *testing.T) {
+	broker := startBroker(t)
+	_, observed := dial(t, broker)
+	first, _ := dial(t, broker)
+	second, secondReceived := dial(t, broker)
+	for i, claimant := range []*transport.Conn{first, second} {
+		if err := claimant.Claim(events.LLMRequestEvent{}); err != nil {
+			t.Fatal(err)
+		}
+		ready(t, claimant, observed, fmt.Sprint("ready", i))
+	}
+
+	if err := first.Publish(context.Background(), events.LLMRequestEvent{AgentID: "agent0"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := requestsIn(observed, 200*time.Millisecond); len(got) != 1 {
+		t.Errorf("expected the observer to receive the request, got %v", got)
+	}
+	if got := requestsIn(secondReceived, 50*time.Millisecond); len(got) != 0 {
+		t.Errorf("expected the request to stay with the claimant that sent it, got %v", got)
+	}
+}
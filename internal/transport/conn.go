@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventcodec"
+	"agentlauncher/internal/logging"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Conn is an eventbus.Transport connected to a Broker.
+type Conn struct {
+	conn     net.Conn
+	registry *eventcodec.Registry
+	logger   *slog.Logger
+	mu       sync.Mutex
+}
+
+func Dial(addr string, registry *eventcodec.Registry) (*Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	return &Conn{conn: conn, registry: registry, logger: logging.Discard()}, nil
+}
+
+func (c *Conn) WithLogger(logger *slog.Logger) *Conn {
+	c.logger = logging.Subsystem(logger, logging.EVENTBUS)
+	return c
+}
+
+// Publish sends event to the broker with the trace of ctx, if any, as a W3C
+// traceparent. Events missing from the registry stay local to this process.
+func (c *Conn) Publish(ctx context.Context, event eventbus.Event) error {
+	if _, registered := c.registry.Name(event); !registered {
+		return nil
+	}
+	envelope, err := c.registry.Encode(event)
+	if err != nil {
+		return err
+	}
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	data, err := json.Marshal(message{Envelope: envelope, Traceparent: carrier.Get("traceparent")})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+// Claim asks the broker to send each event of these types to only one of
+// the clients claiming it. Events missing from the registry stay local and
+// need no claim.
+func (c *Conn) Claim(events ...eventbus.Event) error {
+	names := []string{}
+	for _, event := range events {
+		if name, registered := c.registry.Name(event); registered {
+			names = append(names, name)
+		}
+	}
+	data, err := json.Marshal(claim{Claim: names})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.conn.Write(append(data, '\n'))
+	return err
+}
+
+type claim struct {
+	Claim []string `json:"claim"`
+}
+
+// message is a line of the protocol: an event and the trace it belongs to.
+type message struct {
+	eventcodec.Envelope
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// Receive delivers the events of the other clients until the connection is
+// closed, with a context carrying the remote trace they were published in.
+// Lines it cannot decode, e.g. events from a newer process, are logged and
+// skipped.
+func (c *Conn) Receive(deliver func(context.Context, eventbus.Event)) error {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var received message
+		if err := json.Unmarshal(scanner.Bytes(), &received); err != nil {
+			c.logger.Warn("undecodable remote event dropped", "error", err)
+			continue
+		}
+		event, err := c.registry.Decode(received.Envelope)
+		if err != nil {
+			c.logger.Warn("undecodable remote event dropped", "error", err)
+			continue
+		}
+		carrier := propagation.MapCarrier{"traceparent": received.Traceparent}
+		deliver(propagation.TraceContext{}.Extract(context.Background(), carrier), event)
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) Close() error {
+	err := c.conn.Close()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
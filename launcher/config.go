@@ -0,0 +1,583 @@
+package launcher
+
+import (
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
+	"agentlauncher/internal/providers"
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DEFAULT_PROVIDER_NAME = "openai"
+	DEFAULT_MODEL_NAME    = "default"
+)
+
+// Config describes a whole launcher, see FromConfig. JSON files are read as
+// the YAML they are a subset of:
+//
+//	providers:
+//	  openai:
+//	    api_key: ${OPENAI_API_KEY}
+//	  local:
+//	    base_url: http://localhost:11434/v1
+//	models:
+//	  smart: {provider: openai, model: gpt-4.1}
+//	  fast: {provider: local, model: qwen3, temperature: 0.2}
+//	main_agent: smart
+//	sub_agent: fast
+//	routes:
+//	  - {name: long-context, model: smart, when: {tokens_above: 50000}}
+//	prompts:
+//	  researcher: {file: prompts/researcher.md}
+//	profiles:
+//	  - {name: researcher, description: Finds sources, model: fast, prompt: researcher, tools: [fetch]}
+//	tools:
+//	  - name: fetch
+//	    description: Fetch a web page
+//	    http: {method: GET, url: "https://r.jina.ai/{url}"}
+//	    parameters: [{name: url, type: string, required: true}]
+//	  - name: grep
+//	    command: [sh, -c, "jq -r .pattern | xargs grep -rn"]
+//	    parameters: [{name: pattern, type: string, required: true}]
+//	    require_approval: true
+//	mcp_servers:
+//	  - {name: files, command: [npx, -y, "@modelcontextprotocol/server-filesystem", "."]}
+//	limits: {tool_exec: 8, tools: {grep: 2}, sub_agents: {per_primary_agent: 3, global: 10}}
+//	retry: {max_retries: 3, backoff: 1s, max_backoff: 30s}
+//	user_timeout: 5m
+//	logging: {format: json, level: info, levels: {llm: debug}}
+//
+// String values may reference environment variables as ${NAME}, or
+// ${NAME:-default} when unset variables should fall back to a default; $$ is
+// a literal $. Relative paths are resolved against the config file's
+// directory.
+type Config struct {
+	Providers    map[string]ProviderConfig `yaml:"providers"`
+	Models       map[string]ModelConfig    `yaml:"models"`
+	MainAgent    string                    `yaml:"main_agent"`
+	SubAgent     string                    `yaml:"sub_agent"`
+	Routes       []RouteConfig             `yaml:"routes"`
+	SystemPrompt string                    `yaml:"system_prompt"`
+	// Prompt names one of Prompts as the primary agents' system prompt.
+	Prompt       string                  `yaml:"prompt"`
+	Prompts      map[string]PromptConfig `yaml:"prompts"`
+	Profiles     []ProfileConfig         `yaml:"profiles"`
+	Tools        []ToolConfig            `yaml:"tools"`
+	MCPServers   []MCPServerConfig       `yaml:"mcp_servers"`
+	AskUser      bool                    `yaml:"ask_user"`
+	UserTimeout  time.Duration           `yaml:"user_timeout"`
+	SubAgentTool *bool                   `yaml:"sub_agent_tool"`
+	Limits       LimitsConfig            `yaml:"limits"`
+	Retry        *RetryConfig            `yaml:"retry"`
+	Logging      *LoggingConfig          `yaml:"logging"`
+
+	dir string
+}
+
+// ProviderConfig is an OpenAI-compatible API. Empty fields fall back to the
+// OPENAI_BASE_URL and OPENAI_API_KEY environment variables.
+type ProviderConfig struct {
+	Type    string `yaml:"type"`
+	BaseURL string `yaml:"base_url"`
+	APIKey  string `yaml:"api_key"`
+}
+
+type ModelConfig struct {
+	Provider    string   `yaml:"provider"`
+	Model       string   `yaml:"model"`
+	Temperature *float64 `yaml:"temperature"`
+}
+
+// RouteConfig is a runtimes.LLMRoute to Model, taken when all conditions of
+// When hold.
+type RouteConfig struct {
+	Name    string        `yaml:"name"`
+	Model   string        `yaml:"model"`
+	Timeout time.Duration `yaml:"timeout"`
+	When    struct {
+		TokensAbove  int    `yaml:"tokens_above"`
+		TokensAtMost int    `yaml:"tokens_at_most"`
+		DepthAtLeast int    `yaml:"depth_at_least"`
+		Tools        *bool  `yaml:"tools"`
+		Profile      string `yaml:"profile"`
+	} `yaml:"when"`
+}
+
+// PromptConfig is a prompt given inline or read from a file.
+type PromptConfig struct {
+	Text string `yaml:"text"`
+	File string `yaml:"file"`
+}
+
+type ProfileConfig struct {
+	Name                   string   `yaml:"name"`
+	Description            string   `yaml:"description"`
+	Model                  string   `yaml:"model"`
+	SystemPrompt           string   `yaml:"system_prompt"`
+	Prompt                 string   `yaml:"prompt"`
+	Tools                  []string `yaml:"tools"`
+	MaxTurns               int      `yaml:"max_turns"`
+	MaxConcurrentToolCalls int      `yaml:"max_concurrent_tool_calls"`
+}
+
+// ToolConfig is a tool run either as a command or as an HTTP request.
+//
+// A command gets the call's arguments as a JSON object on stdin and answers
+// with its stdout. An HTTP tool substitutes {name} placeholders in its URL
+// with arguments and sends the others as the query of GET and DELETE
+// requests, or as a JSON body otherwise. It answers with the response body.
+type ToolConfig struct {
+	Name            string                         `yaml:"name"`
+	Description     string                         `yaml:"description"`
+	Parameters      []llminterface.ToolParamSchema `yaml:"parameters"`
+	Command         []string                       `yaml:"command"`
+	Env             map[string]string              `yaml:"env"`
+	HTTP            *HTTPToolConfig                `yaml:"http"`
+	Timeout         time.Duration                  `yaml:"timeout"`
+	RequireApproval bool                           `yaml:"require_approval"`
+}
+
+type HTTPToolConfig struct {
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// MCPServerConfig runs a Model Context Protocol server over stdio and offers
+// its tools, all of them unless Tools lists some. Prefix is prepended to
+// their names.
+type MCPServerConfig struct {
+	Name            string            `yaml:"name"`
+	Command         []string          `yaml:"command"`
+	Env             map[string]string `yaml:"env"`
+	Tools           []string          `yaml:"tools"`
+	Prefix          string            `yaml:"prefix"`
+	Timeout         time.Duration     `yaml:"timeout"`
+	RequireApproval bool              `yaml:"require_approval"`
+}
+
+type LimitsConfig struct {
+	ToolExec  int            `yaml:"tool_exec"`
+	Tools     map[string]int `yaml:"tools"`
+	SubAgents struct {
+		PerPrimaryAgent int `yaml:"per_primary_agent"`
+		Global          int `yaml:"global"`
+	} `yaml:"sub_agents"`
+}
+
+type RetryConfig struct {
+	// MaxRetries defaults to runtimes.DEFAULT_LLM_MAX_RETRIES.
+	MaxRetries *int          `yaml:"max_retries"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+type LoggingConfig struct {
+	Format string            `yaml:"format"`
+	Level  string            `yaml:"level"`
+	Levels map[string]string `yaml:"levels"`
+}
+
+// LoadConfig reads and validates a YAML or JSON config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(data, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig parses and validates a config, resolving relative paths
+// against dir. Empty data gives the default config: the OpenAI API
+// configured by the environment, with DEFAULT_OPENAI_MODEL for every agent.
+func ParseConfig(data []byte, dir string) (*Config, error) {
+	config := &Config{dir: dir}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) > 0 {
+		root := document.Content[0]
+		if err := interpolate(root, ""); err != nil {
+			return nil, err
+		}
+		if err := checkFields(root, reflect.TypeOf(config).Elem(), ""); err != nil {
+			return nil, err
+		}
+		if err := root.Decode(config); err != nil {
+			return nil, err
+		}
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) setDefaults() {
+	if len(c.Providers) == 0 {
+		c.Providers = map[string]ProviderConfig{DEFAULT_PROVIDER_NAME: {}}
+	}
+	if len(c.Models) == 0 {
+		c.Models = map[string]ModelConfig{DEFAULT_MODEL_NAME: {}}
+	}
+	if len(c.Providers) == 1 {
+		for name := range c.Providers {
+			for modelName, model := range c.Models {
+				if model.Provider == "" {
+					model.Provider = name
+					c.Models[modelName] = model
+				}
+			}
+		}
+	}
+	if c.MainAgent == "" && len(c.Models) == 1 {
+		for name := range c.Models {
+			c.MainAgent = name
+		}
+	}
+	if c.SubAgent == "" {
+		c.SubAgent = c.MainAgent
+	}
+}
+
+// Validate reports every problem of the config at once.
+func (c *Config) Validate() error {
+	errs := []error{}
+	fail := func(path, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+	}
+	model := func(path, name string) {
+		if _, exists := c.Models[name]; !exists {
+			fail(path, "unknown model %q", name)
+		}
+	}
+	prompt := func(path, name, text string) {
+		if name != "" && text != "" {
+			fail(path, "set either system_prompt or prompt")
+		}
+		if _, exists := c.Prompts[name]; name != "" && !exists {
+			fail(path+".prompt", "unknown prompt %q", name)
+		}
+	}
+
+	for name, provider := range c.Providers {
+		if provider.Type != "" && provider.Type != "openai" {
+			fail("providers."+name+".type", "unsupported provider type %q, only openai is supported", provider.Type)
+		}
+	}
+	for name, m := range c.Models {
+		if _, exists := c.Providers[m.Provider]; !exists {
+			fail("models."+name+".provider", "unknown provider %q", m.Provider)
+		}
+	}
+	if c.MainAgent == "" {
+		fail("main_agent", "required when several models are configured")
+	} else {
+		model("main_agent", c.MainAgent)
+		model("sub_agent", c.SubAgent)
+	}
+	for i, route := range c.Routes {
+		path := fmt.Sprintf("routes[%d]", i)
+		if route.Name == "" {
+			fail(path+".name", "required")
+		}
+		model(path+".model", route.Model)
+	}
+
+	prompt("", c.Prompt, c.SystemPrompt)
+	for name, p := range c.Prompts {
+		if (p.Text == "") == (p.File == "") {
+			fail("prompts."+name, "set either text or file")
+		}
+	}
+
+	profiles := make(map[string]bool)
+	for i, profile := range c.Profiles {
+		path := fmt.Sprintf("profiles[%d]", i)
+		switch {
+		case profile.Name == "":
+			fail(path+".name", "required")
+		case profiles[profile.Name]:
+			fail(path+".name", "duplicate profile %q", profile.Name)
+		}
+		profiles[profile.Name] = true
+		if profile.Model != "" {
+			model(path+".model", profile.Model)
+		}
+		prompt(path, profile.Prompt, profile.SystemPrompt)
+	}
+
+	tools := make(map[string]bool)
+	for i, tool := range c.Tools {
+		path := fmt.Sprintf("tools[%d]", i)
+		switch {
+		case tool.Name == "":
+			fail(path+".name", "required")
+		case tools[tool.Name]:
+			fail(path+".name", "duplicate tool %q", tool.Name)
+		}
+		tools[tool.Name] = true
+		if (len(tool.Command) == 0) == (tool.HTTP == nil) {
+			fail(path, "set either command or http")
+		}
+		if tool.HTTP != nil {
+			if tool.HTTP.URL == "" {
+				fail(path+".http.url", "required")
+			}
+			if method := strings.ToUpper(tool.HTTP.Method); method != "" && !slices.Contains(httpMethods, method) {
+				fail(path+".http.method", "unsupported method %q", tool.HTTP.Method)
+			}
+		}
+		for j, param := range tool.Parameters {
+			if param.Name == "" || param.Type == "" {
+				fail(fmt.Sprintf("%s.parameters[%d]", path, j), "name and type are required")
+			}
+		}
+	}
+	servers := make(map[string]bool)
+	for i, server := range c.MCPServers {
+		path := fmt.Sprintf("mcp_servers[%d]", i)
+		switch {
+		case server.Name == "":
+			fail(path+".name", "required")
+		case servers[server.Name]:
+			fail(path+".name", "duplicate server %q", server.Name)
+		}
+		servers[server.Name] = true
+		if len(server.Command) == 0 {
+			fail(path+".command", "required")
+		}
+	}
+
+	if c.UserTimeout < 0 {
+		fail("user_timeout", "must not be negative")
+	}
+	if c.Limits.ToolExec < 0 || c.Limits.SubAgents.PerPrimaryAgent < 0 || c.Limits.SubAgents.Global < 0 {
+		fail("limits", "limits must not be negative")
+	}
+	for name, limit := range c.Limits.Tools {
+		if limit < 0 {
+			fail("limits.tools."+name, "must not be negative")
+		}
+	}
+	if c.Retry != nil && ((c.Retry.MaxRetries != nil && *c.Retry.MaxRetries < 0) || c.Retry.Backoff < 0 || c.Retry.MaxBackoff < 0) {
+		fail("retry", "values must not be negative")
+	}
+	if c.Logging != nil {
+		if format := logging.Format(c.Logging.Format); format != "" && format != logging.TEXT && format != logging.JSON {
+			fail("logging.format", "unknown format %q, use text or json", c.Logging.Format)
+		}
+		if _, err := logging.ParseLevel(cmp.Or(c.Logging.Level, "info")); err != nil {
+			fail("logging.level", "unknown level %q", c.Logging.Level)
+		}
+		for subsystem, level := range c.Logging.Levels {
+			if !slices.Contains(logSubsystems, subsystem) {
+				fail("logging.levels."+subsystem, "unknown subsystem, use one of %s", strings.Join(logSubsystems, ", "))
+			}
+			if _, err := logging.ParseLevel(level); err != nil {
+				fail("logging.levels."+subsystem, "unknown level %q", level)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var httpMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+var logSubsystems = []string{logging.EVENTBUS, logging.LAUNCHER, logging.AGENT, logging.LLM, logging.TOOL, logging.MESSAGE}
+
+// handler returns the LLM handler of a model.
+func (c *Config) handler(name string) llminterface.LLMHandler {
+	model := c.Models[name]
+	provider := c.Providers[model.Provider]
+	return providers.NewOpenAI(providers.OpenAIConfig{
+		BaseURL:     provider.BaseURL,
+		APIKey:      provider.APIKey,
+		Model:       model.Model,
+		Temperature: model.Temperature,
+	})
+}
+
+// prompt returns the system prompt set inline or by name, or "".
+func (c *Config) prompt(name, text string) (string, error) {
+	if name == "" {
+		return text, nil
+	}
+	p := c.Prompts[name]
+	if p.File == "" {
+		return p.Text, nil
+	}
+	data, err := os.ReadFile(c.path(p.File))
+	if err != nil {
+		return "", fmt.Errorf("prompts.%s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+func (c *Config) path(path string) string {
+	if filepath.IsAbs(path) || c.dir == "" {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
+
+// Logger returns the configured logger, or nil without a logging section.
+func (c *Config) Logger() *slog.Logger {
+	if c.Logging == nil {
+		return nil
+	}
+	config := logging.Config{Format: logging.Format(cmp.Or(c.Logging.Format, string(logging.TEXT)))}
+	config.Level, _ = logging.ParseLevel(cmp.Or(c.Logging.Level, "info"))
+	if len(c.Logging.Levels) > 0 {
+		config.Levels = make(map[string]slog.Level)
+		for subsystem, level := range c.Logging.Levels {
+			config.Levels[subsystem], _ = logging.ParseLevel(level)
+		}
+	}
+	return logging.New(config)
+}
+
+// interpolate expands environment variables in the string values below
+// node. Plain scalars and values that are a single reference are re-typed
+// once expanded, so ${PORT} may fill an int even when quoted in JSON.
+func interpolate(node *yaml.Node, path string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := interpolate(node.Content[i+1], joinPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if err := interpolate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		reference := strings.HasPrefix(node.Value, "${") && strings.IndexByte(node.Value, '}') == len(node.Value)-1
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", node.Line, path, err)
+		}
+		node.Value = value
+		if node.Style == 0 || reference {
+			node.Tag, node.Style = "", 0
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${NAME} and ${NAME:-default}, failing on unset
+// variables without a default.
+func expandEnv(value string) (string, error) {
+	var result strings.Builder
+	for {
+		index := strings.IndexByte(value, '$')
+		if index < 0 || index == len(value)-1 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+		result.WriteString(value[:index])
+		value = value[index:]
+		switch value[1] {
+		case '$':
+			result.WriteByte('$')
+			value = value[2:]
+		case '{':
+			end := strings.IndexByte(value, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", value)
+			}
+			name, fallback, hasDefault := strings.Cut(value[2:end], ":-")
+			resolved, set := os.LookupEnv(name)
+			switch {
+			case set && resolved != "":
+				result.WriteString(resolved)
+			case hasDefault:
+				result.WriteString(fallback)
+			case set:
+			default:
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			value = value[end+1:]
+		default:
+			result.WriteByte('$')
+			value = value[1:]
+		}
+	}
+}
+
+// checkFields rejects keys that no field of t takes, which yaml would
+// silently drop.
+func checkFields(node *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := make(map[string]reflect.Type)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" {
+				// Types shared with the JSON API use their json names.
+				name = strings.Split(field.Tag.Get("json"), ",")[0]
+			}
+			if field.IsExported() && name != "-" {
+				fields[name] = field.Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			fieldType, exists := fields[key.Value]
+			if !exists {
+				return fmt.Errorf("line %d: unknown field %q in %s", key.Line, key.Value, cmp.Or(path, "config"))
+			}
+			if err := checkFields(node.Content[i+1], fieldType, joinPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := checkFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value)); err != nil {
+				return err
+			}
+		}
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for i, item := range node.Content {
+			if err := checkFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
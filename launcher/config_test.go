@@ -0,0 +1,573 @@
+package launcher_test
+
+import (
+	"agentlauncher/internal/mcp/mcptest"
+	"agentlauncher/launcher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var mcpServer = mcptest.Server{
+	Tools: []map[string]any{
+		{"name": "echo", "description": "Echo the text", "inputSchema": map[string]any{
+			"properties": map[string]any{"text": map[string]any{"type": "string"}},
+			"required":   []any{"text"},
+		}},
+		{"name": "shout"},
+	},
+	Call: func(name string, arguments map[string]any) (*mcptest.Result, error) {
+		if name != "echo" {
+			return nil, errors.New("unknown tool " + name)
+		}
+		return &mcptest.Result{Text: "echo: " + fmt.Sprint(arguments["text"])}, nil
+	},
+}
+
+func TestMain(m *testing.M) {
+	if os.Getenv(mcptest.SERVE_ENV) != "" {
+		mcpServer.Serve(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{"empty", ``, nil},
+		{"single model", `
+models:
+  default: {model: gpt-4.1-mini}
+`, nil},
+		{"json", `{"providers": {"local": {"base_url": "http://localhost:11434/v1"}}, "models": {"fast": {"model": "qwen3"}}}`, nil},
+		{"full", `
+providers:
+  openai: {api_key: key}
+  local: {base_url: "http://localhost:11434/v1"}
+models:
+  smart: {provider: openai, model: gpt-4.1}
+  fast: {provider: local, model: qwen3, temperature: 0.2}
+main_agent: smart
+sub_agent: fast
+routes:
+  - {name: long-context, model: smart, timeout: 2m, when: {tokens_above: 30000}}
+prompts:
+  researcher: {text: Find sources.}
+profiles:
+  - {name: researcher, model: fast, prompt: researcher, tools: [fetch]}
+tools:
+  - {name: fetch, http: {method: get, url: "https://example.com/{url}"}, parameters: [{name: url, type: string}]}
+  - {name: grep, command: [grep, -rn], require_approval: true}
+mcp_servers:
+  - {name: files, command: [mcp-files]}
+limits: {tool_exec: 8, tools: {fetch: 2}, sub_agents: {per_primary_agent: 3, global: 10}}
+retry: {max_retries: 3, backoff: 1s, max_backoff: 30s}
+user_timeout: 5m
+logging: {format: json, level: debug, levels: {llm: trace}}
+`, nil},
+
+		{"unknown field", `
+models:
+  default: {model: gpt-4.1, temprature: 0.2}
+`, []string{`line 3: unknown field "temprature" in models.default`}},
+		{"unsupported provider", `
+providers:
+  claude: {type: anthropic}
+`, []string{`providers.claude.type: unsupported provider type "anthropic"`}},
+		{"several models without main agent", `
+models:
+  smart: {model: gpt-4.1}
+  fast: {model: gpt-4.1-mini}
+`, []string{"main_agent: required when several models are configured"}},
+		{"unknown models", `
+providers:
+  openai: {}
+  local: {}
+models:
+  smart: {provider: openai}
+  fast: {provider: locl}
+main_agent: smart
+sub_agent: fst
+routes:
+  - {model: big}
+profiles:
+  - {name: researcher, model: tiny}
+`, []string{
+			`models.fast.provider: unknown provider "locl"`,
+			`sub_agent: unknown model "fst"`,
+			"routes[0].name: required",
+			`routes[0].model: unknown model "big"`,
+			`profiles[0].model: unknown model "tiny"`,
+		}},
+		{"prompts", `
+system_prompt: Be helpful.
+prompt: helper
+prompts:
+  empty: {}
+  both: {text: Hi, file: hi.md}
+profiles:
+  - {name: writer, prompt: writer}
+`, []string{
+			"set either system_prompt or prompt",
+			`.prompt: unknown prompt "helper"`,
+			"prompts.empty: set either text or file",
+			"prompts.both: set either text or file",
+			`profiles[0].prompt: unknown prompt "writer"`,
+		}},
+		{"profiles", `
+profiles:
+  - {description: Nameless}
+  - {name: researcher}
+  - {name: researcher}
+`, []string{"profiles[0].name: required", `profiles[2].name: duplicate profile "researcher"`}},
+		{"tools", `
+tools:
+  - {name: both, command: [true], http: {url: "https://example.com"}}
+  - {name: neither}
+  - {name: both, http: {method: TRACE}, parameters: [{name: url}]}
+`, []string{
+			"tools[0]: set either command or http",
+			"tools[1]: set either command or http",
+			`tools[2].name: duplicate tool "both"`,
+			"tools[2].http.url: required",
+			`tools[2].http.method: unsupported method "TRACE"`,
+			"tools[2].parameters[0]: name and type are required",
+		}},
+		{"mcp servers", `
+mcp_servers:
+  - {name: files}
+  - {name: files, command: [mcp-files]}
+`, []string{"mcp_servers[0].command: required", `mcp_servers[1].name: duplicate server "files"`}},
+		{"negative values", `
+user_timeout: -1s
+limits: {tool_exec: -1, tools: {fetch: -2}}
+retry: {backoff: -1s}
+`, []string{
+			"user_timeout: must not be negative",
+			"limits: limits must not be negative",
+			"limits.tools.fetch: must not be negative",
+			"retry: values must not be negative",
+		}},
+		{"logging", `
+logging: {format: xml, level: loud, levels: {llm: quiet, network: debug}}
+`, []string{
+			`logging.format: unknown format "xml"`,
+			`logging.level: unknown level "loud"`,
+			`logging.levels.llm: unknown level "quiet"`,
+			"logging.levels.network: unknown subsystem",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := launcher.ParseConfig([]byte(test.config), "")
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Errorf("expected the config to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected the config to be rejected")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected the error to contain %q, got:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "key")
+	config, err := launcher.LoadConfig("../examples/agentlauncher.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MainAgent != "smart" || config.Providers["local"].BaseURL != "http://localhost:11434/v1" {
+		t.Errorf("unexpected config %+v", config)
+	}
+}
+
+func TestConfigInterpolation(t *testing.T) {
+	t.Setenv("CONFIG_TEST_NAME", "world")
+	t.Setenv("CONFIG_TEST_EMPTY", "")
+	t.Setenv("CONFIG_TEST_LIMIT", "4")
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		err   string
+	}{
+		{"variable", "${CONFIG_TEST_NAME}", "world", ""},
+		{"within text", "hello ${CONFIG_TEST_NAME}!", "hello world!", ""},
+		{"default of unset", "${CONFIG_TEST_UNSET:-nobody}", "nobody", ""},
+		{"default of empty", "${CONFIG_TEST_EMPTY:-nobody}", "nobody", ""},
+		{"default of set", "${CONFIG_TEST_NAME:-nobody}", "world", ""},
+		{"empty default", "[${CONFIG_TEST_UNSET:-}]", "[]", ""},
+		{"empty", "[${CONFIG_TEST_EMPTY}]", "[]", ""},
+		{"escaped", "costs $$5", "costs $5", ""},
+		{"lone dollar", "costs $5 or 5$", "costs $5 or 5$", ""},
+		{"unset", "${CONFIG_TEST_UNSET}", "", "line 2: system_prompt: environment variable CONFIG_TEST_UNSET is not set"},
+		{"unterminated", "${CONFIG_TEST_NAME", "", "unterminated variable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := launcher.ParseConfig(fmt.Appendf(nil, "\nsystem_prompt: %q\n", test.value), "")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.SystemPrompt != test.want {
+				t.Errorf("expected %q, got %q", test.want, config.SystemPrompt)
+			}
+		})
+	}
+
+	t.Run("typed", func(t *testing.T) {
+		config, err := launcher.ParseConfig([]byte(`{"limits": {"tool_exec": "${CONFIG_TEST_LIMIT}"}, "user_timeout": "${CONFIG_TEST_TIMEOUT:-90s}"}`), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Limits.ToolExec != 4 || config.UserTimeout != 90*time.Second {
+			t.Errorf("expected the values to be re-typed, got %d and %s", config.Limits.ToolExec, config.UserTimeout)
+		}
+	})
+	t.Run("quoted text", func(t *testing.T) {
+		config, err := launcher.ParseConfig([]byte(`system_prompt: "${CONFIG_TEST_LIMIT}0 ${CONFIG_TEST_NAME}s"`), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.SystemPrompt != "40 worlds" {
+			t.Errorf("expected %q, got %q", "40 worlds", config.SystemPrompt)
+		}
+	})
+}
+
+// chatRequest is what the launcher sends to fakeOpenAI.
+type chatRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Tools []struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	} `json:"tools"`
+}
+
+func (r chatRequest) content(role string) []string {
+	contents := []string{}
+	for _, message := range r.Messages {
+		var text string
+		if message.Role == role && json.Unmarshal(message.Content, &text) == nil {
+			contents = append(contents, text)
+		}
+	}
+	return contents
+}
+
+func (r chatRequest) toolNames() []string {
+	names := []string{}
+	for _, tool := range r.Tools {
+		names = append(names, tool.Function.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// chatReply is either text or a tool call.
+type chatReply struct {
+	Content   string
+	ToolName  string
+	Arguments map[string]any
+}
+
+// fakeOpenAI serves the Chat Completions API, answering each request with
+// reply. It returns the server's base URL and the requests it received.
+func fakeOpenAI(t *testing.T, reply func(chatRequest) chatReply) (string, func() []chatRequest) {
+	t.Helper()
+	var requests []chatRequest
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+
+		answer := reply(request)
+		message := map[string]any{"role": "assistant", "content": answer.Content}
+		if answer.ToolName != "" {
+			arguments, _ := json.Marshal(answer.Arguments)
+			message["tool_calls"] = []map[string]any{{
+				"id":       fmt.Sprintf("call_%d", len(requests)),
+				"type":     "function",
+				"function": map[string]any{"name": answer.ToolName, "arguments": string(arguments)},
+			}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "chatcmpl-test",
+			"object":  "chat.completion",
+			"model":   request.Model,
+			"choices": []map[string]any{{"index": 0, "message": message, "finish_reason": "stop"}},
+			"usage":   map[string]any{"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/v1", func() []chatRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(requests)
+	}
+}
+
+// fromConfig builds a launcher from config, given as a format string whose
+// %s is the base URL of a fake OpenAI server answering with reply.
+func fromConfig(t *testing.T, config string, reply func(chatRequest) chatReply) (*launcher.AgentLauncher, func() []chatRequest) {
+	t.Helper()
+	baseURL, requests := fakeOpenAI(t, reply)
+	parsed, err := launcher.ParseConfig(fmt.Appendf(nil, config, baseURL), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	al, err := launcher.NewFromConfig(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(al.Close)
+	return al, requests
+}
+
+// callOnce makes the primary agent call a tool, then answer with the tool's
+// result.
+func callOnce(toolName string, arguments map[string]any) func(chatRequest) chatReply {
+	return func(r chatRequest) chatReply {
+		if results := r.content("tool"); len(results) > 0 {
+			return chatReply{Content: results[0]}
+		}
+		return chatReply{ToolName: toolName, Arguments: arguments}
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	al, requests := fromConfig(t, `
+providers:
+  fake: {base_url: "%s", api_key: test}
+models:
+  main: {model: main-model}
+  small: {model: small-model}
+main_agent: main
+profiles:
+  - {name: researcher, description: Looks things up, model: small, system_prompt: You research., tools: [fetch]}
+tools:
+  - {name: fetch, http: {url: "https://example.com"}}
+  - {name: grep, command: [grep]}
+`, func(r chatRequest) chatReply {
+		if r.Model == "small-model" {
+			return chatReply{Content: "found it"}
+		}
+		return callOnce("create_sub_agent", map[string]any{"task": "look it up", "profile": "researcher"})(r)
+	})
+
+	if result := strings.TrimSpace(al.Run("find it", nil)); result != "found it" {
+		t.Errorf("expected the sub-agent's answer, got %q", result)
+	}
+	var sub chatRequest
+	for _, request := range requests() {
+		if request.Model == "small-model" {
+			sub = request
+		}
+	}
+	if system := strings.Join(sub.content("system"), "\n"); !strings.Contains(system, "You research.") {
+		t.Errorf("expected the profile's system prompt, got %q", system)
+	}
+	if tools := sub.toolNames(); !slices.Equal(tools, []string{"fetch"}) {
+		t.Errorf("expected only the profile's tools, got %v", tools)
+	}
+}
+
+func TestConfigProfilePromptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/researcher.md", []byte("Quote your sources."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config := `
+prompts:
+  researcher: {file: researcher.md}
+  missing: {file: missing.md}
+profiles:
+  - {name: researcher, prompt: researcher}
+`
+	parsed, err := launcher.ParseConfig([]byte(config), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	al, err := launcher.NewFromConfig(parsed)
+	if err != nil {
+		t.Fatalf("expected the prompt file to be read relative to the config, got %v", err)
+	}
+	al.Close()
+
+	parsed, err = launcher.ParseConfig([]byte(config+"  - {name: lost, prompt: missing}\n"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := launcher.NewFromConfig(parsed); err == nil || !strings.Contains(err.Error(), "prompts.missing") {
+		t.Errorf("expected the missing prompt file to be reported, got %v", err)
+	}
+}
+
+func TestConfigRoutes(t *testing.T) {
+	al, requests := fromConfig(t, `
+providers:
+  fake: {base_url: "%s", api_key: test}
+models:
+  main: {model: main-model}
+  big: {model: big-model}
+  small: {model: small-model}
+main_agent: main
+routes:
+  - {name: huge, model: small, when: {tokens_above: 1000000}}
+  - {name: top-level, model: big, when: {tools: true}}
+`, func(r chatRequest) chatReply {
+		return chatReply{Content: "answered by " + r.Model}
+	})
+
+	if result := strings.TrimSpace(al.Run("hello", nil)); result != "answered by big-model" {
+		t.Errorf("expected the first matching route to answer, got %q", result)
+	}
+	if got := requests(); len(got) != 1 {
+		t.Errorf("expected a single request, got %d", len(got))
+	}
+}
+
+func TestConfigHTTPTools(t *testing.T) {
+	t.Setenv("CONFIG_TEST_TOKEN", "secret")
+	var received []string
+	var mu sync.Mutex
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("Authorization"), body))
+		mu.Unlock()
+		if r.URL.Path == "/missing" {
+			http.Error(w, "no such page", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "page content")
+	}))
+	defer api.Close()
+
+	tests := []struct {
+		name      string
+		http      string
+		arguments map[string]any
+		request   string
+		result    string
+	}{
+		{"get", `{url: "API/pages/{id}", headers: {Authorization: "Bearer ${CONFIG_TEST_TOKEN}"}}`,
+			map[string]any{"id": "a b", "q": "cats"},
+			"GET /pages/a%20b?q=cats Bearer secret ", "page content"},
+		{"post", `{method: post, url: "API/pages"}`,
+			map[string]any{"title": "Cats"},
+			`POST /pages  {"title":"Cats"}`, "page content"},
+		{"error status", `{url: "API/missing"}`,
+			nil,
+			"GET /missing  ", "404 Not Found: no such page"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+			al, _ := fromConfig(t, `
+providers:
+  fake: {base_url: "%s", api_key: test}
+tools:
+  - {name: fetch, http: `+strings.ReplaceAll(test.http, "API", api.URL)+`}
+`, callOnce("fetch", test.arguments))
+
+			if result := strings.TrimSpace(al.Run("fetch it", nil)); !strings.Contains(result, test.result) {
+				t.Errorf("expected the tool result to contain %q, got %q", test.result, result)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(received) != 1 || received[0] != test.request {
+				t.Errorf("expected the request %q, got %q", test.request, received)
+			}
+		})
+	}
+}
+
+func TestConfigCommandTool(t *testing.T) {
+	al, _ := fromConfig(t, `
+providers:
+  fake: {base_url: "%s", api_key: test}
+tools:
+  - name: greet
+    command: [sh, -c, 'echo "$GREETING"; cat']
+    env: {GREETING: hello}
+`, callOnce("greet", map[string]any{"name": "cat"}))
+
+	if result := strings.TrimSpace(al.Run("greet", nil)); result != "hello\n{\"name\":\"cat\"}" {
+		t.Errorf("expected the command's output, got %q", result)
+	}
+}
+
+func TestConfigMCPServer(t *testing.T) {
+	command, _ := json.Marshal(mcptest.Command())
+	al, requests := fromConfig(t, `
+providers:
+  fake: {base_url: "%s", api_key: test}
+mcp_servers:
+  - name: echo
+    command: `+string(command)+`
+    env: {`+mcptest.SERVE_ENV+`: "1"}
+    tools: [echo]
+    prefix: mcp_
+`, callOnce("mcp_echo", map[string]any{"text": "hi"}))
+
+	if result := strings.TrimSpace(al.Run("echo hi", nil)); result != "echo: hi" {
+		t.Errorf("expected the MCP tool's result, got %q", result)
+	}
+	if tools := requests()[0].toolNames(); !slices.Equal(tools, []string{"create_sub_agent", "mcp_echo"}) {
+		t.Errorf("expected only the selected MCP tool, got %v", tools)
+	}
+
+	config, err := launcher.ParseConfig(fmt.Appendf(nil, `
+mcp_servers:
+  - {name: echo, command: %s, env: {%s: "1"}, tools: [whisper]}
+`, command, mcptest.SERVE_ENV), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := launcher.NewFromConfig(config); err == nil || !strings.Contains(err.Error(), `mcp_servers echo: server has no tool "whisper"`) {
+		t.Errorf("expected the unknown MCP tool to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,293 @@
+package launcher
+
+import (
+	"agentlauncher/internal/mcp"
+	"agentlauncher/internal/runtimes"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_CONFIG_TOOL_TIMEOUT = time.Minute
+	MAX_HTTP_TOOL_RESPONSE      = 1 << 20
+	mcpStartTimeout             = 30 * time.Second
+)
+
+// FromConfig builds a launcher from a YAML or JSON config file, see Config.
+// Close stops the MCP servers it started.
+func FromConfig(path string) (*AgentLauncher, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(config)
+}
+
+func NewFromConfig(config *Config) (*AgentLauncher, error) {
+	al := NewAgentLauncher(config.handler(config.MainAgent), config.handler(config.SubAgent))
+	if logger := config.Logger(); logger != nil {
+		al.WithLogger(logger)
+	}
+	if err := al.configure(config); err != nil {
+		al.Close()
+		return nil, err
+	}
+	return al, nil
+}
+
+func (al *AgentLauncher) configure(config *Config) error {
+	prompt, err := config.prompt(config.Prompt, config.SystemPrompt)
+	if err != nil {
+		return err
+	}
+	if prompt != "" {
+		al.WithSystemPrompt(prompt)
+	}
+
+	for _, route := range config.Routes {
+		al.WithLLMRoutes(runtimes.LLMRoute{
+			Name:    route.Name,
+			Handler: config.handler(route.Model),
+			Timeout: route.Timeout,
+			Match:   route.match(),
+		})
+	}
+
+	for _, tool := range config.Tools {
+		fn := tool.command
+		if tool.HTTP != nil {
+			fn = tool.http
+		}
+		al.WithTool(tool.Name, tool.Description, runtimes.RawToolFunction(fn), tool.Parameters)
+		if tool.RequireApproval {
+			al.WithToolApproval(tool.Name)
+		}
+	}
+	for _, server := range config.MCPServers {
+		if err := al.startMCPServer(server); err != nil {
+			return fmt.Errorf("mcp_servers %s: %w", server.Name, err)
+		}
+	}
+	if config.AskUser {
+		al.EnableAskUserTool()
+	}
+	if config.UserTimeout > 0 {
+		al.WithUserTimeout(config.UserTimeout)
+	}
+	if config.SubAgentTool != nil && !*config.SubAgentTool {
+		al.DisableSubAgentTool()
+	}
+
+	for _, profile := range config.Profiles {
+		prompt, err := config.prompt(profile.Prompt, profile.SystemPrompt)
+		if err != nil {
+			return err
+		}
+		agentProfile := runtimes.AgentProfile{
+			Name:                   profile.Name,
+			Description:            profile.Description,
+			SystemPrompt:           prompt,
+			ToolNames:              profile.Tools,
+			MaxTurns:               profile.MaxTurns,
+			MaxConcurrentToolCalls: profile.MaxConcurrentToolCalls,
+		}
+		if profile.Model != "" {
+			agentProfile.LLMHandler = config.handler(profile.Model)
+		}
+		if err := al.AddAgentProfile(agentProfile); err != nil {
+			return fmt.Errorf("profiles %s: %w", profile.Name, err)
+		}
+	}
+
+	limits := config.Limits
+	if limits.ToolExec > 0 {
+		al.WithToolExecLimit(limits.ToolExec)
+	}
+	for name, limit := range limits.Tools {
+		al.WithToolLimit(name, limit)
+	}
+	if limits.SubAgents.PerPrimaryAgent > 0 || limits.SubAgents.Global > 0 {
+		al.WithSubAgentLimit(
+			cmp.Or(limits.SubAgents.PerPrimaryAgent, runtimes.DEFAULT_SUB_AGENTS_PER_PRIMARY_AGENT),
+			limits.SubAgents.Global,
+		)
+	}
+	if config.Retry != nil {
+		policy := runtimes.DefaultRetryPolicy()
+		if config.Retry.MaxRetries != nil {
+			policy.MaxRetries = *config.Retry.MaxRetries
+		}
+		policy.Backoff, policy.MaxBackoff = config.Retry.Backoff, config.Retry.MaxBackoff
+		al.WithRetryPolicy(policy)
+	}
+	return nil
+}
+
+func (route RouteConfig) match() func(runtimes.LLMRouteRequest) bool {
+	matchers := []func(runtimes.LLMRouteRequest) bool{}
+	when := route.When
+	if when.TokensAbove > 0 {
+		matchers = append(matchers, runtimes.RouteWhenTokensAbove(when.TokensAbove))
+	}
+	if when.TokensAtMost > 0 {
+		matchers = append(matchers, runtimes.RouteWhenTokensAtMost(when.TokensAtMost))
+	}
+	if when.DepthAtLeast > 0 {
+		matchers = append(matchers, runtimes.RouteWhenDepthAtLeast(when.DepthAtLeast))
+	}
+	if when.Tools != nil {
+		matchers = append(matchers, runtimes.RouteWhenTools(*when.Tools))
+	}
+	if when.Profile != "" {
+		matchers = append(matchers, runtimes.RouteWhenProfile(when.Profile))
+	}
+	return runtimes.RouteWhenAll(matchers...)
+}
+
+func (tool ToolConfig) timeout() time.Duration {
+	if tool.Timeout > 0 {
+		return tool.Timeout
+	}
+	return DEFAULT_CONFIG_TOOL_TIMEOUT
+}
+
+func (tool ToolConfig) command(ctx context.Context, arguments map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tool.timeout())
+	defer cancel()
+
+	input, err := json.Marshal(arguments)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tool.Command[0], tool.Command[1:]...)
+	cmd.Env = os.Environ()
+	for name, value := range tool.Env {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%w: %s", err, message)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+func (tool ToolConfig) http(ctx context.Context, arguments map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tool.timeout())
+	defer cancel()
+
+	method := strings.ToUpper(cmp.Or(tool.HTTP.Method, http.MethodGet))
+	target := tool.HTTP.URL
+	rest := make(map[string]any)
+	for name, value := range arguments {
+		placeholder := "{" + name + "}"
+		if strings.Contains(target, placeholder) {
+			target = strings.ReplaceAll(target, placeholder, url.PathEscape(fmt.Sprint(value)))
+		} else {
+			rest[name] = value
+		}
+	}
+
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		parsed, err := url.Parse(target)
+		if err != nil {
+			return "", err
+		}
+		query := parsed.Query()
+		for name, value := range rest {
+			query.Set(name, fmt.Sprint(value))
+		}
+		parsed.RawQuery = query.Encode()
+		target = parsed.String()
+	} else {
+		data, err := json.Marshal(rest)
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range tool.HTTP.Headers {
+		request.Header.Set(name, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(response.Body, MAX_HTTP_TOOL_RESPONSE))
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode >= 400 {
+		return "", fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(data)))
+	}
+	return string(data), nil
+}
+
+// startMCPServer registers the tools of an MCP server, which runs until the
+// launcher is closed.
+func (al *AgentLauncher) startMCPServer(server MCPServerConfig) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	defer cancel()
+	client, err := mcp.Start(ctx, server.Command, server.Env, nil)
+	if err != nil {
+		return err
+	}
+	al.closers = append(al.closers, client.Close)
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return err
+	}
+
+	available := make(map[string]bool)
+	for _, tool := range tools {
+		available[tool.Name] = true
+	}
+	for _, name := range server.Tools {
+		if !available[name] {
+			return fmt.Errorf("server has no tool %q", name)
+		}
+	}
+	timeout := ToolConfig{Timeout: server.Timeout}.timeout()
+	for _, tool := range tools {
+		if len(server.Tools) > 0 && !slices.Contains(server.Tools, tool.Name) {
+			continue
+		}
+		name := tool.Name
+		call := func(ctx context.Context, arguments map[string]any) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return client.CallTool(ctx, name, arguments)
+		}
+		al.WithTool(server.Prefix+name, tool.Description, runtimes.RawToolFunction(call), tool.Parameters())
+		if server.RequireApproval {
+			al.WithToolApproval(server.Prefix + name)
+		}
+	}
+	return nil
+}
@@ -2,12 +2,23 @@ package launcher
 
 import (
 	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventlog"
 	"agentlauncher/internal/events"
 	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
+	"agentlauncher/internal/metrics"
+	"agentlauncher/internal/report"
 	"agentlauncher/internal/runtimes"
+	"agentlauncher/internal/tracing"
+	"cmp"
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AgentLauncher struct {
@@ -17,35 +28,51 @@ type AgentLauncher struct {
 	llmRuntime     *runtimes.LLMRuntime
 	toolRuntime    *runtimes.ToolRuntime
 	messageRuntime *runtimes.MessageRuntime
-	finalResults   map[string]chan string
 	primaryAgents  map[string]bool
-	mu             sync.RWMutex
-	subAgentTool   bool
+	eventLog       *eventlog.Recorder
+	tracer         *tracing.Tracer
+	report         *report.Builder
+	logger         *slog.Logger
+	// closers stop what the launcher started for its tools.
+	closers      []func() error
+	mu           sync.RWMutex
+	subAgentTool bool
 }
 
-func NewAgentLauncher(mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler) *AgentLauncher {
-	eb := eventbus.NewEventBus()
+func NewAgentLauncher(mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler, busOptions ...eventbus.Option) *AgentLauncher {
+	eb := eventbus.NewEventBus(busOptions...)
+	eb.WithOrderedDelivery(nil)
 	al := &AgentLauncher{
 		eventBus:       eb,
 		agentRuntime:   runtimes.NewAgentRuntime(eb),
 		llmRuntime:     runtimes.NewLLMRuntime(eb, mainAgentHandler, subAgentHandler),
 		toolRuntime:    runtimes.NewToolRuntime(eb),
 		messageRuntime: runtimes.NewMessageRuntime(eb),
-		systemPrompt:   runtimes.PRIMARY_AGENT_SYSTEM_PROMPT,
-		finalResults:   make(map[string]chan string),
 		primaryAgents:  make(map[string]bool),
+		logger:         logging.Discard(),
 	}
 
-	eventbus.Subscribe(eb, al.HandleTaskFinishEvent)
-
 	return al
 }
 
-func (al *AgentLauncher) WithVerboseLevel(level eventbus.VerboseLevel) *AgentLauncher {
-	al.eventBus.WithVerboseLevel(level)
+// WithLogger sends the logs of the launcher, the event bus and every runtime
+// to logger, see logging.New for output formats and per-subsystem levels.
+func (al *AgentLauncher) WithLogger(logger *slog.Logger) *AgentLauncher {
+	al.logger = logging.Subsystem(logger, logging.LAUNCHER)
+	al.eventBus.WithLogger(logger)
+	al.agentRuntime.WithLogger(logger)
+	al.messageRuntime.WithLogger(logger)
+	if al.llmRuntime != nil {
+		al.llmRuntime.WithLogger(logger)
+	}
+	if al.toolRuntime != nil {
+		al.toolRuntime.WithLogger(logger)
+	}
 	return al
 }
 
+// WithSystemPrompt replaces the system prompt of primary agents, which by
+// default is runtimes.PrimaryAgentSystemPrompt for the sub-agent limit.
 func (al *AgentLauncher) WithSystemPrompt(prompt string) *AgentLauncher {
 	al.systemPrompt = prompt
 	return al
@@ -62,67 +89,311 @@ func (al *AgentLauncher) WithConversationHandler(handler func(llminterface.Messa
 }
 
 func (al *AgentLauncher) WithTool(name, description string, fn any, params []llminterface.ToolParamSchema) *AgentLauncher {
+	al.requireLocalRuntimes("WithTool")
 	al.toolRuntime.Register(name, description, fn, params)
 	return al
 }
 
+func (al *AgentLauncher) WithToolExecLimit(limit int) *AgentLauncher {
+	al.requireLocalRuntimes("WithToolExecLimit")
+	al.toolRuntime.WithToolExecLimit(limit)
+	return al
+}
+
+func (al *AgentLauncher) WithToolLimit(name string, limit int) *AgentLauncher {
+	al.requireLocalRuntimes("WithToolLimit")
+	al.toolRuntime.WithToolLimit(name, limit)
+	return al
+}
+
+// WithSubAgentLimit bounds the sub-agents running at once in the tree of each
+// primary agent and in all of them. A sub-agent waiting for its own
+// sub-agents gives its slot back meanwhile.
+func (al *AgentLauncher) WithSubAgentLimit(perPrimaryAgent, global int) *AgentLauncher {
+	al.requireLocalRuntimes("WithSubAgentLimit")
+	al.toolRuntime.WithSubAgentLimit(perPrimaryAgent, global)
+	return al
+}
+
+// WithAgentProfile is AddAgentProfile for chaining, it panics if the profile
+// is rejected.
+func (al *AgentLauncher) WithAgentProfile(profile runtimes.AgentProfile) *AgentLauncher {
+	if err := al.AddAgentProfile(profile); err != nil {
+		panic(err)
+	}
+	return al
+}
+
+// AddAgentProfile adds a profile sub-agents can be created from. It fails
+// if the name is taken or a tool of the profile is not registered yet.
+func (al *AgentLauncher) AddAgentProfile(profile runtimes.AgentProfile) error {
+	al.requireLocalRuntimes("AddAgentProfile")
+	if err := al.toolRuntime.RegisterProfile(profile); err != nil {
+		return err
+	}
+	if profile.LLMHandler != nil {
+		al.llmRuntime.RegisterProfileHandler(profile.Name, profile.LLMHandler)
+	}
+	return nil
+}
+
+func (al *AgentLauncher) WithLLMRoutes(routes ...runtimes.LLMRoute) *AgentLauncher {
+	al.requireLocalRuntimes("WithLLMRoutes")
+	al.llmRuntime.WithRoutes(routes...)
+	return al
+}
+
+// WithRetryPolicy sets how failed LLM requests are retried, by default
+// runtimes.DEFAULT_LLM_MAX_RETRIES times without waiting.
+func (al *AgentLauncher) WithRetryPolicy(policy eventbus.RetryPolicy) *AgentLauncher {
+	al.requireLocalRuntimes("WithRetryPolicy")
+	al.llmRuntime.WithRetryPolicy(policy)
+	return al
+}
+
+func (al *AgentLauncher) WithEventLog(backend eventlog.Backend) *AgentLauncher {
+	al.eventLog = eventlog.NewRecorder(al.eventBus, backend, events.NewRegistry())
+	return al
+}
+
+func (al *AgentLauncher) WithTracing(provider trace.TracerProvider) *AgentLauncher {
+	al.tracer = tracing.New(provider)
+	al.tracer.Install(al.eventBus)
+	return al
+}
+
+// WithMetrics reports runtime and event bus metrics to m, e.g. a
+// metrics.Registry served over HTTP for Prometheus to scrape.
+func (al *AgentLauncher) WithMetrics(m metrics.Metrics) *AgentLauncher {
+	metrics.Install(al.eventBus, m)
+	return al
+}
+
+// WithReport keeps a run report of every task, see Report.
+func (al *AgentLauncher) WithReport() *AgentLauncher {
+	al.report = report.NewBuilder()
+	al.report.Install(al.eventBus)
+	return al
+}
+
+func (al *AgentLauncher) EventLog() *eventlog.Recorder {
+	return al.eventLog
+}
+
+// Report returns the run report so far, or nil without WithReport.
+func (al *AgentLauncher) Report() *report.Report {
+	if al.report == nil {
+		return nil
+	}
+	return al.report.Report()
+}
+
 func (al *AgentLauncher) DisableSubAgentTool() *AgentLauncher {
+	al.requireLocalRuntimes("DisableSubAgentTool")
 	al.toolRuntime.DisableSubAgentTool()
 	return al
 }
 
-func SubscribeEvent[T eventbus.Event](al *AgentLauncher, handler func(context.Context, T)) *AgentLauncher {
-	eventbus.Subscribe(al.eventBus, handler)
+// WithToolApproval holds every call to the named tools until it is answered
+// with ApproveToolCall.
+func (al *AgentLauncher) WithToolApproval(names ...string) *AgentLauncher {
+	al.requireLocalRuntimes("WithToolApproval")
+	al.toolRuntime.RequireApproval(names...)
+	return al
+}
+
+// WithUserTimeout sets how long tool approvals and ask_user questions wait
+// for an answer before the call is denied or fails.
+func (al *AgentLauncher) WithUserTimeout(timeout time.Duration) *AgentLauncher {
+	al.requireLocalRuntimes("WithUserTimeout")
+	al.toolRuntime.WithUserTimeout(timeout)
 	return al
 }
 
-func (al *AgentLauncher) HandleTaskFinishEvent(ctx context.Context, e events.TaskFinishEvent) {
-	al.mu.RLock()
-	ch, exists := al.finalResults[e.AgentID]
-	al.mu.RUnlock()
+// EnableAskUserTool gives agents the ask_user tool, answered with AnswerUser.
+func (al *AgentLauncher) EnableAskUserTool() *AgentLauncher {
+	al.requireLocalRuntimes("EnableAskUserTool")
+	al.toolRuntime.SetupAskUserTool()
+	return al
+}
 
-	if exists {
-		select {
-		case ch <- e.Result:
-		default:
-		}
-	}
+func (al *AgentLauncher) ApproveToolCall(agentID, toolCallID string, approved bool, reason string) {
+	al.eventBus.Emit(events.ToolApprovalResponseEvent{
+		AgentID:    agentID,
+		ToolCallID: toolCallID,
+		Approved:   approved,
+		Reason:     reason,
+	})
 }
 
-func (al *AgentLauncher) Run(task string, history []llminterface.Message) string {
+func (al *AgentLauncher) AnswerUser(agentID, requestID, answer string) {
+	al.eventBus.Emit(events.UserInputResponseEvent{
+		AgentID:   agentID,
+		RequestID: requestID,
+		Answer:    answer,
+	})
+}
+
+// FollowUp sends a user message to a running agent with its next LLM request.
+func (al *AgentLauncher) FollowUp(agentID, content string) {
+	al.eventBus.Emit(events.UserFollowUpEvent{
+		AgentID: agentID,
+		Content: content,
+	})
+}
+
+// Subscription is a handler subscribed to a launcher's events.
+type Subscription struct {
+	subscription *eventbus.Subscription
+}
+
+// Unsubscribe stops the handler from being called with further events.
+func (s *Subscription) Unsubscribe() {
+	s.subscription.Unsubscribe()
+}
+
+// Active reports whether the handler is still subscribed.
+func (s *Subscription) Active() bool {
+	return s.subscription.Active()
+}
+
+func SubscribeEvent[T eventbus.Event](al *AgentLauncher, handler func(context.Context, T)) *Subscription {
+	return &Subscription{eventbus.Subscribe(al.eventBus, handler)}
+}
+
+// SubscribeTaskEvent delivers events of the task's primary agent and its
+// sub-agents, and unsubscribes once the task finishes.
+func SubscribeTaskEvent[T eventbus.Event](al *AgentLauncher, taskID string, handler func(context.Context, T)) *Subscription {
+	inTask := events.ForAgentTree(taskID)
+	subscription := eventbus.SubscribeUntil(al.eventBus,
+		func(ctx context.Context, e T) {
+			if inTask(e) {
+				handler(ctx, e)
+			}
+		},
+		func(e events.TaskFinishEvent) bool {
+			return e.AgentID == taskID
+		})
+	return &Subscription{subscription}
+}
+
+func SubscribeAllEvents(al *AgentLauncher, handler func(context.Context, eventbus.Event)) *Subscription {
+	return &Subscription{eventbus.SubscribeAll(al.eventBus, handler)}
+}
+
+// WatchEvents calls watch with every event as the bus dispatches it, in
+// emission order. Unlike subscribers, watchers do not wait for the agent's
+// earlier events to be handled, so they see the deltas streamed during an
+// LLM call as they come. watch must return quickly; it stays installed until
+// stop is called.
+func WatchEvents(al *AgentLauncher, watch func(eventbus.Event)) (stop func()) {
+	return al.eventBus.Intercept(func(ctx context.Context, event eventbus.Event) (eventbus.Event, bool) {
+		watch(event)
+		return event, true
+	})
+}
+
+func SubscribeEventsWhere(al *AgentLauncher, predicate func(eventbus.Event) bool, handler func(context.Context, eventbus.Event)) *Subscription {
+	return &Subscription{eventbus.SubscribeWhere(al.eventBus, predicate, handler)}
+}
+
+func SubscribeMatchingEvents[I any](al *AgentLauncher, handler func(context.Context, I)) *Subscription {
+	return &Subscription{eventbus.SubscribeMatching(al.eventBus, handler)}
+}
+
+func (al *AgentLauncher) NewTaskID() string {
 	al.mu.Lock()
+	defer al.mu.Unlock()
 	agentID := runtimes.GeneratePrimaryAgentID(len(al.primaryAgents))
 	al.primaryAgents[agentID] = true
-	al.toolRuntime.SetupSubAgentTool()
-	al.finalResults[agentID] = make(chan string, 1)
+	return agentID
+}
+
+func (al *AgentLauncher) Run(task string, history llminterface.MessageList) string {
+	return al.RunTask(al.NewTaskID(), task, history)
+}
+
+func (al *AgentLauncher) RunTask(agentID string, task string, history llminterface.MessageList) string {
+	return al.RunTaskContext(context.Background(), agentID, task, history)
+}
+
+// RunTaskContext runs the task until it finishes or ctx is done, in which
+// case the task's agents are cancelled.
+func (al *AgentLauncher) RunTaskContext(ctx context.Context, agentID string, task string, history llminterface.MessageList) string {
+	al.mu.Lock()
+	al.primaryAgents[agentID] = true
 	al.mu.Unlock()
 
-	tool_names := al.toolRuntime.GetToolNames()
-	al.eventBus.Emit(events.TaskCreateEvent{
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	al.logger.Info("task started", "agent_id", agentID)
+	defer func() {
+		al.logger.Info("task finished", "agent_id", agentID, "duration", time.Since(start))
+	}()
+
+	tools, err := al.tools(ctx, agentID)
+	if err != nil {
+		al.logger.Error("task failed", "agent_id", agentID, "error", err)
+		return "Error: " + err.Error()
+	}
+	finish, err := eventbus.Request[events.TaskCreateEvent, events.TaskFinishEvent](ctx, al.eventBus, events.TaskCreateEvent{
 		AgentID:      agentID,
 		Task:         task,
 		Conversation: history,
-		SystemPrompt: al.systemPrompt,
-		ToolSchemas:  al.toolRuntime.GetToolSchemas(tool_names),
+		SystemPrompt: cmp.Or(al.systemPrompt, runtimes.PrimaryAgentSystemPrompt(tools.SubAgentsAtOnce)),
+		ToolSchemas:  tools.ToolSchemas,
+		RequestID:    runtimes.NewRequestID(),
 	})
-	select {
-	case result := <-al.finalResults[agentID]:
-		al.mu.Lock()
-		close(al.finalResults[agentID])
-		delete(al.finalResults, agentID)
-		al.mu.Unlock()
-		return result
-	case <-time.After(30 * time.Minute):
+	if errors.Is(err, context.DeadlineExceeded) {
+		al.logger.Error("task timed out", "agent_id", agentID)
+		al.Cancel(agentID, "Task timed out")
 		return "Task timed out"
 	}
+	if errors.Is(err, context.Canceled) {
+		al.logger.Info("task cancelled", "agent_id", agentID)
+		al.Cancel(agentID, "Task cancelled")
+		return "Task cancelled"
+	}
+	if err != nil {
+		al.logger.Error("task failed", "agent_id", agentID, "error", err)
+		return "Error: " + err.Error()
+	}
+	return finish.Result
 }
 
-func (al *AgentLauncher) Close() {
-	al.mu.Lock()
-	defer al.mu.Unlock()
+// Cancel stops the task's primary agent and its sub-agents. A RunTask waiting
+// on the task returns "Error: " followed by reason.
+func (al *AgentLauncher) Cancel(taskID string, reason string) {
+	al.eventBus.Emit(events.TaskCancelEvent{
+		AgentID: taskID,
+		Reason:  reason,
+	})
+}
 
-	for _, ch := range al.finalResults {
-		close(ch)
+// TaskError reports whether a RunTask result is an error rather than an
+// answer, and returns its message.
+func TaskError(result string) (string, bool) {
+	result = strings.TrimSpace(result)
+	switch {
+	case strings.HasPrefix(result, "Error: "):
+		return strings.TrimPrefix(result, "Error: "), true
+	case result == "Task timed out" || result == "Task cancelled":
+		return result, true
 	}
+	return "", false
+}
+
+func (al *AgentLauncher) Close() {
 	al.eventBus.Shutdown(context.Background())
+	if al.eventLog != nil {
+		al.eventLog.Close()
+	}
+	if al.tracer != nil {
+		al.tracer.Close()
+	}
+	for _, close := range al.closers {
+		close()
+	}
 }
@@ -0,0 +1,224 @@
+package launcher_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNestedSubAgentsWithinLimit(t *testing.T) {
+	leaves := llmtest.CreateSubAgent("leaf 1")
+	for i := 2; i <= 5; i++ {
+		leaves = leaves.With(llmtest.CreateSubAgent(fmt.Sprintf("leaf %d", i)).Response...)
+	}
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("level one"), llmtest.Text("top done")).
+		ForTask("level one", leaves, llmtest.Text("one done"))
+	for i := 1; i <= 5; i++ {
+		llm.ForTask(fmt.Sprintf("leaf %d", i), llmtest.Text("leaf done").After(20*time.Millisecond))
+	}
+
+	// Count the sub-agents calling the LLM at once.
+	var running, peak atomic.Int32
+	subAgentHandler := func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for current := peak.Load(); n > current && !peak.CompareAndSwap(current, n); current = peak.Load() {
+		}
+		return llm.Handler()(messages, tools, agentID, eb)
+	}
+	al := launcher.NewAgentLauncher(llm.Handler(), subAgentHandler).WithSubAgentLimit(1, 1)
+	defer al.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if result := strings.TrimSpace(al.RunTaskContext(ctx, "agent0", "nest", nil)); result != "top done" {
+		t.Fatalf("expected top done, got %q", result)
+	}
+	llm.AssertSubAgentCount(t, 6)
+	llm.AssertExhausted(t)
+	if peak.Load() != 1 {
+		t.Errorf("expected one sub-agent at a time, got %d at once", peak.Load())
+	}
+}
+
+func TestSystemPromptFollowsSubAgentLimit(t *testing.T) {
+	llm := llmtest.New().ForAgent("agent0", llmtest.Text("done"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).WithSubAgentLimit(5, 0)
+	defer al.Close()
+
+	al.Run("count", nil)
+	if prompt := llm.RequestsFor("agent0")[0].SystemPrompt(); !strings.Contains(prompt, "up to 5 sub-agents at once") {
+		t.Errorf("expected the prompt to allow 5 sub-agents, got %q", prompt)
+	}
+}
+
+func TestTimedOutRouteIsSilenced(t *testing.T) {
+	slow := func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: "early"})
+		time.Sleep(200 * time.Millisecond)
+		eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: "late"})
+		return llminterface.ResponseMessageList{llminterface.AssistantMessage{Content: "slow"}}
+	}
+	llm := llmtest.New().ForAgent("agent0", llmtest.Text("fast"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithLLMRoutes(runtimes.LLMRoute{Name: "slow", Handler: slow, Timeout: 50 * time.Millisecond})
+	defer al.Close()
+
+	var deltas []string
+	var mu sync.Mutex
+	launcher.WatchEvents(al, func(event eventbus.Event) {
+		if delta, ok := event.(events.MessageDeltaStreamingEvent); ok {
+			mu.Lock()
+			deltas = append(deltas, delta.Delta)
+			mu.Unlock()
+		}
+	})
+
+	if result := strings.TrimSpace(al.Run("hurry", nil)); result != "fast" {
+		t.Fatalf("expected the fallback answer, got %q", result)
+	}
+	time.Sleep(300 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(deltas, []string{"early"}) {
+		t.Errorf("expected only the delta streamed before the timeout, got %v", deltas)
+	}
+}
+
+func TestCancelFinishedTask(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.Text("done")).
+		ForAgent("agent1", llmtest.Text("still working"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	defer al.Close()
+
+	var finished atomic.Int32
+	launcher.SubscribeEvent(al, func(ctx context.Context, e events.TaskFinishEvent) {
+		finished.Add(1)
+	})
+
+	if result := strings.TrimSpace(al.RunTask("agent0", "first", nil)); result != "done" {
+		t.Fatalf("expected done, got %q", result)
+	}
+	al.Cancel("agent0", "too late")
+	time.Sleep(100 * time.Millisecond)
+
+	if got := finished.Load(); got != 1 {
+		t.Errorf("expected 1 TaskFinishEvent, got %d", got)
+	}
+	if result := strings.TrimSpace(al.RunTask("agent1", "second", nil)); result != "still working" {
+		t.Errorf("expected still working, got %q", result)
+	}
+	llm.AssertExhausted(t)
+}
+
+func TestCancelDuringLLMCall(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: "thinking"})
+		close(started)
+		<-release
+		return llminterface.ResponseMessageList{llminterface.AssistantMessage{Content: "too late"}}
+	}
+	al := launcher.NewAgentLauncher(slow, slow)
+	defer al.Close()
+	defer close(release)
+
+	deltas := make(chan string, 10)
+	launcher.SubscribeEvent(al, func(ctx context.Context, e events.MessageDeltaStreamingEvent) {
+		deltas <- e.Delta
+	})
+	finished := make(chan string, 1)
+	launcher.SubscribeEvent(al, func(ctx context.Context, e events.TaskFinishEvent) {
+		finished <- e.Result
+	})
+
+	go al.RunTask("agent0", "think hard", nil)
+	<-started
+	select {
+	case <-deltas:
+	case <-time.After(time.Second):
+		t.Fatal("the delta was held back by the LLM call")
+	}
+	al.Cancel("agent0", "stop")
+	select {
+	case result := <-finished:
+		if result != "Error: stop" {
+			t.Errorf("expected the task to be cancelled, got %q", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the cancellation was held back by the LLM call")
+	}
+}
+
+func TestUnansweredUserIsTimedOut(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0",
+			llmtest.ToolCall("delete", map[string]any{}).With(llminterface.ToolCallMessage{
+				ToolName:  runtimes.ASK_USER_TOOL_NAME,
+				Arguments: map[string]any{"question": "sure?"},
+			}),
+			llmtest.Text("gave up"))
+	var ran atomic.Bool
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTool("delete", "Delete everything", func(ctx context.Context) (string, error) {
+			ran.Store(true)
+			return "deleted", nil
+		}, nil).
+		WithToolApproval("delete").
+		EnableAskUserTool().
+		WithUserTimeout(50 * time.Millisecond)
+	defer al.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if result := strings.TrimSpace(al.RunTaskContext(ctx, "agent0", "clean up", nil)); result != "gave up" {
+		t.Fatalf("expected gave up, got %q", result)
+	}
+	if ran.Load() {
+		t.Error("expected the unapproved tool not to run")
+	}
+	llm.AssertToolResult(t, "agent0", "delete", "no approval within")
+	llm.AssertToolResult(t, "agent0", runtimes.ASK_USER_TOOL_NAME, "did not answer within")
+}
+
+func TestApprovalEndsWithTask(t *testing.T) {
+	llm := llmtest.New().ForAgent("agent0", llmtest.ToolCall("delete", map[string]any{}))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTool("delete", "Delete everything", func(ctx context.Context) (string, error) {
+			return "deleted", nil
+		}, nil).
+		WithToolApproval("delete")
+	defer al.Close()
+
+	denied := make(chan events.ToolExecErrorEvent, 1)
+	launcher.SubscribeEvent(al, func(ctx context.Context, e events.ToolExecErrorEvent) {
+		denied <- e
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if result := al.RunTaskContext(ctx, "agent0", "clean up", nil); result != "Task timed out" {
+		t.Fatalf("expected the task to time out, got %q", result)
+	}
+	select {
+	case e := <-denied:
+		if !strings.Contains(e.Error, "context canceled") {
+			t.Errorf("expected the approval to be cancelled, got %q", e.Error)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the approval kept waiting after the task timed out")
+	}
+}
@@ -0,0 +1,101 @@
+package launcher_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"context"
+	"strings"
+	"testing"
+)
+
+func search(ctx context.Context, query string) (string, error) {
+	return "results for " + query, nil
+}
+
+var queryParams = []llminterface.ToolParamSchema{{Name: "query", Type: "string", Required: true}}
+
+func TestProfiledSubAgent(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateProfiledSubAgent("find cats", "researcher"), llmtest.Text("top done")).
+		ForTask("find cats", llmtest.Text("found"))
+	var profileCalls int
+	profileHandler := func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		profileCalls++
+		return llm.Handler()(messages, tools, agentID, eb)
+	}
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTool("search", "Search the web", search, queryParams).
+		WithTool("write", "Write a file", search, queryParams).
+		WithAgentProfile(runtimes.AgentProfile{
+			Name:         "researcher",
+			Description:  "Looks things up",
+			SystemPrompt: "You research things.",
+			ToolNames:    []string{"search"},
+			LLMHandler:   profileHandler,
+		})
+	defer al.Close()
+
+	if result := strings.TrimSpace(al.Run("cats", nil)); result != "top done" {
+		t.Fatalf("expected top done, got %q", result)
+	}
+	llm.AssertExhausted(t)
+	llm.AssertToolOffered(t, "agent0", runtimes.CREATE_SUB_AGENT_TOOL_NAME)
+
+	subAgents := llm.SubAgentIDs()
+	if len(subAgents) != 1 {
+		t.Fatalf("expected one sub-agent, got %v", subAgents)
+	}
+	request := llm.RequestsFor(subAgents[0])[0]
+	if request.SystemPrompt() != "You research things." {
+		t.Errorf("expected the profile's system prompt, got %q", request.SystemPrompt())
+	}
+	if !request.HasTool("search") || request.HasTool("write") {
+		t.Errorf("expected only the profile's tools, got %v", request.Tools)
+	}
+	if profileCalls != 1 {
+		t.Errorf("expected the profile's handler to be called once, got %d", profileCalls)
+	}
+}
+
+func TestAddAgentProfileRejects(t *testing.T) {
+	llm := llmtest.New()
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTool("search", "Search the web", search, queryParams)
+	defer al.Close()
+
+	if err := al.AddAgentProfile(runtimes.AgentProfile{Name: "researcher", ToolNames: []string{"search", runtimes.CREATE_SUB_AGENT_TOOL_NAME}}); err != nil {
+		t.Fatalf("expected the profile to be added, got %v", err)
+	}
+	tests := []struct {
+		name    string
+		profile runtimes.AgentProfile
+		want    string
+	}{
+		{"duplicate", runtimes.AgentProfile{Name: "researcher"}, "already registered"},
+		{"unknown tools", runtimes.AgentProfile{Name: "writer", ToolNames: []string{"search", "write", "serch"}}, "unknown tools: write, serch"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := al.AddAgentProfile(test.profile)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("expected an error containing %q, got %v", test.want, err)
+			}
+		})
+	}
+}
+
+func TestWithAgentProfilePanicsOnUnknownTool(t *testing.T) {
+	llm := llmtest.New()
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	defer al.Close()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected WithAgentProfile to panic")
+		}
+	}()
+	al.WithAgentProfile(runtimes.AgentProfile{Name: "researcher", ToolNames: []string{"search"}})
+}
@@ -0,0 +1,237 @@
+package launcher
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/logging"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/internal/tracing"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewRemoteAgentLauncher runs agents and their conversations in this process
+// and leaves LLM calls and tool execution to a Worker sharing the same
+// transport, which must be passed with eventbus.WithTransport. Tools, limits,
+// profiles and routes are configured on the Worker.
+func NewRemoteAgentLauncher(busOptions ...eventbus.Option) *AgentLauncher {
+	eb := eventbus.NewEventBus(busOptions...)
+	eb.WithOrderedDelivery(nil)
+	return &AgentLauncher{
+		eventBus:       eb,
+		agentRuntime:   runtimes.NewAgentRuntime(eb),
+		messageRuntime: runtimes.NewMessageRuntime(eb),
+		primaryAgents:  make(map[string]bool),
+		logger:         logging.Discard(),
+	}
+}
+
+func (al *AgentLauncher) requireLocalRuntimes(method string) {
+	if al.toolRuntime == nil {
+		panic(method + " is not available on a remote launcher, configure the Worker instead")
+	}
+}
+
+// ToolSchemas returns the schemas of the tools agents can call, asking a
+// worker for them on a remote launcher.
+func (al *AgentLauncher) ToolSchemas(ctx context.Context) ([]llminterface.ToolSchema, error) {
+	tools, err := al.tools(ctx, "tools")
+	return tools.ToolSchemas, err
+}
+
+// tools returns the tools of agentID and how many sub-agents it may run at
+// once, asking a worker for them on a remote launcher.
+func (al *AgentLauncher) tools(ctx context.Context, agentID string) (events.ToolSchemasResponseEvent, error) {
+	if al.toolRuntime != nil {
+		al.mu.Lock()
+		al.toolRuntime.SetupSubAgentTool()
+		al.mu.Unlock()
+		return events.ToolSchemasResponseEvent{
+			AgentID:         agentID,
+			ToolSchemas:     al.toolRuntime.GetToolSchemas(al.toolRuntime.GetToolNames()),
+			SubAgentsAtOnce: al.toolRuntime.SubAgentsAtOnce(),
+		}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	response, err := eventbus.Request[events.ToolSchemasRequestEvent, events.ToolSchemasResponseEvent](ctx, al.eventBus, events.ToolSchemasRequestEvent{
+		AgentID: agentID,
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return response, errors.New("no worker answered the tool schema request")
+	}
+	return response, err
+}
+
+// Worker hosts the LLM and tool runtimes for a remote AgentLauncher. Workers
+// claim the requests their runtimes answer, so with several workers on one
+// broker each request is handled by only one of them. Tool and sub-agent
+// limits apply per worker.
+type Worker struct {
+	eventBus    *eventbus.EventBus
+	llmRuntime  *runtimes.LLMRuntime
+	toolRuntime *runtimes.ToolRuntime
+	tracer      *tracing.Tracer
+}
+
+// NewWorker runs both the LLM and the tool runtime, see NewLLMWorker and
+// NewToolWorker to scale them separately.
+func NewWorker(mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler, busOptions ...eventbus.Option) *Worker {
+	w := newWorker(busOptions)
+	w.startLLMRuntime(mainAgentHandler, subAgentHandler)
+	w.startToolRuntime()
+	return w
+}
+
+// NewLLMWorker only calls LLMs, tools are left to a tool worker.
+func NewLLMWorker(mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler, busOptions ...eventbus.Option) *Worker {
+	w := newWorker(busOptions)
+	w.startLLMRuntime(mainAgentHandler, subAgentHandler)
+	return w
+}
+
+// NewToolWorker only runs tools and sub-agent slots, LLM calls are left to
+// an LLM worker.
+func NewToolWorker(busOptions ...eventbus.Option) *Worker {
+	w := newWorker(busOptions)
+	w.startToolRuntime()
+	return w
+}
+
+func newWorker(busOptions []eventbus.Option) *Worker {
+	eb := eventbus.NewEventBus(busOptions...)
+	eb.WithOrderedDelivery(nil)
+	return &Worker{eventBus: eb}
+}
+
+func (w *Worker) startLLMRuntime(mainAgentHandler llminterface.LLMHandler, subAgentHandler llminterface.LLMHandler) {
+	w.llmRuntime = runtimes.NewLLMRuntime(w.eventBus, mainAgentHandler, subAgentHandler)
+	// A failed claim means a broken connection, which also stops the
+	// transport from receiving, and the bus logs that.
+	_ = w.eventBus.Claim(events.LLMRequestEvent{}, events.LLMRuntimeErrorEvent{})
+}
+
+func (w *Worker) startToolRuntime() {
+	w.toolRuntime = runtimes.NewToolRuntime(w.eventBus)
+	_ = w.eventBus.Claim(events.ToolsExecRequestEvent{}, events.ToolRuntimeErrorEvent{}, events.ToolSchemasRequestEvent{})
+}
+
+func (w *Worker) requireLLMRuntime(method string) {
+	if w.llmRuntime == nil {
+		panic(method + " is not available on a tool worker")
+	}
+}
+
+func (w *Worker) requireToolRuntime(method string) {
+	if w.toolRuntime == nil {
+		panic(method + " is not available on an LLM worker")
+	}
+}
+
+func (w *Worker) WithLogger(logger *slog.Logger) *Worker {
+	w.eventBus.WithLogger(logger)
+	if w.llmRuntime != nil {
+		w.llmRuntime.WithLogger(logger)
+	}
+	if w.toolRuntime != nil {
+		w.toolRuntime.WithLogger(logger)
+	}
+	return w
+}
+
+// WithTracing records the spans of this worker, e.g. its tool calls, in the
+// traces of the launcher that sent the requests.
+func (w *Worker) WithTracing(provider trace.TracerProvider) *Worker {
+	w.tracer = tracing.New(provider)
+	w.tracer.Install(w.eventBus)
+	return w
+}
+
+func (w *Worker) WithTool(name, description string, fn any, params []llminterface.ToolParamSchema) *Worker {
+	w.requireToolRuntime("WithTool")
+	w.toolRuntime.Register(name, description, fn, params)
+	return w
+}
+
+func (w *Worker) WithToolExecLimit(limit int) *Worker {
+	w.requireToolRuntime("WithToolExecLimit")
+	w.toolRuntime.WithToolExecLimit(limit)
+	return w
+}
+
+func (w *Worker) WithToolLimit(name string, limit int) *Worker {
+	w.requireToolRuntime("WithToolLimit")
+	w.toolRuntime.WithToolLimit(name, limit)
+	return w
+}
+
+func (w *Worker) WithSubAgentLimit(perPrimaryAgent, global int) *Worker {
+	w.requireToolRuntime("WithSubAgentLimit")
+	w.toolRuntime.WithSubAgentLimit(perPrimaryAgent, global)
+	return w
+}
+
+// WithAgentProfile adds a profile sub-agents can be created from, it panics
+// if the profile is rejected, see AgentLauncher.AddAgentProfile. The tool
+// worker needs the profile to create sub-agents from it, the LLM worker only
+// its LLMHandler, if any.
+func (w *Worker) WithAgentProfile(profile runtimes.AgentProfile) *Worker {
+	if w.toolRuntime != nil {
+		if err := w.toolRuntime.RegisterProfile(profile); err != nil {
+			panic(err)
+		}
+	}
+	if w.llmRuntime != nil && profile.LLMHandler != nil {
+		w.llmRuntime.RegisterProfileHandler(profile.Name, profile.LLMHandler)
+	}
+	return w
+}
+
+func (w *Worker) WithLLMRoutes(routes ...runtimes.LLMRoute) *Worker {
+	w.requireLLMRuntime("WithLLMRoutes")
+	w.llmRuntime.WithRoutes(routes...)
+	return w
+}
+
+func (w *Worker) WithRetryPolicy(policy eventbus.RetryPolicy) *Worker {
+	w.requireLLMRuntime("WithRetryPolicy")
+	w.llmRuntime.WithRetryPolicy(policy)
+	return w
+}
+
+func (w *Worker) DisableSubAgentTool() *Worker {
+	w.requireToolRuntime("DisableSubAgentTool")
+	w.toolRuntime.DisableSubAgentTool()
+	return w
+}
+
+func (w *Worker) WithToolApproval(names ...string) *Worker {
+	w.requireToolRuntime("WithToolApproval")
+	w.toolRuntime.RequireApproval(names...)
+	return w
+}
+
+func (w *Worker) WithUserTimeout(timeout time.Duration) *Worker {
+	w.requireToolRuntime("WithUserTimeout")
+	w.toolRuntime.WithUserTimeout(timeout)
+	return w
+}
+
+func (w *Worker) EnableAskUserTool() *Worker {
+	w.requireToolRuntime("EnableAskUserTool")
+	w.toolRuntime.SetupAskUserTool()
+	return w
+}
+
+func (w *Worker) Close() {
+	w.eventBus.Shutdown(context.Background())
+	if w.tracer != nil {
+		w.tracer.Close()
+	}
+}
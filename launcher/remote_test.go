@@ -0,0 +1,176 @@
+package launcher_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/transport"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cluster is a broker with an observer that tells when the broker has read
+// what a connection sent so far, e.g. the claims of a worker.
+type cluster struct {
+	t        *testing.T
+	broker   *transport.Broker
+	observed chan eventbus.Event
+}
+
+func newCluster(t *testing.T) *cluster {
+	t.Helper()
+	broker := transport.NewBroker()
+	if err := broker.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	c := &cluster{t: t, broker: broker, observed: make(chan eventbus.Event, 1024)}
+	observer := c.dial()
+	go observer.Receive(func(_ context.Context, event eventbus.Event) {
+		select {
+		case c.observed <- event:
+		default:
+		}
+	})
+	return c
+}
+
+func (c *cluster) dial() *transport.Conn {
+	c.t.Helper()
+	conn, err := transport.Dial(c.broker.Addr(), events.NewRegistry())
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func (c *cluster) ready(conn *transport.Conn) {
+	c.t.Helper()
+	marker := fmt.Sprintf("ready-%p", conn)
+	timeout := time.After(5 * time.Second)
+	for {
+		conn.Publish(context.Background(), events.TaskCancelEvent{AgentID: marker})
+		select {
+		case event := <-c.observed:
+			if cancel, ok := event.(events.TaskCancelEvent); ok && cancel.AgentID == marker {
+				return
+			}
+		case <-time.After(20 * time.Millisecond):
+		case <-timeout:
+			c.t.Fatal("broker did not read the connection")
+		}
+	}
+}
+
+func countCalls(handler llminterface.LLMHandler, calls *atomic.Int32) llminterface.LLMHandler {
+	return func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		calls.Add(1)
+		return handler(messages, tools, agentID, eb)
+	}
+}
+
+func TestWorkersShareRequests(t *testing.T) {
+	c := newCluster(t)
+	llm := llmtest.New()
+	const tasks = 4
+	for i := range tasks {
+		llm.ForTask(fmt.Sprint("task ", i), llmtest.Text(fmt.Sprint("done ", i)))
+	}
+	var calls [2]atomic.Int32
+	for i := range calls {
+		conn := c.dial()
+		handler := countCalls(llm.Handler(), &calls[i])
+		worker := launcher.NewWorker(handler, handler, eventbus.WithTransport(conn))
+		t.Cleanup(worker.Close)
+		c.ready(conn)
+	}
+	al := launcher.NewRemoteAgentLauncher(eventbus.WithTransport(c.dial()))
+	defer al.Close()
+
+	var wg sync.WaitGroup
+	for i := range tasks {
+		wg.Go(func() {
+			if result := strings.TrimSpace(al.Run(fmt.Sprint("task ", i), nil)); result != fmt.Sprint("done ", i) {
+				t.Errorf("task %d: unexpected result %q", i, result)
+			}
+		})
+	}
+	wg.Wait()
+
+	llm.AssertExhausted(t)
+	if got := len(llm.Requests()); got != tasks {
+		t.Errorf("expected %d LLM calls, got %d", tasks, got)
+	}
+	if calls[0].Load() == 0 || calls[1].Load() == 0 {
+		t.Errorf("expected both workers to take requests, got %d and %d", calls[0].Load(), calls[1].Load())
+	}
+}
+
+func TestRoleSpecificWorkers(t *testing.T) {
+	c := newCluster(t)
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.ToolCall("add", map[string]any{"a": 1.0, "b": 2.0}), llmtest.Text("3"))
+
+	var llmCalls atomic.Int32
+	llmConn := c.dial()
+	llmWorker := launcher.NewLLMWorker(countCalls(llm.Handler(), &llmCalls), nil, eventbus.WithTransport(llmConn))
+	defer llmWorker.Close()
+	c.ready(llmConn)
+
+	var toolCalls atomic.Int32
+	toolConn := c.dial()
+	toolWorker := launcher.NewToolWorker(eventbus.WithTransport(toolConn)).
+		WithTool("add", "Adds two numbers", func(ctx context.Context, a, b int) (string, error) {
+			toolCalls.Add(1)
+			return fmt.Sprint(a + b), nil
+		}, []llminterface.ToolParamSchema{
+			{Name: "a", Type: "integer", Required: true},
+			{Name: "b", Type: "integer", Required: true},
+		})
+	defer toolWorker.Close()
+	c.ready(toolConn)
+
+	al := launcher.NewRemoteAgentLauncher(eventbus.WithTransport(c.dial()))
+	defer al.Close()
+
+	if result := strings.TrimSpace(al.Run("add 1 and 2", nil)); result != "3" {
+		t.Errorf("unexpected result %q", result)
+	}
+	llm.AssertExhausted(t)
+	llm.AssertToolResult(t, "agent0", "add", "3")
+	if llmCalls.Load() != 2 || toolCalls.Load() != 1 {
+		t.Errorf("expected 2 LLM calls and 1 tool call, got %d and %d", llmCalls.Load(), toolCalls.Load())
+	}
+}
+
+func TestWorkerRolePanics(t *testing.T) {
+	for name, configure := range map[string]func(){
+		"tool on an LLM worker": func() {
+			worker := launcher.NewLLMWorker(llmtest.New().Handler(), nil)
+			defer worker.Close()
+			worker.WithTool("noop", "Does nothing", func(ctx context.Context) (string, error) { return "", nil }, nil)
+		},
+		"routes on a tool worker": func() {
+			worker := launcher.NewToolWorker()
+			defer worker.Close()
+			worker.WithLLMRoutes()
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recovered := recover(); recovered == nil || !strings.Contains(fmt.Sprint(recovered), "not available") {
+					t.Errorf("expected a not available panic, got %v", recovered)
+				}
+			}()
+			configure()
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package llmtest
+
+import (
+	"strings"
+	"testing"
+)
+
+func (s *ScriptedLLM) AssertRequestCount(t testing.TB, agentID string, expected int) {
+	t.Helper()
+	if got := len(s.RequestsFor(agentID)); got != expected {
+		t.Errorf("llmtest: expected %d requests for %s, got %d", expected, agentID, got)
+	}
+}
+
+func (s *ScriptedLLM) AssertSubAgentCount(t testing.TB, expected int) {
+	t.Helper()
+	if got := len(s.SubAgentIDs()); got != expected {
+		t.Errorf("llmtest: expected %d sub-agents, got %d", expected, got)
+	}
+}
+
+func (s *ScriptedLLM) AssertToolOffered(t testing.TB, agentID, toolName string) {
+	t.Helper()
+	requests := s.RequestsFor(agentID)
+	if len(requests) == 0 {
+		t.Errorf("llmtest: no requests received for %s", agentID)
+		return
+	}
+	if !requests[0].HasTool(toolName) {
+		t.Errorf("llmtest: tool %s was not offered to %s", toolName, agentID)
+	}
+}
+
+func (s *ScriptedLLM) AssertToolResult(t testing.TB, agentID, toolName, contains string) {
+	t.Helper()
+	for _, request := range s.RequestsFor(agentID) {
+		for _, result := range request.ToolResults() {
+			if result.ToolName == toolName && strings.Contains(result.Result, contains) {
+				return
+			}
+		}
+	}
+	t.Errorf("llmtest: no %s result containing %q was sent to %s", toolName, contains, agentID)
+}
+
+func (s *ScriptedLLM) AssertExhausted(t testing.TB) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for agentID, steps := range s.agentScripts {
+		if len(steps) > 0 {
+			t.Errorf("llmtest: %d scripted steps left for %s", len(steps), agentID)
+		}
+	}
+	for _, script := range s.taskScripts {
+		t.Errorf("llmtest: no agent received a task containing %q", script.contains)
+	}
+	if len(s.subAgentScripts) > 0 {
+		t.Errorf("llmtest: %d scripted sub-agents were never created", len(s.subAgentScripts))
+	}
+	for _, request := range s.unexpected {
+		t.Errorf("llmtest: unexpected request from %s", request.AgentID)
+	}
+}
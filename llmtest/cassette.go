@@ -0,0 +1,170 @@
+package llmtest
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type CassetteMode int
+
+const (
+	// RECORD calls the wrapped handler and records every interaction.
+	RECORD CassetteMode = iota
+	// REPLAY serves recorded interactions and never calls the wrapped handler.
+	REPLAY
+	// REPLAY_OR_RECORD replays known interactions and records the missing ones.
+	REPLAY_OR_RECORD
+)
+
+type Interaction struct {
+	Key      string                           `json:"key"`
+	Messages llminterface.RequestMessageList  `json:"messages"`
+	Tools    llminterface.RequestToolList     `json:"tools"`
+	Response llminterface.ResponseMessageList `json:"response"`
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Cassette struct {
+	path         string
+	mode         CassetteMode
+	interactions []Interaction
+	served       map[string]int
+	normalize    func(llminterface.Message) llminterface.Message
+	mu           sync.Mutex
+}
+
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	c := &Cassette{
+		path:   path,
+		mode:   mode,
+		served: make(map[string]int),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && mode != REPLAY {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if mode != RECORD {
+		c.interactions = file.Interactions
+	}
+	return c, nil
+}
+
+// WithNormalizer rewrites request messages before they are hashed, e.g. to
+// blank out tool results that depend on the clock.
+func (c *Cassette) WithNormalizer(normalize func(llminterface.Message) llminterface.Message) *Cassette {
+	c.normalize = normalize
+	return c
+}
+
+func (c *Cassette) Wrap(handler llminterface.LLMHandler) llminterface.LLMHandler {
+	return func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		key, normalized := c.key(messages, tools)
+
+		if c.mode != RECORD {
+			if response, found := c.replay(key); found {
+				return response
+			}
+			if c.mode == REPLAY {
+				panic(fmt.Errorf("llmtest: no recorded interaction for request %s from %s", key[:12], agentID))
+			}
+		}
+
+		response := handler(messages, tools, agentID, eb)
+		if err := c.record(Interaction{
+			Key:      key,
+			Messages: normalized,
+			Tools:    tools,
+			Response: response,
+		}); err != nil {
+			panic(err)
+		}
+		return response
+	}
+}
+
+func (c *Cassette) key(messages llminterface.RequestMessageList, tools llminterface.RequestToolList) (string, llminterface.RequestMessageList) {
+	normalized := make(llminterface.RequestMessageList, len(messages))
+	for i, msg := range messages {
+		if c.normalize != nil {
+			msg = c.normalize(msg)
+		}
+		normalized[i] = msg
+	}
+
+	// json.Marshal sorts map keys, so tool arguments hash deterministically.
+	// Tools are offered in no particular order, sort them too.
+	sortedTools := slices.SortedFunc(slices.Values(tools), func(a, b llminterface.ToolSchema) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	data, _ := json.Marshal(struct {
+		Messages llminterface.RequestMessageList `json:"messages"`
+		Tools    llminterface.RequestToolList    `json:"tools"`
+	}{normalized, sortedTools})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), normalized
+}
+
+func (c *Cassette) replay(key string) (llminterface.ResponseMessageList, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matches := []Interaction{}
+	for _, interaction := range c.interactions {
+		if interaction.Key == key {
+			matches = append(matches, interaction)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	// Identical requests are answered in recording order, then the last answer repeats.
+	index := min(c.served[key], len(matches)-1)
+	c.served[key]++
+	return append(llminterface.ResponseMessageList{}, matches[index].Response...), true
+}
+
+func (c *Cassette) record(interaction Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, interaction)
+	c.served[interaction.Key]++
+	return c.save()
+}
+
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction{}, c.interactions...)
+}
+
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return os.Rename(tmp, c.path)
+}
@@ -0,0 +1,68 @@
+package llmtest_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"path/filepath"
+	"testing"
+)
+
+func neverCalled(llminterface.RequestMessageList, llminterface.RequestToolList, string, *eventbus.EventBus) llminterface.ResponseMessageList {
+	panic("the wrapped handler was called during replay")
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("look it up"), llmtest.Text("summary")).
+		ForSubAgent(llmtest.Text("found it"))
+
+	recorder, err := llmtest.NewCassette(path, llmtest.RECORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	al := launcher.NewAgentLauncher(recorder.Wrap(llm.Handler()), recorder.Wrap(llm.Handler()))
+	recorded := al.Run("research", nil)
+	al.Close()
+	if got := len(recorder.Interactions()); got != 3 {
+		t.Fatalf("expected 3 recorded interactions, got %d", got)
+	}
+
+	player, err := llmtest.NewCassette(path, llmtest.REPLAY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	al = launcher.NewAgentLauncher(player.Wrap(neverCalled), player.Wrap(neverCalled))
+	defer al.Close()
+	if replayed := al.Run("research", nil); replayed != recorded {
+		t.Errorf("expected replayed result %q, got %q", recorded, replayed)
+	}
+}
+
+func TestCassetteIgnoresToolOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	messages := llminterface.RequestMessageList{llminterface.UserMessage{Content: "hello"}}
+	search := llminterface.ToolSchema{Name: "search"}
+	fetch := llminterface.ToolSchema{Name: "fetch"}
+
+	recorder, err := llmtest.NewCassette(path, llmtest.RECORD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm := llmtest.New().ForAgent("agent0", llmtest.Text("hi"))
+	recorder.Wrap(llm.Handler())(messages, llminterface.RequestToolList{search, fetch}, "agent0", nil)
+
+	player, err := llmtest.NewCassette(path, llmtest.REPLAY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := player.Wrap(neverCalled)(messages, llminterface.RequestToolList{fetch, search}, "agent0", nil)
+	if len(response) != 1 {
+		t.Fatalf("expected the recorded response, got %v", response)
+	}
+	if message, ok := response[0].(llminterface.AssistantMessage); !ok || message.Content != "hi" {
+		t.Errorf("expected the recorded response, got %v", response)
+	}
+}
@@ -0,0 +1,267 @@
+package llmtest
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/runtimes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Step struct {
+	Response llminterface.ResponseMessageList
+	Err      error
+	Delay    time.Duration
+}
+
+func Reply(messages ...llminterface.Message) Step {
+	return Step{Response: llminterface.ResponseMessageList(messages)}
+}
+
+func Text(content string) Step {
+	return Reply(llminterface.AssistantMessage{Content: content})
+}
+
+func ToolCall(toolName string, arguments map[string]any) Step {
+	return Reply(llminterface.ToolCallMessage{ToolName: toolName, Arguments: arguments})
+}
+
+func CreateSubAgent(task string, toolNames ...string) Step {
+	return ToolCall(runtimes.CREATE_SUB_AGENT_TOOL_NAME, map[string]any{
+		"task":         task,
+		"toolNameList": toStrings(toolNames),
+	})
+}
+
+func CreateProfiledSubAgent(task, profile string) Step {
+	return ToolCall(runtimes.CREATE_SUB_AGENT_TOOL_NAME, map[string]any{
+		"task":    task,
+		"profile": profile,
+	})
+}
+
+// Fail makes the handler panic with err, which LLMRuntime reports as an
+// LLMRuntimeErrorEvent and retries with the next step.
+func Fail(err error) Step {
+	return Step{Err: err}
+}
+
+func (s Step) After(delay time.Duration) Step {
+	s.Delay = delay
+	return s
+}
+
+func (s Step) With(messages ...llminterface.Message) Step {
+	s.Response = append(append(llminterface.ResponseMessageList{}, s.Response...), messages...)
+	return s
+}
+
+type Request struct {
+	AgentID  string
+	Messages llminterface.RequestMessageList
+	Tools    llminterface.RequestToolList
+}
+
+// Task returns the agent's task, the last user message; earlier ones come
+// from the conversation history.
+func (r Request) Task() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if userMsg, ok := r.Messages[i].(llminterface.UserMessage); ok {
+			return userMsg.Content
+		}
+	}
+	return ""
+}
+
+func (r Request) SystemPrompt() string {
+	for _, msg := range r.Messages {
+		if systemMsg, ok := msg.(llminterface.SystemMessage); ok {
+			return systemMsg.Content
+		}
+	}
+	return ""
+}
+
+func (r Request) HasTool(name string) bool {
+	for _, tool := range r.Tools {
+		if tool.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Request) ToolResults() []llminterface.ToolResultMessage {
+	results := []llminterface.ToolResultMessage{}
+	for _, msg := range r.Messages {
+		if result, ok := msg.(llminterface.ToolResultMessage); ok {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+type taskScript struct {
+	contains string
+	steps    []Step
+}
+
+type ScriptedLLM struct {
+	agentScripts    map[string][]Step
+	taskScripts     []taskScript
+	subAgentScripts [][]Step
+	requests        []Request
+	unexpected      []Request
+	toolCallCount   int
+	mu              sync.Mutex
+}
+
+func New() *ScriptedLLM {
+	return &ScriptedLLM{
+		agentScripts: make(map[string][]Step),
+	}
+}
+
+func (s *ScriptedLLM) ForAgent(agentID string, steps ...Step) *ScriptedLLM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agentScripts[agentID] = append(s.agentScripts[agentID], steps...)
+	return s
+}
+
+// ForTask scripts the first agent whose task contains the given text, which
+// keeps parallel sub-agents deterministic regardless of creation order.
+func (s *ScriptedLLM) ForTask(contains string, steps ...Step) *ScriptedLLM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.taskScripts = append(s.taskScripts, taskScript{contains: contains, steps: steps})
+	return s
+}
+
+// ForSubAgent scripts the next sub-agent, in the order their first request arrives.
+func (s *ScriptedLLM) ForSubAgent(steps ...Step) *ScriptedLLM {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subAgentScripts = append(s.subAgentScripts, steps)
+	return s
+}
+
+func (s *ScriptedLLM) Handler() llminterface.LLMHandler {
+	return s.handle
+}
+
+func (s *ScriptedLLM) handle(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+	step, err := s.next(Request{AgentID: agentID, Messages: messages, Tools: tools})
+	if err != nil {
+		panic(err)
+	}
+	if step.Delay > 0 {
+		time.Sleep(step.Delay)
+	}
+	if step.Err != nil {
+		panic(step.Err)
+	}
+	return step.Response
+}
+
+func (s *ScriptedLLM) next(request Request) (Step, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, request)
+
+	if _, exists := s.agentScripts[request.AgentID]; !exists {
+		s.assign(request)
+	}
+	steps := s.agentScripts[request.AgentID]
+	if len(steps) == 0 {
+		s.unexpected = append(s.unexpected, request)
+		return Step{}, fmt.Errorf("llmtest: no scripted response left for agent %s", request.AgentID)
+	}
+	s.agentScripts[request.AgentID] = steps[1:]
+	return s.withToolCallIDs(steps[0]), nil
+}
+
+func (s *ScriptedLLM) assign(request Request) {
+	task := request.Task()
+	for i, script := range s.taskScripts {
+		if strings.Contains(task, script.contains) {
+			s.agentScripts[request.AgentID] = script.steps
+			s.taskScripts = append(s.taskScripts[:i:i], s.taskScripts[i+1:]...)
+			return
+		}
+	}
+	if !runtimes.IsPrimaryAgent(request.AgentID) && len(s.subAgentScripts) > 0 {
+		s.agentScripts[request.AgentID] = s.subAgentScripts[0]
+		s.subAgentScripts = s.subAgentScripts[1:]
+	}
+}
+
+func (s *ScriptedLLM) withToolCallIDs(step Step) Step {
+	response := make(llminterface.ResponseMessageList, len(step.Response))
+	for i, msg := range step.Response {
+		if toolCall, ok := msg.(llminterface.ToolCallMessage); ok {
+			if toolCall.ToolCallID == "" {
+				s.toolCallCount++
+				toolCall.ToolCallID = fmt.Sprintf("call_%d", s.toolCallCount)
+			}
+			toolCall.Arguments = copyArguments(toolCall.Arguments)
+			msg = toolCall
+		}
+		response[i] = msg
+	}
+	step.Response = response
+	return step
+}
+
+func (s *ScriptedLLM) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+func (s *ScriptedLLM) RequestsFor(agentID string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := []Request{}
+	for _, request := range s.requests {
+		if request.AgentID == agentID {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+func (s *ScriptedLLM) SubAgentIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	ids := []string{}
+	for _, request := range s.requests {
+		if !runtimes.IsPrimaryAgent(request.AgentID) && !seen[request.AgentID] {
+			seen[request.AgentID] = true
+			ids = append(ids, request.AgentID)
+		}
+	}
+	return ids
+}
+
+func copyArguments(arguments map[string]any) map[string]any {
+	if arguments == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(arguments))
+	for key, value := range arguments {
+		copied[key] = value
+	}
+	return copied
+}
+
+func toStrings(values []string) []any {
+	items := make([]any, len(values))
+	for i, value := range values {
+		items[i] = value
+	}
+	return items
+}
@@ -0,0 +1,79 @@
+package llmtest_test
+
+import (
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func add(ctx context.Context, a, b int) (string, error) {
+	return fmt.Sprint(a + b), nil
+}
+
+var addParams = []llminterface.ToolParamSchema{
+	{Name: "a", Type: "integer", Required: true},
+	{Name: "b", Type: "integer", Required: true},
+}
+
+func TestScriptedRun(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("add 1 and 2", "add"), llmtest.Text("the sum is 3")).
+		ForSubAgent(llmtest.ToolCall("add", map[string]any{"a": 1.0, "b": 2.0}), llmtest.Text("3"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler()).
+		WithTool("add", "Add two numbers", add, addParams)
+	defer al.Close()
+
+	if result := strings.TrimSpace(al.Run("what is 1 + 2?", nil)); result != "the sum is 3" {
+		t.Fatalf("expected the scripted answer, got %q", result)
+	}
+	llm.AssertExhausted(t)
+	llm.AssertRequestCount(t, "agent0", 2)
+	llm.AssertSubAgentCount(t, 1)
+	llm.AssertToolOffered(t, "agent0", runtimes.CREATE_SUB_AGENT_TOOL_NAME)
+	llm.AssertToolResult(t, "agent0", runtimes.CREATE_SUB_AGENT_TOOL_NAME, "3")
+
+	subAgentID := llm.SubAgentIDs()[0]
+	llm.AssertToolOffered(t, subAgentID, "add")
+	llm.AssertToolResult(t, subAgentID, "add", "3")
+	if task := llm.RequestsFor(subAgentID)[0].Task(); task != "add 1 and 2" {
+		t.Errorf("expected the sub-agent task, got %q", task)
+	}
+}
+
+func TestScriptedFailureIsRetried(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.Fail(errors.New("rate limited")), llmtest.Text("recovered"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	defer al.Close()
+
+	if result := strings.TrimSpace(al.Run("try again", nil)); result != "recovered" {
+		t.Fatalf("expected the retried answer, got %q", result)
+	}
+	llm.AssertRequestCount(t, "agent0", 2)
+	llm.AssertExhausted(t)
+}
+
+func TestForTaskMatchesParallelSubAgents(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0",
+			llmtest.CreateSubAgent("first half").With(llminterface.ToolCallMessage{
+				ToolName:  runtimes.CREATE_SUB_AGENT_TOOL_NAME,
+				Arguments: map[string]any{"task": "second half", "toolNameList": []any{}},
+			}),
+			llmtest.Text("both halves")).
+		ForTask("second half", llmtest.Text("two")).
+		ForTask("first half", llmtest.Text("one"))
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	defer al.Close()
+
+	al.Run("split it", nil)
+	llm.AssertExhausted(t)
+	llm.AssertToolResult(t, "agent0", runtimes.CREATE_SUB_AGENT_TOOL_NAME, "one")
+	llm.AssertToolResult(t, "agent0", runtimes.CREATE_SUB_AGENT_TOOL_NAME, "two")
+}
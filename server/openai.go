@@ -0,0 +1,358 @@
+package server
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DEFAULT_CHAT_MODEL is reported as the model when a request names none.
+const DEFAULT_CHAT_MODEL = "agentlauncher"
+
+type ChatCompletionRequest struct {
+	Model         string        `json:"model"`
+	Messages      []ChatMessage `json:"messages"`
+	Stream        bool          `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+type ChatMessage struct {
+	Role       string         `json:"role"`
+	Content    ChatContent    `json:"content"`
+	ToolCalls  []ChatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// ChatContent is the text of a message, sent either as a string or as a
+// list of content parts of which only text parts are kept.
+type ChatContent string
+
+func (c *ChatContent) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*c = ""
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = ChatContent(text)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content must be a string or a list of content parts")
+	}
+	texts := []string{}
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = ChatContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+type ChatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *ChatUsage   `json:"usage,omitempty"`
+}
+
+type ChatChoice struct {
+	Index        int          `json:"index"`
+	Message      *ChatMessage `json:"message,omitempty"`
+	Delta        *ChatDelta   `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type ChatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ToMessages turns the chat messages into the history and the task for
+// AgentLauncher.Run. The last message must come from the user and becomes
+// the task.
+func (r ChatCompletionRequest) ToMessages() (llminterface.MessageList, string, error) {
+	if len(r.Messages) == 0 {
+		return nil, "", errors.New("messages must not be empty")
+	}
+	last := r.Messages[len(r.Messages)-1]
+	if last.Role != "user" {
+		return nil, "", errors.New("the last message must have role user")
+	}
+
+	history := llminterface.MessageList{}
+	toolNames := make(map[string]string)
+	for _, message := range r.Messages[:len(r.Messages)-1] {
+		switch message.Role {
+		case "system", "developer":
+			history = append(history, llminterface.SystemMessage{Content: string(message.Content)})
+		case "user":
+			history = append(history, llminterface.UserMessage{Content: string(message.Content)})
+		case "assistant":
+			if message.Content != "" {
+				history = append(history, llminterface.AssistantMessage{Content: string(message.Content)})
+			}
+			for _, toolCall := range message.ToolCalls {
+				var arguments map[string]any
+				if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil && toolCall.Function.Arguments != "" {
+					return nil, "", fmt.Errorf("invalid arguments for tool call %s: %w", toolCall.ID, err)
+				}
+				toolNames[toolCall.ID] = toolCall.Function.Name
+				history = append(history, llminterface.ToolCallMessage{
+					ToolCallID: toolCall.ID,
+					ToolName:   toolCall.Function.Name,
+					Arguments:  arguments,
+				})
+			}
+		case "tool":
+			history = append(history, llminterface.ToolResultMessage{
+				ToolCallID: message.ToolCallID,
+				ToolName:   toolNames[message.ToolCallID],
+				Result:     string(message.Content),
+			})
+		default:
+			return nil, "", fmt.Errorf("unsupported role %q", message.Role)
+		}
+	}
+	return history, string(last.Content), nil
+}
+
+// chatTask runs a task for a chat completion request, collecting the token
+// usage of the task's agents and, for streaming, the text deltas of its
+// primary agent's answer. The deltas of an LLM turn are held back until the
+// turn ends: a turn that calls tools only narrates what the agent is about
+// to do, so its deltas are dropped rather than mixed into the answer.
+type chatTask struct {
+	id     string
+	deltas *stream
+	usage  ChatUsage
+	mu     sync.Mutex
+}
+
+func (s *Server) startChatTask(ctx context.Context, history llminterface.MessageList, task string) (*chatTask, <-chan string) {
+	t := &chatTask{
+		id:     s.launcher.NewTaskID(),
+		deltas: newStream(s.streamLimit),
+	}
+	usage := launcher.SubscribeTaskEvent(s.launcher, t.id, func(ctx context.Context, e events.LLMUsageEvent) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.usage.PromptTokens += e.InputTokens
+		t.usage.CompletionTokens += e.OutputTokens
+		t.usage.TotalTokens += e.InputTokens + e.OutputTokens
+	})
+	// Watchers see the events in the order they are emitted, so a turn's
+	// deltas all arrive before the response that ends it. A failed attempt
+	// is retried and streams its deltas again.
+	var turn []string
+	stopDeltas := launcher.WatchEvents(s.launcher, func(event eventbus.Event) {
+		switch e := event.(type) {
+		case events.MessageDeltaStreamingEvent:
+			if e.AgentID == t.id {
+				turn = append(turn, e.Delta)
+			}
+		case events.LLMRouteFailedEvent:
+			if e.AgentID == t.id {
+				turn = nil
+			}
+		case events.LLMRuntimeErrorEvent:
+			if e.AgentID == t.id {
+				turn = nil
+			}
+		case events.LLMResponseEvent:
+			if e.AgentID != t.id {
+				return
+			}
+			if !callsTools(e.Response) {
+				for _, delta := range turn {
+					t.deltas.append("delta", []byte(delta))
+				}
+			}
+			turn = nil
+		}
+	})
+
+	results := make(chan string, 1)
+	go func() {
+		defer usage.Unsubscribe()
+		defer stopDeltas()
+		results <- s.launcher.RunTaskContext(ctx, t.id, task, history)
+	}()
+	return t, results
+}
+
+func callsTools(response llminterface.ResponseMessageList) bool {
+	for _, message := range response {
+		if _, ok := message.(llminterface.ToolCallMessage); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *chatTask) Usage() *ChatUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	usage := t.usage
+	return &usage
+}
+
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var request ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: "+err.Error())
+		return
+	}
+	history, task, err := request.ToMessages()
+	if err != nil {
+		writeChatError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if request.Model == "" {
+		request.Model = DEFAULT_CHAT_MODEL
+	}
+
+	completion := ChatCompletion{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+	}
+	t, results := s.startChatTask(r.Context(), history, task)
+	if request.Stream {
+		s.streamChatCompletion(w, r, request, completion, t, results)
+		return
+	}
+
+	result := <-results
+	if message, failed := launcher.TaskError(result); failed {
+		writeChatError(w, http.StatusInternalServerError, "server_error", message)
+		return
+	}
+	stop := "stop"
+	completion.Choices = []ChatChoice{{
+		Message:      &ChatMessage{Role: "assistant", Content: ChatContent(strings.TrimSpace(result))},
+		FinishReason: &stop,
+	}}
+	completion.Usage = t.Usage()
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// streamChatCompletion forwards the text the primary agent streams for its
+// answer, turn by turn as the turns end without calling tools. If its LLM
+// handler does not stream, the final answer is sent as a single chunk once
+// the task finishes.
+func (s *Server) streamChatCompletion(w http.ResponseWriter, r *http.Request, request ChatCompletionRequest, completion ChatCompletion, t *chatTask, results <-chan string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeChatError(w, http.StatusInternalServerError, "server_error", "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	completion.Object = "chat.completion.chunk"
+	send := func(chunk ChatCompletion) {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	sendDelta := func(delta ChatDelta, finishReason *string) {
+		chunk := completion
+		chunk.Choices = []ChatChoice{{Delta: &delta, FinishReason: finishReason}}
+		send(chunk)
+	}
+
+	sendDelta(ChatDelta{Role: "assistant"}, nil)
+	next, streamed := 0, false
+	for {
+		pending, _, changed := t.deltas.since(next)
+		for _, delta := range pending {
+			sendDelta(ChatDelta{Content: string(delta.data)}, nil)
+			streamed = true
+		}
+		next = last(pending, next)
+
+		select {
+		case <-changed:
+			continue
+		case <-r.Context().Done():
+			return
+		case result := <-results:
+			pending, _, _ := t.deltas.since(next)
+			for _, delta := range pending {
+				sendDelta(ChatDelta{Content: string(delta.data)}, nil)
+				streamed = true
+			}
+			if message, failed := launcher.TaskError(result); failed {
+				data, _ := json.Marshal(chatError("server_error", message))
+				fmt.Fprintf(w, "data: %s\n\n", data)
+			} else {
+				if !streamed {
+					sendDelta(ChatDelta{Content: strings.TrimSpace(result)}, nil)
+				}
+				stop := "stop"
+				sendDelta(ChatDelta{}, &stop)
+				if request.StreamOptions != nil && request.StreamOptions.IncludeUsage {
+					chunk := completion
+					chunk.Choices = []ChatChoice{}
+					chunk.Usage = t.Usage()
+					send(chunk)
+				}
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			flusher.Flush()
+			return
+		}
+	}
+}
+
+func chatError(errorType, message string) map[string]any {
+	return map[string]any{
+		"error": map[string]string{
+			"type":    errorType,
+			"message": message,
+		},
+	}
+}
+
+func writeChatError(w http.ResponseWriter, status int, errorType, message string) {
+	writeJSON(w, status, chatError(errorType, message))
+}
@@ -0,0 +1,383 @@
+// Package server exposes an AgentLauncher as a REST API:
+//
+//	POST   /tasks              start a task: {"task": "...", "history": [...]}
+//	GET    /tasks              list tasks
+//	GET    /tasks/{id}         status and result of a task
+//	DELETE /tasks/{id}         cancel a running task
+//	GET    /tasks/{id}/events  Server-Sent Events for the task and its sub-agents
+//	POST   /v1/chat/completions  OpenAI-compatible chat, answered by an agent
+//	GET    /ws                 interactive WebSocket protocol, see ClientMessage
+//
+// Every SSE message carries the event type as its name, the encoded event as
+// its data and a sequence number as its ID, so clients can resume with
+// Last-Event-ID. Events are streamed as they are emitted, including the
+// deltas of an LLM call in progress, and the stream ends after the task's
+// TaskFinishEvent. Each stream keeps its last DEFAULT_STREAM_LIMIT events
+// and the server its last DEFAULT_FINISHED_TASK_LIMIT finished tasks, see
+// WithStreamLimit and WithFinishedTaskLimit.
+package server
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/eventcodec"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type Status string
+
+const (
+	RUNNING   Status = "running"
+	FINISHED  Status = "finished"
+	FAILED    Status = "failed"
+	CANCELLED Status = "cancelled"
+)
+
+// DEFAULT_FINISHED_TASK_LIMIT is how many finished tasks a server keeps by
+// default, the oldest are forgotten first.
+const DEFAULT_FINISHED_TASK_LIMIT = 1000
+
+// streamGracePeriod is how long a finished task waits for its
+// TaskFinishEvent to reach the event stream before closing it anyway.
+const streamGracePeriod = time.Second
+
+type CreateTaskRequest struct {
+	Task    string                   `json:"task"`
+	History llminterface.MessageList `json:"history,omitempty"`
+}
+
+type Task struct {
+	ID         string    `json:"id"`
+	Task       string    `json:"task"`
+	Status     Status    `json:"status"`
+	Result     string    `json:"result,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+type task struct {
+	Task
+	cancel       context.CancelFunc
+	stopWatching func()
+	stream       *stream
+	finished     chan struct{}
+}
+
+type Server struct {
+	launcher          *launcher.AgentLauncher
+	registry          *eventcodec.Registry
+	mux               *http.ServeMux
+	upgrader          websocket.Upgrader
+	tasks             map[string]*task
+	order             []string
+	finishedTaskLimit int
+	streamLimit       int
+	running           sync.WaitGroup
+	mu                sync.RWMutex
+}
+
+func New(al *launcher.AgentLauncher) *Server {
+	s := &Server{
+		launcher:          al,
+		registry:          events.NewRegistry(),
+		mux:               http.NewServeMux(),
+		tasks:             make(map[string]*task),
+		finishedTaskLimit: DEFAULT_FINISHED_TASK_LIMIT,
+		streamLimit:       DEFAULT_STREAM_LIMIT,
+	}
+	s.mux.HandleFunc("POST /tasks", s.handleCreateTask)
+	s.mux.HandleFunc("GET /tasks", s.handleListTasks)
+	s.mux.HandleFunc("GET /tasks/{id}", s.handleGetTask)
+	s.mux.HandleFunc("DELETE /tasks/{id}", s.handleCancelTask)
+	s.mux.HandleFunc("GET /tasks/{id}/events", s.handleTaskEvents)
+	s.mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	s.mux.HandleFunc("GET /ws", s.handleWebSocket)
+	return s
+}
+
+// WithCheckOrigin decides which origins may open WebSocket connections, by
+// default only the server's own.
+func (s *Server) WithCheckOrigin(checkOrigin func(r *http.Request) bool) *Server {
+	s.upgrader.CheckOrigin = checkOrigin
+	return s
+}
+
+// WithFinishedTaskLimit sets how many finished tasks are kept, with their
+// results and event streams. Once there are more, the oldest are forgotten.
+func (s *Server) WithFinishedTaskLimit(limit int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finishedTaskLimit = limit
+	return s
+}
+
+// WithStreamLimit sets how many events the stream of a task keeps for
+// clients to catch up on. A client further behind skips the oldest.
+func (s *Server) WithStreamLimit(limit int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamLimit = limit
+	return s
+}
+
+// Handle registers an extra handler on the server's mux, e.g. a metrics
+// endpoint.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start runs a task in the background and returns its initial state.
+func (s *Server) Start(taskText string, history llminterface.MessageList) Task {
+	t := s.start(taskText, history)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return t.Task
+}
+
+func (s *Server) start(taskText string, history llminterface.MessageList) *task {
+	id := s.launcher.NewTaskID()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.RLock()
+	streamLimit := s.streamLimit
+	s.mu.RUnlock()
+	t := &task{
+		Task: Task{
+			ID:        id,
+			Task:      taskText,
+			Status:    RUNNING,
+			CreatedAt: time.Now(),
+		},
+		cancel:   cancel,
+		stream:   newStream(streamLimit),
+		finished: make(chan struct{}),
+	}
+	// Watch before the task starts so the stream misses no events.
+	// Subscribers would only see the deltas of an LLM call once it returned.
+	inTask := events.ForAgentTree(id)
+	t.stopWatching = launcher.WatchEvents(s.launcher, func(event eventbus.Event) {
+		if inTask(event) {
+			s.record(t, event)
+		}
+	})
+
+	s.mu.Lock()
+	s.tasks[id] = t
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		result := s.launcher.RunTaskContext(ctx, id, taskText, history)
+		s.finish(t, result, ctx.Err() != nil)
+	}()
+	return t
+}
+
+func (s *Server) record(t *task, event eventbus.Event) {
+	envelope, err := s.registry.Encode(event)
+	if err != nil {
+		return
+	}
+	t.stream.append(envelope.Type, envelope.Event)
+	if finish, ok := event.(events.TaskFinishEvent); ok && finish.AgentID == t.ID {
+		t.stream.close()
+		t.stopWatching()
+	}
+}
+
+func (s *Server) finish(t *task, result string, cancelled bool) {
+	s.mu.Lock()
+	t.Result = result
+	t.FinishedAt = time.Now()
+	_, failed := launcher.TaskError(result)
+	switch {
+	case cancelled:
+		t.Status = CANCELLED
+	case failed:
+		t.Status = FAILED
+	default:
+		t.Status = FINISHED
+	}
+	s.evictFinished()
+	s.mu.Unlock()
+	close(t.finished)
+
+	select {
+	case <-t.stream.done:
+	case <-time.After(streamGracePeriod):
+		t.stream.close()
+		t.stopWatching()
+	}
+}
+
+// evictFinished forgets the oldest finished tasks over the limit. The
+// caller holds s.mu.
+func (s *Server) evictFinished() {
+	finished := 0
+	for _, id := range s.order {
+		if s.tasks[id].Status != RUNNING {
+			finished++
+		}
+	}
+	s.order = slices.DeleteFunc(s.order, func(id string) bool {
+		if finished <= s.finishedTaskLimit || s.tasks[id].Status == RUNNING {
+			return false
+		}
+		finished--
+		delete(s.tasks, id)
+		return true
+	})
+}
+
+// Get returns the current state of a task.
+func (s *Server) Get(id string) (Task, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, exists := s.tasks[id]
+	if !exists {
+		return Task{}, false
+	}
+	return t.Task, true
+}
+
+// Cancel stops a running task. It reports false if the task does not exist
+// or has already finished.
+func (s *Server) Cancel(id string) bool {
+	s.mu.RLock()
+	t, exists := s.tasks[id]
+	running := exists && t.Status == RUNNING
+	s.mu.RUnlock()
+	if running {
+		t.cancel()
+	}
+	return running
+}
+
+// Close cancels every running task and waits for them to finish.
+func (s *Server) Close() {
+	s.mu.RLock()
+	for _, t := range s.tasks {
+		t.cancel()
+	}
+	s.mu.RUnlock()
+	s.running.Wait()
+}
+
+func (s *Server) handleCreateTask(w http.ResponseWriter, r *http.Request) {
+	var request CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if strings.TrimSpace(request.Task) == "" {
+		writeError(w, http.StatusBadRequest, "task is required")
+		return
+	}
+	t := s.Start(request.Task, request.History)
+	w.Header().Set("Location", "/tasks/"+t.ID)
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	tasks := make([]Task, 0, len(s.order))
+	for _, id := range s.order {
+		tasks = append(tasks, s.tasks[id].Task)
+	}
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	t, exists := s.Get(r.PathValue("id"))
+	if !exists {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) handleCancelTask(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, exists := s.Get(id); !exists {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	if !s.Cancel(id) {
+		writeError(w, http.StatusConflict, "task is not running")
+		return
+	}
+	t, _ := s.Get(id)
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+func (s *Server) handleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	t, exists := s.tasks[r.PathValue("id")]
+	s.mu.RUnlock()
+	if !exists {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	next, err := lastEventID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		pending, closed, changed := t.stream.since(next)
+		for _, message := range pending {
+			if err := message.write(w); err != nil {
+				return
+			}
+		}
+		next = last(pending, next)
+		flusher.Flush()
+		if closed {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
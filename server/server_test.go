@@ -0,0 +1,447 @@
+package server_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"agentlauncher/server"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newServer(t *testing.T, llm *llmtest.ScriptedLLM) *httptest.Server {
+	t.Helper()
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	s := server.New(al)
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+		al.Close()
+	})
+	return ts
+}
+
+func do(t *testing.T, method, url, body string, into any) int {
+	t.Helper()
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if into != nil {
+		if err := json.NewDecoder(response.Body).Decode(into); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return response.StatusCode
+}
+
+func waitFor(t *testing.T, url string, status server.Status) server.Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var task server.Task
+		do(t, http.MethodGet, url, "", &task)
+		if task.Status == status {
+			return task
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task is %s, expected %s", task.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	ts := newServer(t, llmtest.New().ForAgent("agent0", llmtest.Text("hello there")))
+
+	var created server.Task
+	if status := do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "greet me"}`, &created); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	if created.ID != "agent0" || created.Task != "greet me" {
+		t.Errorf("unexpected task %+v", created)
+	}
+	task := waitFor(t, ts.URL+"/tasks/agent0", server.FINISHED)
+	if strings.TrimSpace(task.Result) != "hello there" {
+		t.Errorf("expected the agent's answer, got %q", task.Result)
+	}
+
+	var tasks []server.Task
+	do(t, http.MethodGet, ts.URL+"/tasks", "", &tasks)
+	if len(tasks) != 1 || tasks[0].ID != "agent0" {
+		t.Errorf("expected the one task, got %+v", tasks)
+	}
+	if status := do(t, http.MethodDelete, ts.URL+"/tasks/agent0", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 cancelling a finished task, got %d", status)
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/tasks/agent9", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", status)
+	}
+	if status := do(t, http.MethodPost, ts.URL+"/tasks", `{"task": " "}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty task, got %d", status)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	ts := newServer(t, llmtest.New().ForAgent("agent0", llmtest.Text("too late").After(time.Second)))
+
+	do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "take your time"}`, nil)
+	if status := do(t, http.MethodDelete, ts.URL+"/tasks/agent0", "", nil); status != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", status)
+	}
+	waitFor(t, ts.URL+"/tasks/agent0", server.CANCELLED)
+}
+
+type sseMessage struct {
+	id, event, data string
+}
+
+func readEvents(t *testing.T, url, lastEventID string) []sseMessage {
+	t.Helper()
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		body, _ := io.ReadAll(response.Body)
+		t.Fatalf("expected an event stream, got %s: %s", contentType, body)
+	}
+
+	messages := []sseMessage{}
+	var message sseMessage
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ": ")
+		switch field {
+		case "id":
+			message.id = value
+		case "event":
+			message.event = value
+		case "data":
+			message.data = value
+		case "":
+			messages = append(messages, message)
+			message = sseMessage{}
+		}
+	}
+	return messages
+}
+
+func TestTaskEvents(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("look it up"), llmtest.Text("done")).
+		ForSubAgent(llmtest.Text("found"))
+	ts := newServer(t, llm)
+
+	do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "research"}`, nil)
+	// The stream ends with the task, and replays what a late client missed.
+	messages := readEvents(t, ts.URL+"/tasks/agent0/events", "")
+	if len(messages) == 0 {
+		t.Fatal("no events streamed")
+	}
+	last := messages[len(messages)-1]
+	if last.event != "TaskFinishEvent" || !strings.Contains(last.data, `"agent_id":"agent0"`) {
+		t.Errorf("expected the stream to end with the task's TaskFinishEvent, got %+v", last)
+	}
+	subAgentEvents := 0
+	for i, message := range messages {
+		if message.id != strconv.Itoa(i+1) {
+			t.Errorf("expected message %d to have id %d, got %q", i, i+1, message.id)
+		}
+		if strings.Contains(message.data, `"agent_id":"agent0_`) {
+			subAgentEvents++
+		}
+	}
+	if subAgentEvents == 0 {
+		t.Error("expected the sub-agent's events in the task stream")
+	}
+
+	resumed := readEvents(t, ts.URL+"/tasks/agent0/events", messages[len(messages)-3].id)
+	if len(resumed) != 2 || resumed[1] != last {
+		t.Errorf("expected the last 2 events after Last-Event-ID, got %+v", resumed)
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/tasks/agent9/events", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown task, got %d", status)
+	}
+}
+
+func TestChatCompletionStreamsOnlyTheAnswer(t *testing.T) {
+	script := func() *llmtest.ScriptedLLM {
+		return llmtest.New().
+			ForAgent("agent0",
+				llmtest.Reply(
+					llminterface.AssistantMessage{Content: "Let me ask a sub-agent."},
+					llminterface.ToolCallMessage{ToolName: "create_sub_agent", Arguments: map[string]any{"task": "find the answer"}},
+				),
+				llmtest.Text("The answer is 42."),
+			).
+			ForSubAgent(llmtest.Text("42"))
+	}
+	complete := func(stream bool) string {
+		handler := streaming(script().Handler())
+		al := launcher.NewAgentLauncher(handler, handler)
+		s := server.New(al)
+		ts := httptest.NewServer(s)
+		defer func() {
+			ts.Close()
+			s.Close()
+			al.Close()
+		}()
+
+		body := fmt.Sprintf(`{"stream": %t, "messages": [{"role": "user", "content": "what is the answer?"}]}`, stream)
+		response, err := http.Post(ts.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		if !stream {
+			var completion server.ChatCompletion
+			if err := json.NewDecoder(response.Body).Decode(&completion); err != nil {
+				t.Fatal(err)
+			}
+			return string(completion.Choices[0].Message.Content)
+		}
+
+		var content strings.Builder
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+			if !ok || data == "[DONE]" {
+				continue
+			}
+			var chunk server.ChatCompletion
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				t.Fatal(err)
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta != nil {
+				content.WriteString(chunk.Choices[0].Delta.Content)
+			}
+		}
+		return content.String()
+	}
+
+	streamed, answer := complete(true), complete(false)
+	if answer != "The answer is 42." {
+		t.Errorf("unexpected answer %q", answer)
+	}
+	if streamed != answer {
+		t.Errorf("streamed %q, expected the answer %q", streamed, answer)
+	}
+}
+
+// streaming streams the text of handler's responses as deltas before
+// returning them.
+func streaming(handler llminterface.LLMHandler) llminterface.LLMHandler {
+	return func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		response := handler(messages, tools, agentID, eb)
+		for _, message := range response {
+			if text, ok := message.(llminterface.AssistantMessage); ok {
+				eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: text.Content})
+			}
+		}
+		return response
+	}
+}
+
+// holdingHandler streams "early", then waits for release before streaming
+// " late" and returning.
+func holdingHandler(release <-chan struct{}) llminterface.LLMHandler {
+	return func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: "early"})
+		select {
+		case <-release:
+		case <-time.After(2 * time.Second):
+		}
+		eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: " late"})
+		return llminterface.ResponseMessageList{llminterface.AssistantMessage{Content: "early late"}}
+	}
+}
+
+func TestTaskEventsStreamDeltasLive(t *testing.T) {
+	release := make(chan struct{})
+	handler := holdingHandler(release)
+	al := launcher.NewAgentLauncher(handler, handler)
+	s := server.New(al)
+	ts := httptest.NewServer(s)
+	defer func() {
+		ts.Close()
+		s.Close()
+		al.Close()
+	}()
+
+	do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "stream it"}`, nil)
+	response, err := http.Get(ts.URL + "/tasks/agent0/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	start := time.Now()
+	deltas := []string{}
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || !strings.Contains(data, `"delta"`) {
+			continue
+		}
+		var delta events.MessageDeltaStreamingEvent
+		if err := json.Unmarshal([]byte(data), &delta); err != nil {
+			t.Fatal(err)
+		}
+		deltas = append(deltas, delta.Delta)
+		if delta.Delta == "early" {
+			if time.Since(start) > time.Second {
+				t.Error("the first delta only arrived after the LLM call returned")
+			}
+			close(release)
+		}
+	}
+	if strings.Join(deltas, "") != "early late" {
+		t.Errorf("expected both deltas, got %q", deltas)
+	}
+}
+
+func TestWebSocketStreamsDeltasLive(t *testing.T) {
+	release := make(chan struct{})
+	handler := holdingHandler(release)
+	al := launcher.NewAgentLauncher(handler, handler)
+	s := server.New(al)
+	ts := httptest.NewServer(s)
+	defer func() {
+		ts.Close()
+		s.Close()
+		al.Close()
+	}()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(server.ClientMessage{Type: server.START_MESSAGE, Ref: "r1", Task: "stream it"}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	deltas := []string{}
+	for {
+		var message server.ServerMessage
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Type == server.TASK_FINISHED_MESSAGE {
+			break
+		}
+		if message.EventType != "MessageDeltaStreamingEvent" {
+			continue
+		}
+		var delta events.MessageDeltaStreamingEvent
+		if err := json.Unmarshal(message.Event, &delta); err != nil {
+			t.Fatal(err)
+		}
+		deltas = append(deltas, delta.Delta)
+		if delta.Delta == "early" {
+			if time.Since(start) > time.Second {
+				t.Error("the first delta only arrived after the LLM call returned")
+			}
+			close(release)
+		}
+	}
+	if strings.Join(deltas, "") != "early late" {
+		t.Errorf("expected both deltas, got %q", deltas)
+	}
+}
+
+func TestFinishedTaskLimit(t *testing.T) {
+	llm := llmtest.New()
+	for i := range 3 {
+		llm.ForAgent(fmt.Sprint("agent", i), llmtest.Text(fmt.Sprint("answer ", i)))
+	}
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	s := server.New(al).WithFinishedTaskLimit(2)
+	ts := httptest.NewServer(s)
+	defer func() {
+		ts.Close()
+		s.Close()
+		al.Close()
+	}()
+
+	for i := range 3 {
+		do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "answer"}`, nil)
+		waitFor(t, fmt.Sprint(ts.URL, "/tasks/agent", i), server.FINISHED)
+	}
+	var tasks []server.Task
+	do(t, http.MethodGet, ts.URL+"/tasks", "", &tasks)
+	if len(tasks) != 2 || tasks[0].ID != "agent1" || tasks[1].ID != "agent2" {
+		t.Errorf("expected the 2 newest tasks, got %+v", tasks)
+	}
+	if status := do(t, http.MethodGet, ts.URL+"/tasks/agent0", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected the oldest task forgotten, got %d", status)
+	}
+}
+
+func TestStreamLimit(t *testing.T) {
+	handler := func(messages llminterface.RequestMessageList, tools llminterface.RequestToolList, agentID string, eb *eventbus.EventBus) llminterface.ResponseMessageList {
+		for i := range 50 {
+			eb.Emit(events.MessageDeltaStreamingEvent{AgentID: agentID, Delta: fmt.Sprint(i)})
+		}
+		return llminterface.ResponseMessageList{llminterface.AssistantMessage{Content: "done"}}
+	}
+	al := launcher.NewAgentLauncher(handler, handler)
+	s := server.New(al).WithStreamLimit(20)
+	ts := httptest.NewServer(s)
+	defer func() {
+		ts.Close()
+		s.Close()
+		al.Close()
+	}()
+
+	do(t, http.MethodPost, ts.URL+"/tasks", `{"task": "talk a lot"}`, nil)
+	waitFor(t, ts.URL+"/tasks/agent0", server.FINISHED)
+	messages := readEvents(t, ts.URL+"/tasks/agent0/events", "")
+	if len(messages) > 20 || messages[len(messages)-1].event != "TaskFinishEvent" {
+		t.Fatalf("expected at most the last 20 events, ending with the task's finish, got %d", len(messages))
+	}
+	first, _ := strconv.Atoi(messages[0].id)
+	if first <= 1 {
+		t.Errorf("expected the oldest events dropped, the first kept is %d", first)
+	}
+	for i, message := range messages {
+		if message.id != strconv.Itoa(first+i) {
+			t.Errorf("expected consecutive IDs from %d, got %q at %d", first, message.id, i)
+		}
+	}
+
+	// A client resuming from a dropped event skips to the oldest kept.
+	resumed := readEvents(t, ts.URL+"/tasks/agent0/events", "1")
+	if len(resumed) != len(messages) || resumed[0] != messages[0] {
+		t.Errorf("expected to resume at the oldest kept event, got %d events", len(resumed))
+	}
+}
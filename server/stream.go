@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+type streamMessage struct {
+	id   int
+	name string
+	data []byte
+}
+
+func (m streamMessage) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", m.id, m.name, m.data)
+	return err
+}
+
+// DEFAULT_STREAM_LIMIT is how many messages a stream keeps by default.
+const DEFAULT_STREAM_LIMIT = 10000
+
+// stream buffers the events of a task so late subscribers can catch up. It
+// keeps the last limit messages, a client that falls further behind skips
+// the ones dropped.
+type stream struct {
+	messages []streamMessage
+	// dropped is how many of the first messages are no longer kept.
+	dropped int
+	limit   int
+	closed  bool
+	changed chan struct{}
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func newStream(limit int) *stream {
+	return &stream{
+		limit:   limit,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+func (st *stream) append(name string, data []byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return
+	}
+	if st.limit > 0 && len(st.messages) >= st.limit {
+		// Drop a tenth at once rather than shifting on every message.
+		n := max(st.limit/10, 1)
+		st.messages = append(st.messages[:0], st.messages[n:]...)
+		st.dropped += n
+	}
+	st.messages = append(st.messages, streamMessage{
+		id:   st.dropped + len(st.messages) + 1,
+		name: name,
+		data: data,
+	})
+	close(st.changed)
+	st.changed = make(chan struct{})
+}
+
+func (st *stream) close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closed {
+		return
+	}
+	st.closed = true
+	close(st.changed)
+	close(st.done)
+}
+
+// since returns the messages kept after the one with ID n, whether the
+// stream is closed, and a channel that is closed on the next change.
+func (st *stream) since(n int) ([]streamMessage, bool, <-chan struct{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	n = min(max(n-st.dropped, 0), len(st.messages))
+	return slices.Clone(st.messages[n:]), st.closed, st.changed
+}
+
+// last returns the ID of the last of messages, or n if there are none.
+func last(messages []streamMessage, n int) int {
+	if len(messages) == 0 {
+		return n
+	}
+	return messages[len(messages)-1].id
+}
+
+// lastEventID returns how many messages the client has already seen.
+func lastEventID(r *http.Request) (int, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid Last-Event-ID %q", value)
+	}
+	return id, nil
+}
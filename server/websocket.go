@@ -0,0 +1,270 @@
+package server
+
+import (
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/llminterface"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Message types of the WebSocket protocol.
+const (
+	// Sent by clients.
+	START_MESSAGE     = "start"
+	FOLLOW_UP_MESSAGE = "message"
+	CANCEL_MESSAGE    = "cancel"
+	APPROVAL_MESSAGE  = "approval"
+	ANSWER_MESSAGE    = "answer"
+
+	// Sent by the server.
+	TASK_STARTED_MESSAGE  = "task_started"
+	EVENT_MESSAGE         = "event"
+	TASK_FINISHED_MESSAGE = "task_finished"
+	ERROR_MESSAGE         = "error"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingPeriod   = wsPongTimeout * 9 / 10
+)
+
+// ClientMessage is a JSON frame sent to GET /ws. Its type selects the
+// fields that are used:
+//
+//	{"type": "start", "ref": "r1", "task": "...", "history": [...]}
+//	{"type": "message", "task_id": "agent0", "content": "..."}
+//	{"type": "cancel", "task_id": "agent0"}
+//	{"type": "approval", "agent_id": "agent0", "tool_call_id": "call_1", "approved": false, "reason": "..."}
+//	{"type": "answer", "agent_id": "agent0_<uuid>", "request_id": "call_2", "answer": "..."}
+//
+// "start" runs a task, acknowledged with a task_started message echoing ref.
+// "message" adds a follow-up to a running task, sent to its primary agent
+// with the next LLM request. "approval" and "answer" reply to the
+// ToolApprovalRequestEvent and UserInputRequestEvent events of the session's
+// tasks. A connection may only act on tasks it started, and its running tasks
+// are cancelled when it closes.
+type ClientMessage struct {
+	Type       string                   `json:"type"`
+	Ref        string                   `json:"ref,omitempty"`
+	TaskID     string                   `json:"task_id,omitempty"`
+	Task       string                   `json:"task,omitempty"`
+	History    llminterface.MessageList `json:"history,omitempty"`
+	Content    string                   `json:"content,omitempty"`
+	AgentID    string                   `json:"agent_id,omitempty"`
+	ToolCallID string                   `json:"tool_call_id,omitempty"`
+	Approved   bool                     `json:"approved,omitempty"`
+	Reason     string                   `json:"reason,omitempty"`
+	RequestID  string                   `json:"request_id,omitempty"`
+	Answer     string                   `json:"answer,omitempty"`
+}
+
+// ServerMessage is a JSON frame sent by GET /ws:
+//
+//	{"type": "task_started", "ref": "r1", "task_id": "agent0", "task": {...}}
+//	{"type": "event", "task_id": "agent0", "seq": 7, "event_type": "MessageDeltaStreamingEvent", "event": {...}}
+//	{"type": "task_finished", "task_id": "agent0", "task": {...}}
+//	{"type": "error", "ref": "r1", "error": "..."}
+//
+// Events are those of package events, for the task's primary agent and all
+// of its sub-agents, in the same encoding as GET /tasks/{id}/events. Their
+// seq numbers match that stream's event IDs.
+type ServerMessage struct {
+	Type      string          `json:"type"`
+	Ref       string          `json:"ref,omitempty"`
+	TaskID    string          `json:"task_id,omitempty"`
+	Task      *Task           `json:"task,omitempty"`
+	Seq       int             `json:"seq,omitempty"`
+	EventType string          `json:"event_type,omitempty"`
+	Event     json.RawMessage `json:"event,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type wsSession struct {
+	server  *Server
+	conn    *websocket.Conn
+	ctx     context.Context
+	tasks   map[string]*task
+	writeMu sync.Mutex
+	mu      sync.Mutex
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	session := &wsSession{
+		server: s,
+		conn:   conn,
+		ctx:    ctx,
+		tasks:  make(map[string]*task),
+	}
+	defer session.cancelTasks()
+	go session.keepAlive()
+
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	for {
+		var message ClientMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			if _, malformed := err.(*json.SyntaxError); malformed {
+				session.send(ServerMessage{Type: ERROR_MESSAGE, Error: "invalid message: " + err.Error()})
+				continue
+			}
+			return
+		}
+		session.handle(message)
+	}
+}
+
+func (ss *wsSession) handle(message ClientMessage) {
+	fail := func(err string) {
+		ss.send(ServerMessage{Type: ERROR_MESSAGE, Ref: message.Ref, TaskID: message.TaskID, Error: err})
+	}
+
+	switch message.Type {
+	case START_MESSAGE:
+		if message.Task == "" {
+			fail("task is required")
+			return
+		}
+		t := ss.server.start(message.Task, message.History)
+		ss.mu.Lock()
+		ss.tasks[t.ID] = t
+		ss.mu.Unlock()
+		snapshot, _ := ss.server.Get(t.ID)
+		ss.send(ServerMessage{Type: TASK_STARTED_MESSAGE, Ref: message.Ref, TaskID: t.ID, Task: &snapshot})
+		go ss.forward(t)
+
+	case FOLLOW_UP_MESSAGE:
+		if !ss.running(message.TaskID) {
+			fail("task is not running")
+			return
+		}
+		ss.server.launcher.FollowUp(message.TaskID, message.Content)
+
+	case CANCEL_MESSAGE:
+		if !ss.owns(message.TaskID) || !ss.server.Cancel(message.TaskID) {
+			fail("task is not running")
+		}
+
+	case APPROVAL_MESSAGE:
+		if !ss.owns(message.AgentID) {
+			fail("unknown agent")
+			return
+		}
+		ss.server.launcher.ApproveToolCall(message.AgentID, message.ToolCallID, message.Approved, message.Reason)
+
+	case ANSWER_MESSAGE:
+		if !ss.owns(message.AgentID) {
+			fail("unknown agent")
+			return
+		}
+		ss.server.launcher.AnswerUser(message.AgentID, message.RequestID, message.Answer)
+
+	default:
+		fail("unknown message type " + message.Type)
+	}
+}
+
+// owns reports whether agentID belongs to a task started by this session.
+func (ss *wsSession) owns(agentID string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for taskID := range ss.tasks {
+		if events.InAgentTree(agentID, taskID) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ss *wsSession) running(taskID string) bool {
+	if !ss.owns(taskID) {
+		return false
+	}
+	t, _ := ss.server.Get(taskID)
+	return t.Status == RUNNING
+}
+
+// forward sends the task's events as the task's stream receives them, which
+// is as they are emitted: the deltas of an LLM call arrive while it runs.
+func (ss *wsSession) forward(t *task) {
+	next := 0
+	for {
+		pending, closed, changed := t.stream.since(next)
+		for _, message := range pending {
+			ss.send(ServerMessage{
+				Type:      EVENT_MESSAGE,
+				TaskID:    t.ID,
+				Seq:       message.id,
+				EventType: message.name,
+				Event:     message.data,
+			})
+		}
+		next = last(pending, next)
+		if closed {
+			break
+		}
+		select {
+		case <-changed:
+		case <-ss.ctx.Done():
+			return
+		}
+	}
+
+	select {
+	case <-t.finished:
+	case <-ss.ctx.Done():
+		return
+	}
+	snapshot, _ := ss.server.Get(t.ID)
+	ss.send(ServerMessage{Type: TASK_FINISHED_MESSAGE, TaskID: t.ID, Task: &snapshot})
+}
+
+func (ss *wsSession) send(message ServerMessage) {
+	ss.writeMu.Lock()
+	defer ss.writeMu.Unlock()
+	ss.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	ss.conn.WriteJSON(message)
+}
+
+func (ss *wsSession) keepAlive() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ss.writeMu.Lock()
+			err := ss.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			ss.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ss.ctx.Done():
+			return
+		}
+	}
+}
+
+// cancelTasks stops the session's running tasks once its connection is gone,
+// nobody is left to approve their tool calls or answer their questions.
+func (ss *wsSession) cancelTasks() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for taskID := range ss.tasks {
+		ss.server.Cancel(taskID)
+	}
+}
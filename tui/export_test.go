@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"agentlauncher/internal/eventbus"
+	"strings"
+	"time"
+)
+
+// Observe applies event to the agent tree as the launcher's bus does.
+func (t *TUI) Observe(event eventbus.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observe(event)
+}
+
+// Tree returns a line per agent in display order, its tree prefix, ID and
+// status.
+func (t *TUI) Tree() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := []string{}
+	for _, row := range t.rows() {
+		lines = append(lines, row.prefix+row.agent.id+": "+row.agent.status())
+	}
+	return lines
+}
+
+// Status returns the status of an agent, or "" for an unknown one.
+func (t *TUI) Status(agentID string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if a, exists := t.agents[agentID]; exists {
+		return a.status()
+	}
+	return ""
+}
+
+// Selected returns the ID of the selected agent.
+func (t *TUI) Selected() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.selected
+}
+
+// PressKey handles key as if it was pressed.
+func (t *TUI) PressKey(key string) bool {
+	return t.handleKey(key)
+}
+
+// Render returns the screen without styles.
+func (t *TUI) Render(width, height int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.render(width, height, time.Now())
+	for i, line := range lines {
+		lines[i] = stripStyles(line)
+	}
+	return lines
+}
+
+func stripStyles(line string) string {
+	for _, style := range []string{styleReset, styleBold, styleDim, styleReverse, styleRed, styleGreen, styleYellow, styleCyan} {
+		line = strings.ReplaceAll(line, style, "")
+	}
+	return line
+}
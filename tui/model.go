@@ -0,0 +1,222 @@
+package tui
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/launcher"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type state int
+
+const (
+	stateRunning state = iota
+	stateFinished
+	stateFailed
+	stateCancelled
+)
+
+// maxTextLength bounds the streamed text kept per agent.
+const maxTextLength = 16 * 1024
+
+type agent struct {
+	id        string
+	profile   string
+	task      string
+	state     state
+	activity  string
+	text      string
+	result    string
+	start     time.Time
+	end       time.Time
+	input     int
+	output    int
+	running   map[string]*toolCall
+	toolCalls []*toolCall
+	subAgents []*agent
+}
+
+type toolCall struct {
+	name     string
+	waiting  string
+	failed   bool
+	result   string
+	start    time.Time
+	end      time.Time
+	finished bool
+}
+
+// observe applies an event to the agent tree. The caller holds t.mu.
+func (t *TUI) observe(event eventbus.Event) {
+	now := time.Now()
+	switch e := event.(type) {
+	case events.TaskCreateEvent:
+		t.agent(e.AgentID, now).task = e.Task
+	case events.AgentCreateEvent:
+		a := t.agent(e.AgentID, now)
+		a.task, a.profile = e.Task, e.Profile
+		a.activity = "starting"
+	case events.LLMRequestEvent:
+		a := t.agent(e.AgentID, now)
+		a.activity, a.text = "thinking", ""
+		if e.RetryCount > 0 {
+			a.activity = "thinking, retry " + strconv.Itoa(e.RetryCount)
+		}
+	case events.LLMRuntimeErrorEvent:
+		t.agent(e.AgentID, now).activity = "llm error: " + e.Error
+	case events.LLMUsageEvent:
+		a := t.agent(e.AgentID, now)
+		a.input += e.InputTokens
+		a.output += e.OutputTokens
+	case events.MessageDeltaStreamingEvent:
+		a := t.agent(e.AgentID, now)
+		a.text += e.Delta
+		if len(a.text) > maxTextLength {
+			a.text = a.text[len(a.text)-maxTextLength:]
+		}
+	case events.ToolExecQueuedEvent:
+		t.toolCall(e.AgentID, e.ToolCallID, e.ToolName, now).waiting = "queued on " + e.Limiter
+	case events.ToolApprovalRequestEvent:
+		t.toolCall(e.AgentID, e.ToolCallID, e.ToolName, now).waiting = "waiting for approval"
+	case events.ToolApprovalResponseEvent:
+		if call := t.agent(e.AgentID, now).running[e.ToolCallID]; call != nil {
+			call.waiting = ""
+		}
+	case events.ToolExecStartEvent:
+		t.toolCall(e.AgentID, e.ToolCallID, e.ToolName, now).waiting = ""
+	case events.ToolExecFinishEvent:
+		t.endToolCall(e.AgentID, e.ToolCallID, e.Result, false, now)
+	case events.ToolExecErrorEvent:
+		t.endToolCall(e.AgentID, e.ToolCallID, e.Error, true, now)
+	case events.UserInputRequestEvent:
+		t.agent(e.AgentID, now).activity = "waiting for the user"
+	case events.AgentFinishEvent:
+		t.agent(e.AgentID, now).result = e.Result
+	case events.TaskCancelEvent:
+		for _, a := range t.agents {
+			if events.InAgentTree(a.id, e.AgentID) && a.state == stateRunning {
+				a.activity = "cancelling"
+			}
+		}
+	case events.TaskFinishEvent:
+		t.finish(e.AgentID, e.Result, now)
+	case events.SubAgentFinishEvent:
+		t.finish(e.AgentID, e.Result, now)
+	}
+}
+
+// finish ends an agent with the result it reported. The caller holds t.mu.
+func (t *TUI) finish(agentID, result string, now time.Time) {
+	a := t.agent(agentID, now)
+	if a.state != stateRunning {
+		return
+	}
+	a.state, a.end, a.result = stateFinished, now, result
+	if message, failed := launcher.TaskError(result); failed {
+		a.state = stateFailed
+		if a.activity == "cancelling" || message == "Task cancelled" {
+			a.state = stateCancelled
+		}
+		a.activity = message
+	}
+	for _, call := range a.running {
+		call.end, call.failed, call.finished = now, true, true
+	}
+	clear(a.running)
+}
+
+// agent returns the agent with the given ID, adding it to the tree when it
+// is new.
+func (t *TUI) agent(agentID string, now time.Time) *agent {
+	if a, exists := t.agents[agentID]; exists {
+		return a
+	}
+	a := &agent{id: agentID, start: now, running: make(map[string]*toolCall)}
+	t.agents[agentID] = a
+	if parentID := runtimes.GetParentAgentID(agentID); parentID != "" {
+		parent := t.agent(parentID, now)
+		parent.subAgents = append(parent.subAgents, a)
+	} else {
+		t.primaryAgents = append(t.primaryAgents, a)
+	}
+	return a
+}
+
+func (t *TUI) toolCall(agentID, toolCallID, name string, now time.Time) *toolCall {
+	a := t.agent(agentID, now)
+	if call, exists := a.running[toolCallID]; exists {
+		return call
+	}
+	call := &toolCall{name: name, start: now}
+	a.running[toolCallID] = call
+	a.toolCalls = append(a.toolCalls, call)
+	return call
+}
+
+func (t *TUI) endToolCall(agentID, toolCallID, result string, failed bool, now time.Time) {
+	a := t.agent(agentID, now)
+	if call, exists := a.running[toolCallID]; exists {
+		call.end, call.result, call.failed, call.finished = now, result, failed, true
+		delete(a.running, toolCallID)
+	}
+	if len(a.running) == 0 {
+		a.activity = "thinking"
+	}
+}
+
+// status describes what a running agent is doing right now.
+func (a *agent) status() string {
+	if a.state != stateRunning {
+		switch a.state {
+		case stateFinished:
+			return "finished"
+		case stateCancelled:
+			return "cancelled"
+		}
+		return "failed: " + a.activity
+	}
+	if len(a.running) == 0 {
+		return a.activity
+	}
+	calling, waiting := []string{}, []string{}
+	for _, call := range a.toolCalls {
+		if call.finished {
+			continue
+		}
+		switch {
+		case call.waiting != "":
+			waiting = append(waiting, call.name+" "+call.waiting)
+		case call.name == runtimes.CREATE_SUB_AGENT_TOOL_NAME:
+			if !slices.Contains(calling, "waiting for sub-agents") {
+				calling = append(calling, "waiting for sub-agents")
+			}
+		case call.name == runtimes.ASK_USER_TOOL_NAME:
+			calling = append(calling, "waiting for the user")
+		default:
+			calling = append(calling, "calling "+call.name)
+		}
+	}
+	return strings.Join(append(waiting, calling...), ", ")
+}
+
+func (a *agent) elapsed(now time.Time) time.Duration {
+	if !a.end.IsZero() {
+		now = a.end
+	}
+	return now.Sub(a.start)
+}
+
+// tokens sums the tokens used by the agent and its sub-agents.
+func (a *agent) tokens() (input, output int) {
+	input, output = a.input, a.output
+	for _, sub := range a.subAgents {
+		subInput, subOutput := sub.tokens()
+		input += subInput
+		output += subOutput
+	}
+	return input, output
+}
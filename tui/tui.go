@@ -0,0 +1,188 @@
+// Package tui shows the agents of a launcher live in a terminal: every
+// primary agent with its tree of sub-agents, what each one is doing, the text
+// it is streaming, its elapsed time and tokens. The selected agent can be
+// inspected in detail or have its task cancelled.
+//
+// Keys:
+//
+//	up, down, k, j   select an agent
+//	enter, i         inspect the selected agent, esc goes back
+//	c                cancel the task of the selected agent
+//	q, ctrl-c        quit
+package tui
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/runtimes"
+	"agentlauncher/launcher"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const refreshInterval = 100 * time.Millisecond
+
+type TUI struct {
+	launcher      *launcher.AgentLauncher
+	in            *os.File
+	out           *os.File
+	agents        map[string]*agent
+	primaryAgents []*agent
+	selected      string
+	inspecting    bool
+	offset        int
+	notice        string
+	mu            sync.Mutex
+}
+
+// New starts following the events of al right away and for as long as al
+// lives, so tasks run before Run are shown too.
+func New(al *launcher.AgentLauncher) *TUI {
+	t := &TUI{
+		launcher: al,
+		in:       os.Stdin,
+		out:      os.Stdout,
+		agents:   make(map[string]*agent),
+	}
+	// Watch rather than subscribe, subscribers only get an agent's streamed
+	// text once its LLM call returns.
+	launcher.WatchEvents(al, func(event eventbus.Event) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.observe(event)
+	})
+	return t
+}
+
+// Run takes over the terminal until q is pressed or ctx is done. Tasks keep
+// running when it returns.
+func (t *TUI) Run(ctx context.Context) error {
+	if !term.IsTerminal(int(t.in.Fd())) || !term.IsTerminal(int(t.out.Fd())) {
+		return errors.New("tui: stdin and stdout must be a terminal")
+	}
+	saved, err := term.MakeRaw(int(t.in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(t.in.Fd()), saved)
+	// Alternate screen, hidden cursor.
+	t.out.WriteString("\x1b[?1049h\x1b[?25l")
+	defer t.out.WriteString("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go t.readKeys(keys)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		t.draw()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+			} else if !t.handleKey(key) {
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys sends the keys pressed, named as in handleKey. It stops with the
+// input, which outlives Run.
+func (t *TUI) readKeys(keys chan<- string) {
+	buffer := make([]byte, 64)
+	for {
+		n, err := t.in.Read(buffer)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for input := buffer[:n]; len(input) > 0; {
+			key, size := decodeKey(input)
+			input = input[size:]
+			if key != "" {
+				keys <- key
+			}
+		}
+	}
+}
+
+func decodeKey(input []byte) (string, int) {
+	if len(input) >= 3 && input[0] == 0x1b && (input[1] == '[' || input[1] == 'O') {
+		switch input[2] {
+		case 'A':
+			return "up", 3
+		case 'B':
+			return "down", 3
+		}
+		return "", 3
+	}
+	switch input[0] {
+	case 0x1b:
+		return "esc", 1
+	case '\r', '\n':
+		return "enter", 1
+	case 0x03:
+		return "ctrl-c", 1
+	}
+	return string(input[:1]), 1
+}
+
+// handleKey applies a key press and reports false to quit.
+func (t *TUI) handleKey(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.notice = ""
+	rows := t.rows()
+	index := 0
+	for i, row := range rows {
+		if row.agent.id == t.selected {
+			index = i
+		}
+	}
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "up", "k":
+		index--
+	case "down", "j":
+		index++
+	case "enter", "i":
+		t.inspecting = !t.inspecting && len(rows) > 0
+	case "esc":
+		t.inspecting = false
+	case "c":
+		if len(rows) == 0 {
+			break
+		}
+		taskID := rootAgentID(rows[index].agent.id)
+		if t.agents[taskID].state != stateRunning {
+			t.notice = taskID + " is not running"
+			break
+		}
+		// Cancel emits on the bus, whose handlers take t.mu.
+		go t.launcher.Cancel(taskID, "Cancelled from the terminal")
+		t.notice = "cancelling " + taskID
+	}
+	if len(rows) > 0 {
+		t.selected = rows[max(0, min(index, len(rows)-1))].agent.id
+	}
+	return true
+}
+
+// rootAgentID returns the primary agent, whose ID is the task's, of an agent.
+func rootAgentID(agentID string) string {
+	for {
+		parentID := runtimes.GetParentAgentID(agentID)
+		if parentID == "" {
+			return agentID
+		}
+		agentID = parentID
+	}
+}
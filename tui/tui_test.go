@@ -0,0 +1,214 @@
+package tui_test
+
+import (
+	"agentlauncher/internal/eventbus"
+	"agentlauncher/internal/events"
+	"agentlauncher/launcher"
+	"agentlauncher/llmtest"
+	"agentlauncher/tui"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTUI(t *testing.T, llm *llmtest.ScriptedLLM) (*tui.TUI, *launcher.AgentLauncher) {
+	t.Helper()
+	al := launcher.NewAgentLauncher(llm.Handler(), llm.Handler())
+	t.Cleanup(al.Close)
+	return tui.New(al), al
+}
+
+func TestTreeFollowsEvents(t *testing.T) {
+	ui, _ := newTUI(t, llmtest.New())
+	steps := []struct {
+		name   string
+		events []eventbus.Event
+		want   []string
+	}{
+		{"task", []eventbus.Event{
+			events.TaskCreateEvent{AgentID: "agent0", Task: "plan a trip"},
+			events.LLMRequestEvent{AgentID: "agent0"},
+		}, []string{"agent0: thinking"}},
+		{"retry", []eventbus.Event{
+			events.LLMRuntimeErrorEvent{AgentID: "agent0", Error: "rate limited"},
+			events.LLMRequestEvent{AgentID: "agent0", RetryCount: 1},
+		}, []string{"agent0: thinking, retry 1"}},
+		{"sub-agents", []eventbus.Event{
+			events.ToolExecStartEvent{AgentID: "agent0", ToolCallID: "call_1", ToolName: "create_sub_agent"},
+			events.ToolExecStartEvent{AgentID: "agent0", ToolCallID: "call_2", ToolName: "create_sub_agent"},
+			events.AgentCreateEvent{AgentID: "agent0_a", Task: "find flights", Profile: "researcher"},
+			events.AgentCreateEvent{AgentID: "agent0_b", Task: "find hotels"},
+			events.LLMRequestEvent{AgentID: "agent0_a"},
+		}, []string{
+			"agent0: waiting for sub-agents",
+			"├─ agent0_a: thinking",
+			"└─ agent0_b: starting",
+		}},
+		{"tool calls", []eventbus.Event{
+			events.ToolExecQueuedEvent{AgentID: "agent0_a", ToolCallID: "call_3", ToolName: "search", Limiter: "tool search"},
+			events.ToolApprovalRequestEvent{AgentID: "agent0_b", ToolCallID: "call_4", ToolName: "book"},
+			events.ToolExecStartEvent{AgentID: "agent0_b", ToolCallID: "call_5", ToolName: "search"},
+		}, []string{
+			"agent0: waiting for sub-agents",
+			"├─ agent0_a: search queued on tool search",
+			"└─ agent0_b: book waiting for approval, calling search",
+		}},
+		{"tool results", []eventbus.Event{
+			events.ToolExecStartEvent{AgentID: "agent0_a", ToolCallID: "call_3", ToolName: "search"},
+			events.ToolApprovalResponseEvent{AgentID: "agent0_b", ToolCallID: "call_4", Approved: true},
+			events.ToolExecErrorEvent{AgentID: "agent0_b", ToolCallID: "call_5", ToolName: "search", Error: "timeout"},
+		}, []string{
+			"agent0: waiting for sub-agents",
+			"├─ agent0_a: calling search",
+			"└─ agent0_b: calling book",
+		}},
+		{"sub-agents finish", []eventbus.Event{
+			events.ToolExecFinishEvent{AgentID: "agent0_a", ToolCallID: "call_3", ToolName: "search", Result: "3 flights"},
+			events.SubAgentFinishEvent{AgentID: "agent0_a", Result: "the 9am flight"},
+			events.ToolExecFinishEvent{AgentID: "agent0", ToolCallID: "call_1", ToolName: "create_sub_agent", Result: "the 9am flight"},
+			events.SubAgentFinishEvent{AgentID: "agent0_b", Result: "Error: no hotels left"},
+			events.ToolExecFinishEvent{AgentID: "agent0", ToolCallID: "call_2", ToolName: "create_sub_agent", Result: "Error: no hotels left"},
+		}, []string{
+			"agent0: thinking",
+			"├─ agent0_a: finished",
+			"└─ agent0_b: failed: no hotels left",
+		}},
+		{"task finishes", []eventbus.Event{
+			events.UserInputRequestEvent{AgentID: "agent0", RequestID: "call_6", Question: "which hotel?"},
+			events.TaskFinishEvent{AgentID: "agent0", Result: "booked"},
+		}, []string{
+			"agent0: finished",
+			"├─ agent0_a: finished",
+			"└─ agent0_b: failed: no hotels left",
+		}},
+	}
+	for _, step := range steps {
+		for _, event := range step.events {
+			ui.Observe(event)
+		}
+		if tree := ui.Tree(); !slices.Equal(tree, step.want) {
+			t.Errorf("after %s, expected\n%s\ngot\n%s", step.name, strings.Join(step.want, "\n"), strings.Join(tree, "\n"))
+		}
+	}
+	if header := ui.Render(120, 10)[0]; !strings.Contains(header, "1 tasks · 2 sub-agents · 0 running") || !strings.HasSuffix(header, "all done") {
+		t.Errorf("unexpected header %q", header)
+	}
+}
+
+func TestCancelledAgents(t *testing.T) {
+	ui, _ := newTUI(t, llmtest.New())
+	for _, event := range []eventbus.Event{
+		events.TaskCreateEvent{AgentID: "agent0", Task: "plan a trip"},
+		events.ToolExecStartEvent{AgentID: "agent0", ToolCallID: "call_1", ToolName: "create_sub_agent"},
+		events.AgentCreateEvent{AgentID: "agent0_a", Task: "find flights"},
+		events.ToolExecStartEvent{AgentID: "agent0_a", ToolCallID: "call_2", ToolName: "search"},
+		events.TaskCreateEvent{AgentID: "agent1", Task: "unrelated"},
+		events.LLMRequestEvent{AgentID: "agent1"},
+		events.TaskCancelEvent{AgentID: "agent0", Reason: "changed my mind"},
+	} {
+		ui.Observe(event)
+	}
+	if tree := ui.Tree(); !slices.Equal(tree, []string{
+		"agent0: waiting for sub-agents",
+		"└─ agent0_a: calling search",
+		"agent1: thinking",
+	}) {
+		t.Errorf("expected the tool calls to show until the agents end, got %q", tree)
+	}
+
+	ui.Observe(events.SubAgentFinishEvent{AgentID: "agent0_a", Result: "Error: changed my mind"})
+	ui.Observe(events.TaskFinishEvent{AgentID: "agent0", Result: "Task cancelled"})
+	if tree := ui.Tree(); !slices.Equal(tree, []string{
+		"agent0: cancelled",
+		"└─ agent0_a: cancelled",
+		"agent1: thinking",
+	}) {
+		t.Errorf("expected the cancelled task's agents to be cancelled, got %q", tree)
+	}
+
+	// A late event of a finished agent does not revive it.
+	ui.Observe(events.TaskFinishEvent{AgentID: "agent0", Result: "done after all"})
+	if status := ui.Status("agent0"); status != "cancelled" {
+		t.Errorf("expected agent0 to stay cancelled, got %q", status)
+	}
+}
+
+func TestCancelKey(t *testing.T) {
+	llm := llmtest.New().
+		ForAgent("agent0", llmtest.CreateSubAgent("research"), llmtest.Text("done")).
+		ForSubAgent(llmtest.Text("found").After(5 * time.Second))
+	ui, al := newTUI(t, llm)
+
+	results := make(chan string, 1)
+	go func() {
+		results <- al.Run("plan a trip", nil)
+	}()
+	waitFor(t, ui, func(tree []string) bool {
+		return len(tree) == 2 && strings.HasSuffix(tree[1], ": thinking")
+	})
+
+	if !ui.PressKey("c") {
+		t.Fatal("c quit the TUI")
+	}
+	if footer := ui.Render(120, 10)[9]; !strings.HasPrefix(footer, "cancelling agent0") {
+		t.Errorf("expected a notice, got %q", footer)
+	}
+	select {
+	case result := <-results:
+		if message, failed := launcher.TaskError(result); !failed || message != "Cancelled from the terminal" {
+			t.Errorf("expected the task to be cancelled, got %q", result)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the task was not cancelled")
+	}
+	waitFor(t, ui, func(tree []string) bool {
+		return tree[0] == "agent0: cancelled" && strings.HasSuffix(tree[1], ": cancelled")
+	})
+
+	ui.PressKey("c")
+	if footer := ui.Render(120, 10)[9]; !strings.HasPrefix(footer, "agent0 is not running") {
+		t.Errorf("expected cancelling a finished task to be refused, got %q", footer)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	ui, _ := newTUI(t, llmtest.New())
+	ui.Observe(events.TaskCreateEvent{AgentID: "agent0", Task: "first"})
+	ui.Observe(events.AgentCreateEvent{AgentID: "agent0_a", Task: "nested"})
+	ui.Observe(events.TaskCreateEvent{AgentID: "agent1", Task: "second"})
+
+	for _, key := range []string{"down", "j", "j", "up"} {
+		if !ui.PressKey(key) {
+			t.Fatalf("%s quit the TUI", key)
+		}
+	}
+	if selected := ui.Selected(); selected != "agent0_a" {
+		t.Errorf("expected the selection to stop at the last agent and come back, got %q", selected)
+	}
+	ui.PressKey("enter")
+	if screen := strings.Join(ui.Render(120, 12), "\n"); !strings.Contains(screen, "agent0_a") || !strings.Contains(screen, "esc back") {
+		t.Errorf("expected agent0_a to be inspected, got:\n%s", screen)
+	}
+	ui.PressKey("esc")
+	if screen := strings.Join(ui.Render(120, 12), "\n"); !strings.Contains(screen, "agent1") || strings.Contains(screen, "esc back") {
+		t.Errorf("expected the tree again, got:\n%s", screen)
+	}
+	for _, key := range []string{"q", "ctrl-c"} {
+		if ui.PressKey(key) {
+			t.Errorf("expected %s to quit", key)
+		}
+	}
+}
+
+// waitFor polls the tree until done accepts it.
+func waitFor(t *testing.T, ui *tui.TUI, done func(tree []string) bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !done(ui.Tree()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected tree %q", ui.Tree())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
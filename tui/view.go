@@ -0,0 +1,350 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	styleReset    = "\x1b[0m"
+	styleBold     = "\x1b[1m"
+	styleDim      = "\x1b[2m"
+	styleReverse  = "\x1b[7m"
+	styleRed      = "\x1b[31m"
+	styleGreen    = "\x1b[32m"
+	styleYellow   = "\x1b[33m"
+	styleCyan     = "\x1b[36m"
+	clearLine     = "\x1b[K"
+	clearBelow    = "\x1b[J"
+	cursorHome    = "\x1b[H"
+	maxInspected  = 20
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// row is an agent's line in the tree.
+type row struct {
+	agent  *agent
+	prefix string
+	indent string
+}
+
+// rows flattens the agent tree in display order. The caller holds t.mu.
+func (t *TUI) rows() []row {
+	rows := []row{}
+	var walk func(agents []*agent, indent string, depth int)
+	walk = func(agents []*agent, indent string, depth int) {
+		for i, a := range agents {
+			prefix, childIndent := "", ""
+			if depth > 0 {
+				prefix, childIndent = indent+"├─ ", indent+"│  "
+				if i == len(agents)-1 {
+					prefix, childIndent = indent+"└─ ", indent+"   "
+				}
+			}
+			rows = append(rows, row{agent: a, prefix: prefix, indent: childIndent})
+			walk(a.subAgents, childIndent, depth+1)
+		}
+	}
+	walk(t.primaryAgents, "", 0)
+	return rows
+}
+
+func (t *TUI) draw() {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil {
+		width, height = defaultWidth, defaultHeight
+	}
+	t.mu.Lock()
+	lines := t.render(width, height, time.Now())
+	t.mu.Unlock()
+
+	var frame strings.Builder
+	frame.WriteString(cursorHome)
+	for i, line := range lines {
+		if i > 0 {
+			frame.WriteString("\r\n")
+		}
+		frame.WriteString(line + styleReset + clearLine)
+	}
+	frame.WriteString(clearBelow)
+	t.out.WriteString(frame.String())
+}
+
+// render returns the lines of the screen. The caller holds t.mu.
+func (t *TUI) render(width, height int, now time.Time) []string {
+	rows := t.rows()
+	var selected *agent
+	for _, row := range rows {
+		if row.agent.id == t.selected {
+			selected = row.agent
+		}
+	}
+	if selected == nil && len(rows) > 0 {
+		selected = rows[0].agent
+		t.selected = selected.id
+	}
+
+	lines := []string{styleBold + fit(t.header(now), width)}
+	body := height - 2
+	if t.inspecting && selected != nil {
+		lines = append(lines, t.inspect(selected, width, body, now)...)
+	} else {
+		lines = append(lines, t.tree(rows, width, body, now)...)
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	footer := "↑/↓ select · enter inspect · c cancel task · q quit"
+	if t.inspecting {
+		footer = "esc back · c cancel task · q quit"
+	}
+	if t.notice != "" {
+		footer = t.notice + " · " + footer
+	}
+	return append(lines, styleDim+fit(footer, width))
+}
+
+func (t *TUI) header(now time.Time) string {
+	running, subAgents, input, output := 0, 0, 0, 0
+	var start time.Time
+	for _, a := range t.agents {
+		if a.state == stateRunning {
+			running++
+		}
+		if rootAgentID(a.id) != a.id {
+			subAgents++
+		}
+		if start.IsZero() || a.start.Before(start) {
+			start = a.start
+		}
+		input += a.input
+		output += a.output
+	}
+	header := fmt.Sprintf("agentlauncher · %d tasks · %d sub-agents · %d running", len(t.primaryAgents), subAgents, running)
+	if input+output > 0 {
+		header += fmt.Sprintf(" · %s in / %s out tokens", formatCount(input), formatCount(output))
+	}
+	if !start.IsZero() {
+		header += " · " + formatDuration(now.Sub(start))
+	}
+	if len(t.agents) > 0 && running == 0 {
+		header += " · all done"
+	}
+	return header
+}
+
+// tree renders each agent with its status on one line, followed by the tail
+// of the text it is streaming while it runs.
+func (t *TUI) tree(rows []row, width, height int, now time.Time) []string {
+	if len(rows) == 0 {
+		return []string{styleDim + "waiting for tasks…"}
+	}
+	type block struct {
+		lines    []string
+		selected bool
+	}
+	blocks := make([]block, 0, len(rows))
+	selectedIndex := 0
+	for i, row := range rows {
+		a := row.agent
+		line := row.prefix + stateIcon(a.state) + " " + a.id
+		if a.profile != "" {
+			line += " (" + a.profile + ")"
+		}
+		line += "  " + formatDuration(a.elapsed(now))
+		if input, output := a.tokens(); input+output > 0 {
+			line += "  " + formatCount(input+output) + " tok"
+		}
+		line += "  " + a.status()
+
+		b := block{selected: a.id == t.selected}
+		if b.selected {
+			selectedIndex = i
+			b.lines = append(b.lines, styleReverse+fit(line, width))
+		} else {
+			b.lines = append(b.lines, stateStyle(a.state)+fit(line, width))
+		}
+		if a.state == stateRunning {
+			detail := lastLine(a.text)
+			if detail == "" {
+				detail = "task: " + a.task
+			}
+			b.lines = append(b.lines, styleDim+fit(row.indent+"  "+detail, width))
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Scroll so that the selected agent stays in view.
+	t.offset = min(t.offset, selectedIndex)
+	for {
+		used := 0
+		for _, b := range blocks[t.offset : selectedIndex+1] {
+			used += len(b.lines)
+		}
+		if used <= height || t.offset == selectedIndex {
+			break
+		}
+		t.offset++
+	}
+	lines := []string{}
+	for _, b := range blocks[t.offset:] {
+		if len(lines)+len(b.lines) > height {
+			break
+		}
+		lines = append(lines, b.lines...)
+	}
+	return lines
+}
+
+// inspect renders the details of one agent: its task, tool calls, sub-agents
+// and the text it streamed or its result.
+func (t *TUI) inspect(a *agent, width, height int, now time.Time) []string {
+	title := a.id
+	if a.profile != "" {
+		title += " (" + a.profile + ")"
+	}
+	input, output := a.tokens()
+	lines := []string{
+		stateStyle(a.state) + fit(fmt.Sprintf("%s %s · %s · %s · %s in / %s out tokens",
+			stateIcon(a.state), title, a.status(), formatDuration(a.elapsed(now)), formatCount(input), formatCount(output)), width),
+		"",
+		styleBold + "Task",
+	}
+	for _, line := range wrap(a.task, width-2) {
+		lines = append(lines, "  "+line)
+	}
+
+	if len(a.toolCalls) > 0 {
+		lines = append(lines, "", styleBold+"Tool calls")
+		calls := a.toolCalls[max(0, len(a.toolCalls)-maxInspected):]
+		for _, call := range calls {
+			icon, detail := stateIcon(stateRunning), call.waiting
+			if call.finished {
+				icon, detail = stateIcon(stateFinished), formatDuration(call.end.Sub(call.start))+"  "+lastLine(call.result)
+				if call.failed {
+					icon = stateIcon(stateFailed)
+				}
+			} else if detail == "" {
+				detail = formatDuration(now.Sub(call.start))
+			}
+			lines = append(lines, fit("  "+icon+" "+call.name+"  "+detail, width))
+		}
+	}
+	if len(a.subAgents) > 0 {
+		lines = append(lines, "", styleBold+"Sub-agents")
+		for _, sub := range a.subAgents {
+			lines = append(lines, fit("  "+stateIcon(sub.state)+" "+sub.id+"  "+sub.status(), width))
+		}
+	}
+
+	text, heading := a.text, "Streaming"
+	if a.state != stateRunning && a.result != "" {
+		text, heading = a.result, "Result"
+	}
+	if text != "" {
+		lines = append(lines, "", styleBold+heading)
+		wrapped := wrap(text, width-2)
+		// Keep the end of the text, which is what changes.
+		if room := height - len(lines); len(wrapped) > room {
+			wrapped = wrapped[max(0, len(wrapped)-room):]
+		}
+		for _, line := range wrapped {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+func stateIcon(s state) string {
+	switch s {
+	case stateFinished:
+		return "✓"
+	case stateFailed:
+		return "✗"
+	case stateCancelled:
+		return "⊘"
+	}
+	return "●"
+}
+
+func stateStyle(s state) string {
+	switch s {
+	case stateFinished:
+		return styleGreen
+	case stateFailed:
+		return styleRed
+	case stateCancelled:
+		return styleYellow
+	}
+	return styleCyan
+}
+
+// fit cuts a line to width runes.
+func fit(line string, width int) string {
+	line = printable(line)
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	if width <= 1 {
+		return string(runes[:max(0, width)])
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// wrap breaks text into lines of at most width runes.
+func wrap(text string, width int) []string {
+	width = max(width, 1)
+	lines := []string{}
+	for _, paragraph := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		runes := []rune(printable(paragraph))
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+// printable replaces the control characters of text, which would upset the
+// screen, with spaces.
+func printable(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, text)
+}
+
+// lastLine returns the last non-empty line of text.
+func lastLine(text string) string {
+	text = strings.TrimSpace(text)
+	if index := strings.LastIndexByte(text, '\n'); index >= 0 {
+		text = text[index+1:]
+	}
+	return strings.TrimSpace(text)
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+	return d.Round(time.Second).String()
+}
+
+func formatCount(n int) string {
+	if n < 1000 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}